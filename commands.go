@@ -0,0 +1,226 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/impl"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// runCommand runs a maintenance subcommand (e.g. "sync_server migrate status") instead of
+// starting the server. Returns false if name is not a subcommand.
+func runCommand(name string, args []string) bool {
+	switch name {
+	case "migrate":
+		os.Exit(migrateCommand(args))
+	case "usage":
+		os.Exit(usageCommand(args))
+	default:
+		return false
+	}
+	return true
+}
+
+// migrateCommand implements "migrate [status|up] [-a auth.db]".
+// status (the default) is a dry run: it prints the DB version and the pending migrations.
+// up applies the pending migrations, as the server does on start.
+func migrateCommand(args []string) int {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var authDBPath string
+	fs.StringVar(&authDBPath, "a", "", `Path to SQLite auth DB. If empty, uses SYNC_AUTH_DB env or auth.db next to the executable.`)
+	_ = fs.Parse(args)
+
+	path := commandAuthDBPath(authDBPath)
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Auth DB path is not set.")
+		return 2
+	}
+	switch action {
+	case "status":
+		status, err := store.GetMigrationStatus(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Auth DB: %s\n", path)
+		fmt.Printf("Schema version: %d (this server: %d)\n", status.Current, status.Latest)
+		if status.Current > status.Latest {
+			fmt.Println("The DB is newer than this server. The server will refuse to start.")
+			return 1
+		}
+		if len(status.Pending) == 0 {
+			fmt.Println("Up to date.")
+			return 0
+		}
+		fmt.Println("Pending migrations:")
+		for _, m := range status.Pending {
+			fmt.Printf("  %s\n", m)
+		}
+		return 0
+	case "up":
+		if err := store.Open(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Auth DB %s is at schema version %d.\n", path, store.LatestSchemaVersion())
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate action '%s'. Use status or up.\n", action)
+		return 2
+	}
+}
+
+// usageCommand implements "usage [-d storage] [-a auth.db] [-user id|username] [-json]": the
+// storage used per user, device, month and media type, as GET /admin/usage reports it. Users are
+// named from the auth DB if it exists; an auth DB with an outdated schema is refused.
+func usageCommand(args []string) int {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	var directory, authDBPath, user string
+	var asJSON bool
+	fs.StringVar(&directory, "d", "", `Storage path location. If empty, uses SYNC_STORAGE_PATH env.`)
+	fs.StringVar(&authDBPath, "a", "", `Path to SQLite auth DB. If empty, uses SYNC_AUTH_DB env or auth.db next to the executable.`)
+	fs.StringVar(&user, "user", "", `Only report the storage folder of this user id or username.`)
+	fs.BoolVar(&asJSON, "json", false, `Print the report as JSON.`)
+	_ = fs.Parse(args)
+
+	if directory = strings.TrimSpace(directory); directory == "" {
+		directory = strings.TrimSpace(os.Getenv(config.UploadPathVariable))
+	}
+	if directory == "" {
+		fmt.Fprintln(os.Stderr, config.ErrStoragePathEmpty)
+		return 2
+	}
+	if _, err := os.ReadDir(directory); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if path := commandAuthDBPath(authDBPath); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			// Reading the report must not change the DB, and Open would migrate an outdated schema.
+			status, err := store.GetMigrationStatus(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if status.Current != status.Latest {
+				fmt.Fprintf(os.Stderr, "Auth DB %s is at schema version %d, this server uses %d. Run \"sync_server migrate up -a %s\" first.\n",
+					path, status.Current, status.Latest, path)
+				return 1
+			}
+			if err := store.Open(path); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			config.AuthDBPath = path
+		} else if authDBPath != "" {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	config.UploadDirectory = directory
+	report := impl.StorageUsage(strings.TrimSpace(user))
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return 0
+	}
+	if report.Volume.Total > 0 {
+		fmt.Printf("Volume %s: %s free of %s\n\n", report.Volume.Path,
+			formatBytes(int64(report.Volume.Free)), formatBytes(int64(report.Volume.Total)))
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tFiles\tOriginals\tThumbnails\tMetadata\tTrash\tTotal")
+	totalsRow := func(name string, t impl.UsageTotals) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", name, t.Total.Count, formatBytes(t.Originals.Size),
+			formatBytes(t.Thumbnails.Size), formatBytes(t.Metadata.Size), formatBytes(t.Trash.Size), formatBytes(t.Total.Size))
+	}
+	for _, u := range report.Users {
+		name := u.UserId
+		if u.Username != "" {
+			name += " (" + u.Username + ")"
+		}
+		totalsRow(name, u.UsageTotals)
+		for _, d := range u.Devices {
+			totalsRow("  "+d.DeviceId, d.UsageTotals)
+		}
+	}
+	totalsRow("All users", report.UsageTotals)
+	tw.Flush()
+
+	for _, u := range report.Users {
+		for _, d := range u.Devices {
+			fmt.Printf("\n%s / %s\n", u.UserId, d.DeviceId)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, t := range []string{"image", "video", "audio", "other"} {
+				if c, ok := d.Types[t]; ok {
+					fmt.Fprintf(tw, "  %s\t%d\t%s\n", t, c.Count, formatBytes(c.Size))
+				}
+			}
+			for _, m := range d.Months {
+				month := "other folders"
+				if m.Year != 0 {
+					month = fmt.Sprintf("%04d/%02d", m.Year, m.Month)
+				}
+				fmt.Fprintf(tw, "  %s\t%d\t%s\n", month, m.Count, formatBytes(m.Size))
+			}
+			tw.Flush()
+		}
+	}
+	return 0
+}
+
+// formatBytes formats a size in bytes with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// commandAuthDBPath resolves the auth DB path the same way the server does.
+func commandAuthDBPath(flagValue string) string {
+	if p := strings.TrimSpace(flagValue); p != "" {
+		return p
+	}
+	if p := strings.TrimSpace(os.Getenv("SYNC_AUTH_DB")); p != "" {
+		return p
+	}
+	config.InitBinDirectory()
+	if config.BinDirectory != "" {
+		return filepath.Join(config.BinDirectory, "auth.db")
+	}
+	return ""
+}
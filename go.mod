@@ -3,6 +3,7 @@ module github.com/takecontrolsoft/sync_server
 go 1.21
 
 require (
+	github.com/barasher/go-exiftool v1.10.0
 	github.com/disintegration/imaging v1.6.2
 	github.com/flytam/filenamify v1.2.0
 	github.com/go-errors/errors v1.5.1
@@ -14,9 +15,21 @@ require (
 
 require (
 	github.com/aws/aws-sdk-go v1.38.20 // indirect
-	github.com/barasher/go-exiftool v1.10.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/timandy/routine v1.1.4 // indirect
 	github.com/u2takey/go-utils v0.3.1 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
 
 require (
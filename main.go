@@ -32,6 +32,10 @@ import (
 
 func main() {
 
+	if len(os.Args) > 1 && runCommand(os.Args[1], os.Args[2:]) {
+		return
+	}
+
 	var port int
 	var directory string
 	var logPath string
@@ -89,6 +93,8 @@ func main() {
 			config.DocumentToTrashEnabled = true
 		}
 		config.DocumentClassifierPath = strings.TrimSpace(os.Getenv("SYNC_DOCUMENT_CLASSIFIER_PATH"))
+		config.InitThumbnailsFromEnv()
+		config.InitAuthFromEnv()
 	}
 
 	if authDBPath != "" {
@@ -0,0 +1,172 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+)
+
+// OIDCIssuer is the issuer URL of the OpenID Connect identity provider
+// (e.g. https://id.example.com/realms/family). OIDC login is disabled when empty.
+// Set via SYNC_OIDC_ISSUER.
+var OIDCIssuer string
+
+// OIDCClientID and OIDCClientSecret are the credentials of the sync server
+// registered as a client at the identity provider.
+// Set via SYNC_OIDC_CLIENT_ID and SYNC_OIDC_CLIENT_SECRET.
+var OIDCClientID, OIDCClientSecret string
+
+// OIDCRedirectURL is the callback URL registered at the identity provider,
+// e.g. https://photos.example.com/auth/oidc/callback. Set via SYNC_OIDC_REDIRECT_URL.
+var OIDCRedirectURL string
+
+// OIDCScopes are the scopes requested at login. Defaults to "openid email profile".
+// Set via SYNC_OIDC_SCOPES (space separated).
+var OIDCScopes []string
+
+// OIDCAutoProvision, when true, creates a local account for an OIDC user with a
+// verified email that does not match any existing user. Set via SYNC_OIDC_AUTO_PROVISION.
+var OIDCAutoProvision bool
+
+// Require2FA, when true, requires every user to complete TOTP two-factor authentication at login.
+// Admins can change it at runtime through /admin/settings, which takes precedence.
+// Set via SYNC_REQUIRE_2FA.
+var Require2FA bool
+
+// TOTPIssuer is the issuer name shown in authenticator apps. Defaults to "Sync Server".
+// Set via SYNC_TOTP_ISSUER.
+var TOTPIssuer string
+
+// PasswordMinLength is the minimum length (in characters) of new passwords. Defaults to 8.
+// Set via SYNC_PASSWORD_MIN_LENGTH.
+var PasswordMinLength int
+
+// BreachedPasswordsFile is an optional list of passwords that must not be used for new
+// accounts: one uppercase hex SHA-1 per line (optionally ":<count>"), sorted by hash.
+// Set via SYNC_BREACHED_PASSWORDS_FILE.
+var BreachedPasswordsFile string
+
+// Argon2Time, Argon2MemoryKiB and Argon2Threads are the argon2id parameters for new password
+// hashes. Defaults are 3 passes, 65536 KiB and 2 threads. Existing hashes with other parameters
+// are rehashed at the next login.
+// Set via SYNC_ARGON2_TIME, SYNC_ARGON2_MEMORY_KIB and SYNC_ARGON2_THREADS.
+var Argon2Time, Argon2MemoryKiB, Argon2Threads int
+
+// AuditRetentionDays is how long audit log entries are kept. 0 keeps them forever. Defaults to 90.
+// Set via SYNC_AUDIT_RETENTION_DAYS.
+var AuditRetentionDays int
+
+// ChangesRetentionDays is how long change log entries (/changes) are kept. 0 keeps them forever.
+// Defaults to 90. Set via SYNC_CHANGES_RETENTION_DAYS.
+var ChangesRetentionDays int
+
+// URLSigningKey is the HMAC key for URLs issued by /sign-url. When empty, a random key is
+// generated once and kept in the auth DB. Set via SYNC_URL_SIGNING_KEY.
+var URLSigningKey string
+
+// RequireSignedURLs, when true, rejects /stream requests without a valid signature.
+// Set via SYNC_REQUIRE_SIGNED_URLS.
+var RequireSignedURLs bool
+
+// Registration modes for /auth/register.
+const (
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationDisabled = "disabled"
+)
+
+// RegistrationMode controls who may create accounts through /auth/register:
+// [RegistrationOpen] (anyone), [RegistrationInvite] (admin-issued invite code required)
+// or [RegistrationDisabled]. Defaults to open. Admins can change it at runtime through
+// /admin/settings, which takes precedence. Set via SYNC_REGISTRATION_MODE.
+var RegistrationMode string
+
+// IsValidRegistrationMode returns true for the known registration modes.
+func IsValidRegistrationMode(mode string) bool {
+	return mode == RegistrationOpen || mode == RegistrationInvite || mode == RegistrationDisabled
+}
+
+// InitAuthFromEnv initializes the optional authentication settings
+// from their environment variables. Unset variables keep the defaults.
+func InitAuthFromEnv() {
+	OIDCIssuer = strings.TrimRight(strings.TrimSpace(os.Getenv("SYNC_OIDC_ISSUER")), "/")
+	OIDCClientID = strings.TrimSpace(os.Getenv("SYNC_OIDC_CLIENT_ID"))
+	OIDCClientSecret = os.Getenv("SYNC_OIDC_CLIENT_SECRET")
+	OIDCRedirectURL = strings.TrimSpace(os.Getenv("SYNC_OIDC_REDIRECT_URL"))
+	OIDCScopes = strings.Fields(os.Getenv("SYNC_OIDC_SCOPES"))
+	if len(OIDCScopes) == 0 {
+		OIDCScopes = []string{"openid", "email", "profile"}
+	}
+	OIDCAutoProvision = envBool("SYNC_OIDC_AUTO_PROVISION", true)
+	Require2FA = envBool("SYNC_REQUIRE_2FA", false)
+	TOTPIssuer = strings.TrimSpace(os.Getenv("SYNC_TOTP_ISSUER"))
+	if TOTPIssuer == "" {
+		TOTPIssuer = "Sync Server"
+	}
+	RegistrationMode = strings.ToLower(strings.TrimSpace(os.Getenv("SYNC_REGISTRATION_MODE")))
+	if !IsValidRegistrationMode(RegistrationMode) {
+		if RegistrationMode != "" {
+			logger.ErrorF("Unknown SYNC_REGISTRATION_MODE %q, using %q", RegistrationMode, RegistrationOpen)
+		}
+		RegistrationMode = RegistrationOpen
+	}
+	PasswordMinLength = envInt("SYNC_PASSWORD_MIN_LENGTH", 8)
+	BreachedPasswordsFile = strings.TrimSpace(os.Getenv("SYNC_BREACHED_PASSWORDS_FILE"))
+	Argon2Time = envInt("SYNC_ARGON2_TIME", 3)
+	Argon2MemoryKiB = envInt("SYNC_ARGON2_MEMORY_KIB", 64*1024)
+	Argon2Threads = envInt("SYNC_ARGON2_THREADS", 2)
+	AuditRetentionDays = envInt("SYNC_AUDIT_RETENTION_DAYS", 90)
+	ChangesRetentionDays = envInt("SYNC_CHANGES_RETENTION_DAYS", 90)
+	URLSigningKey = os.Getenv("SYNC_URL_SIGNING_KEY")
+	RequireSignedURLs = envBool("SYNC_REQUIRE_SIGNED_URLS", false)
+	if OIDCIssuer != "" {
+		logger.InfoF("OIDC login enabled: %s", OIDCIssuer)
+	}
+}
+
+// envInt returns the non-negative integer value of the environment variable name, or def if unset or invalid.
+func envInt(name string, def int) int {
+	s := strings.TrimSpace(os.Getenv(name))
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		logger.ErrorF("Invalid %s %q, using %d", name, s, def)
+		return def
+	}
+	return n
+}
+
+// envBool returns the boolean value of the environment variable name
+// ("1", "true" or "yes" are true; "0", "false" or "no" are false), or def if unset.
+func envBool(name string, def bool) bool {
+	s, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	return def
+}
@@ -88,6 +88,11 @@ var AuthDBPath string
 // Set via SYNC_ADMIN_USER and SYNC_ADMIN_PASSWORD.
 var AdminUser, AdminPassword string
 
+// RescanIntervalMinutes is how often the storage tree is rescanned for files added, changed or
+// deleted outside the API. It is always rescanned at startup; 0 disables the periodic rescan.
+// Set via SYNC_RESCAN_INTERVAL.
+var RescanIntervalMinutes int
+
 // InitBinDirectory sets BinDirectory to the executable's directory and
 // prepends it to PATH so exiftool and ffmpeg are found when next to sync_server.
 func InitBinDirectory() {
@@ -181,6 +186,9 @@ func InitFromEnvVariables() {
 	if AuthDBPath != "" {
 		logger.Info("Auth DB enabled (dangerous endpoints require login)")
 	}
+	RescanIntervalMinutes = envInt("SYNC_RESCAN_INTERVAL", 0)
+	InitThumbnailsFromEnv()
+	InitAuthFromEnv()
 	logger.InfoF("Server port: %d", PortNumber)
 	logger.InfoF(fmt.Sprintf("Storage path: %s", UploadDirectory))
 	logger.InfoF(fmt.Sprintf("Log path: %s", LogPath))
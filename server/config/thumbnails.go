@@ -0,0 +1,80 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+)
+
+// Bounds of a thumbnail rendition size in pixels.
+const (
+	MinThumbnailSize = 32
+	MaxThumbnailSize = 4096
+)
+
+// ThumbnailSizes are the thumbnail renditions created for every image and video:
+// "s<px>" is center-cropped to a square, "f<px>" fits into a <px> box keeping the aspect ratio.
+// Defaults to s250, s500 and f1280. Set via SYNC_THUMBNAIL_SIZES (comma separated).
+var ThumbnailSizes = []string{"s250", "s500", "f1280"}
+
+// ThumbnailFormats are the formats each rendition is stored in: "jpeg" and "webp" (encoded by ffmpeg).
+// Defaults to both. Set via SYNC_THUMBNAIL_FORMATS (comma separated).
+var ThumbnailFormats = []string{"jpeg", "webp"}
+
+// IsValidThumbnailSize returns true for "s<px>" and "f<px>" with px between
+// [MinThumbnailSize] and [MaxThumbnailSize].
+func IsValidThumbnailSize(size string) bool {
+	if len(size) < 2 || (size[0] != 's' && size[0] != 'f') {
+		return false
+	}
+	px, err := strconv.Atoi(size[1:])
+	return err == nil && strconv.Itoa(px) == size[1:] && px >= MinThumbnailSize && px <= MaxThumbnailSize
+}
+
+// InitThumbnailsFromEnv reads the thumbnail settings from the environment. Invalid entries are
+// logged and skipped; an empty or fully invalid list keeps the default.
+func InitThumbnailsFromEnv() {
+	if sizes := envList("SYNC_THUMBNAIL_SIZES", IsValidThumbnailSize); len(sizes) > 0 {
+		ThumbnailSizes = sizes
+	}
+	isFormat := func(format string) bool { return format == "jpeg" || format == "webp" }
+	if formats := envList("SYNC_THUMBNAIL_FORMATS", isFormat); len(formats) > 0 {
+		ThumbnailFormats = formats
+	}
+}
+
+// envList returns the distinct valid, lowercased entries of the comma separated environment variable name.
+func envList(name string, valid func(string) bool) []string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Split(os.Getenv(name), ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !valid(s) {
+			logger.ErrorF("Invalid %s entry %q, skipped", name, s)
+			continue
+		}
+		seen[s] = true
+		list = append(list, s)
+	}
+	return list
+}
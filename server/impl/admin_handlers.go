@@ -22,9 +22,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/takecontrolsoft/go_multi_log/logger"
 	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
 	"github.com/takecontrolsoft/sync_server/server/utils"
 )
 
@@ -97,12 +99,16 @@ func RegenerateThumbnailsHandler(w http.ResponseWriter, r *http.Request) {
 				logger.ErrorF("Regenerate thumbnail %s: %v", rel, err)
 			} else {
 				regenerated++
+				indexMediaFile(userDir, rel)
+				recordFileChange(userDir, store.ChangeMetadata, rel, "")
 			}
 		} else if ext == ".mp4" || ext == ".mov" || ext == ".avi" || ext == ".mkv" || ext == ".webm" {
 			if _, err := BuildVideoThumbnail(userId, deviceId, rel); err != nil {
 				logger.ErrorF("Regenerate video thumbnail %s: %v", rel, err)
 			} else {
 				regenerated++
+				indexMediaFile(userDir, rel)
+				recordFileChange(userDir, store.ChangeMetadata, rel, "")
 			}
 		}
 	}
@@ -144,18 +150,32 @@ func CleanOrphanThumbnailsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // cleanOrphanThumbnailsInDir walks userDir/thumbSubdir (e.g. Thumbnails or Trash/Thumbnails) and removes
-// thumbnail files whose source file no longer exists. Also removes corresponding metadata.
+// thumbnail files and renditions whose source file no longer exists. Also removes corresponding metadata.
 func cleanOrphanThumbnailsInDir(userDir, thumbSubdir string) int {
 	dir := filepath.Join(userDir, filepath.FromSlash(thumbSubdir))
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return 0
 	}
 	prefix := filepath.ToSlash(thumbSubdir) + "/"
+	sourcePath := func(sourceRel string) string {
+		if strings.HasPrefix(thumbSubdir, TrashFolder) {
+			return filepath.Join(userDir, TrashFolder, sourceRel)
+		}
+		return filepath.Join(userDir, sourceRel)
+	}
+	deleted := make(map[string]bool)
 	var removed int
 	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		}
+		if strings.HasPrefix(d.Name(), renditionTempPrefix) {
+			// Left behind by an interrupted write; not a thumbnail of any file.
+			if info, err := d.Info(); err == nil && time.Since(info.ModTime()) > time.Hour {
+				_ = os.Remove(path)
+			}
+			return nil
+		}
 		rel, err := filepath.Rel(userDir, path)
 		if err != nil {
 			return nil
@@ -165,16 +185,20 @@ func cleanOrphanThumbnailsInDir(userDir, thumbSubdir string) int {
 			return nil
 		}
 		sourceRel := strings.TrimPrefix(rel, prefix)
+		// A rendition ("photo.jpg@s250.jpg") belongs to "photo.jpg"; a source can also be named like one.
+		if suffix := renditionSuffix(sourceRel); suffix != "" {
+			if _, err := os.Stat(sourcePath(sourceRel)); err == nil {
+				return nil
+			}
+			sourceRel = strings.TrimSuffix(sourceRel, suffix)
+		}
 		if strings.HasSuffix(sourceRel, ".jpeg") {
+			if _, err := os.Stat(sourcePath(sourceRel)); err == nil {
+				return nil
+			}
 			sourceRel = strings.TrimSuffix(sourceRel, ".jpeg")
 		}
-		var sourcePath string
-		if strings.HasPrefix(thumbSubdir, TrashFolder) {
-			sourcePath = filepath.Join(userDir, TrashFolder, sourceRel)
-		} else {
-			sourcePath = filepath.Join(userDir, sourceRel)
-		}
-		if _, err := os.Stat(sourcePath); err == nil {
+		if _, err := os.Stat(sourcePath(sourceRel)); err == nil {
 			return nil
 		}
 		if err := os.Remove(path); err != nil {
@@ -182,13 +206,50 @@ func cleanOrphanThumbnailsInDir(userDir, thumbSubdir string) int {
 			return nil
 		}
 		removed++
-		var metaPath string
 		if strings.HasPrefix(thumbSubdir, TrashFolder) {
-			metaPath = MetadataPath(userDir, TrashFolder+"/"+sourceRel)
-		} else {
-			metaPath = MetadataPath(userDir, sourceRel)
+			sourceRel = TrashFolder + "/" + sourceRel
 		}
-		_ = os.Remove(metaPath)
+		if deleted[sourceRel] {
+			return nil
+		}
+		deleted[sourceRel] = true
+		_ = os.Remove(MetadataPath(userDir, sourceRel))
+		// The original was deleted outside the server.
+		onFileDeleted(userDir, sourceRel)
+		return nil
+	})
+	return removed
+}
+
+// cleanOrphanMetadataInDir walks userDir/metaSubdir (Metadata or Trash/Metadata) and removes metadata
+// files whose source file no longer exists.
+func cleanOrphanMetadataInDir(userDir, metaSubdir string) int {
+	dir := filepath.Join(userDir, filepath.FromSlash(metaSubdir))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0
+	}
+	var removed int
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		sourceRel := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if strings.HasPrefix(metaSubdir, TrashFolder) {
+			sourceRel = TrashFolder + "/" + sourceRel
+		}
+		if _, err := os.Stat(filepath.Join(userDir, filepath.FromSlash(sourceRel))); err == nil {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logger.ErrorF("Clean orphan metadata remove %s: %v", path, err)
+			return nil
+		}
+		removed++
+		onFileDeleted(userDir, sourceRel)
 		return nil
 	})
 	return removed
@@ -232,6 +293,7 @@ func RunDocumentDetectionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var moved int
+	movedPaths := make([]string, 0)
 	for _, rel := range all {
 		if strings.HasPrefix(rel, TrashFolder+"/") || rel == TrashFolder {
 			continue
@@ -246,11 +308,14 @@ func RunDocumentDetectionHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		if classifierMoved {
 			moved++
+			movedPaths = append(movedPaths, rel)
 		} else if LooksLikeDocument(fullPath) {
 			MoveRelativePathToTrash(userDir, rel)
 			moved++
+			movedPaths = append(movedPaths, rel)
 		}
 	}
+	audit(r, userId, deviceId, AuditDocumentDetection, movedPaths, auditOK, "")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(map[string]int{"Moved": moved})
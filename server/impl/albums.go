@@ -0,0 +1,317 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// albumRequest is the body of all album endpoints. Files are paths as returned by /files: with
+// UserData.DeviceId set they are relative to that device ("2024/07/photo.jpg"), otherwise they
+// start with the device id ("phone/2024/07/photo.jpg").
+type albumRequest struct {
+	UserData userData `json:"UserData"`
+	AlbumId  string   `json:"AlbumId"`
+	Name     string   `json:"Name"`
+	Cover    string   `json:"Cover"`
+	Files    []string `json:"Files"`
+	// Filters for /albums/files.
+	annotationFilter
+}
+
+func (req *albumRequest) requestUser() string { return req.UserData.User }
+
+type albumFilesResponse struct {
+	Count int `json:"Count"`
+}
+
+// visibleAlbumItems returns the items of the album whose files exist outside Trash. Items of
+// trashed files stay in the album and show up again when the files are restored.
+func visibleAlbumItems(userFolder, albumId string) ([]store.FileRef, error) {
+	items, err := store.ListAlbumItems(albumId)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]store.FileRef, 0, len(items))
+	for _, item := range items {
+		if !strings.HasPrefix(item.Path, trashPrefix) && fileRefExists(userFolder, item) {
+			visible = append(visible, item)
+		}
+	}
+	return visible, nil
+}
+
+// userAlbum returns the album albumId of userId, or writes 404 and returns nil.
+func userAlbum(w http.ResponseWriter, userId, albumId string) *store.Album {
+	album, err := store.GetAlbum(albumId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return nil
+	}
+	if album == nil || album.UserId != userId {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	return album
+}
+
+// ListAlbumsHandler returns the albums of the user. Cover is the "deviceId/path" of the chosen
+// cover, or of the first file if none was chosen (empty for an empty album).
+// POST body: { "UserData": { "User": "" } }
+// -> [ { "Id": "", "UserId": "", "Name": "", "Cover": "phone/2024/07/photo.jpg", "Count": 0, "CreatedAt": 0, "UpdatedAt": 0 } ]
+func ListAlbumsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := decodeUserRequest(w, r, &albumRequest{})
+	if !ok {
+		return
+	}
+	albums, err := store.ListAlbums(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	userFolder := ResolveToUserId(userId)
+	for i := range albums {
+		items, err := visibleAlbumItems(userFolder, albums[i].Id)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		albums[i].Count = len(items)
+		albums[i].Cover = albumCover(albums[i].Cover, items)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(albums)
+}
+
+// containsFileRef returns true if item is in items.
+func containsFileRef(items []store.FileRef, item store.FileRef) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
+
+// albumCover returns cover if it is one of the visible items, else the first visible item.
+func albumCover(cover string, items []store.FileRef) string {
+	for _, item := range items {
+		if fileRefPath("", item) == cover {
+			return cover
+		}
+	}
+	if len(items) > 0 {
+		return fileRefPath("", items[0])
+	}
+	return ""
+}
+
+// CreateAlbumHandler creates an album, optionally with files (missing files are skipped).
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Name": "Holidays", "Files": [] } -> the album
+func CreateAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id, err := utils.RandomHex(16)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := store.CreateAlbum(store.Album{Id: id, UserId: userId, Name: name}); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if items := existingFileRefs(userId, req.UserData.DeviceId, req.Files); len(items) > 0 {
+		if _, err := store.AddAlbumItems(id, items); err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	album, err := store.GetAlbum(id)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(album)
+}
+
+// UpdateAlbumHandler renames an album and/or sets its cover. An empty Name keeps the name;
+// Cover must be a file of the album ("" keeps the cover).
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "AlbumId": "", "Name": "", "Cover": "" }
+func UpdateAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	name, cover := album.Name, album.Cover
+	if n := strings.TrimSpace(req.Name); n != "" {
+		name = n
+	}
+	if req.Cover != "" {
+		item, ok := fileRefFromPath(strings.TrimSpace(req.UserData.DeviceId), req.Cover)
+		items, err := store.ListAlbumItems(album.Id)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if !ok || !containsFileRef(items, item) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cover = fileRefPath("", item)
+	}
+	if err := store.UpdateAlbum(album.Id, name, cover); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteAlbumHandler deletes an album. The files are not touched.
+// POST body: { "UserData": { "User": "" }, "AlbumId": "" }
+func DeleteAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	if err := store.DeleteAlbum(album.Id); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// AddAlbumFilesHandler appends files to an album; files already in it keep their position and
+// files that do not exist are skipped.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "AlbumId": "", "Files": [] } -> { "Count": <added> }
+func AddAlbumFilesHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	added, err := store.AddAlbumItems(album.Id, existingFileRefs(userId, req.UserData.DeviceId, req.Files))
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(albumFilesResponse{Count: added})
+}
+
+// RemoveAlbumFilesHandler removes files from an album. The files are not touched.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "AlbumId": "", "Files": [] } -> { "Count": <removed> }
+func RemoveAlbumFilesHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	removed, err := store.RemoveAlbumItems(album.Id, fileRefsFromPaths(req.UserData.DeviceId, req.Files))
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(albumFilesResponse{Count: removed})
+}
+
+// ReorderAlbumHandler moves the given files to the front of the album in the given order; the
+// other files follow in their current order.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "AlbumId": "", "Files": [] }
+func ReorderAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	if err := store.ReorderAlbumItems(album.Id, fileRefsFromPaths(req.UserData.DeviceId, req.Files)); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetAlbumFilesHandler lists the files of an album in album order, in the format of /files: with
+// a DeviceId only that device's files as "2024/07/photo.jpg", else all as "phone/2024/07/photo.jpg".
+// Files in Trash are left out until they are restored. Favorite, MinRating and Tags filter as in /files.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "AlbumId": "" } -> [ "..." ]
+func GetAlbumFilesHandler(w http.ResponseWriter, r *http.Request) {
+	var req albumRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	album := userAlbum(w, userId, req.AlbumId)
+	if album == nil {
+		return
+	}
+	items, err := visibleAlbumItems(ResolveToUserId(userId), album.Id)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	files := make([]string, 0, len(items))
+	for _, item := range items {
+		if deviceId == "" || item.DeviceId == deviceId {
+			files = append(files, fileRefPath(deviceId, item))
+		}
+	}
+	files = filterAnnotated(userId, deviceId, files, req.annotationFilter)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(files)
+}
@@ -0,0 +1,41 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestAlbumCover(t *testing.T) {
+	items := []store.FileRef{{DeviceId: "phone", Path: "2024/07/a.jpg"}, {DeviceId: "tab", Path: "2024/08/b.jpg"}}
+	tests := []struct {
+		cover string
+		items []store.FileRef
+		want  string
+	}{
+		{"", items, "phone/2024/07/a.jpg"},
+		{"tab/2024/08/b.jpg", items, "tab/2024/08/b.jpg"},
+		{"phone/2024/07/trashed.jpg", items, "phone/2024/07/a.jpg"},
+		{"phone/2024/07/a.jpg", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := albumCover(tt.cover, tt.items); got != tt.want {
+			t.Errorf("albumCover(%q) = %q, want %q", tt.cover, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Limits of annotations.
+const (
+	maxRating    = 5
+	maxTagLength = 64
+)
+
+// annotationsRequest is the body of the annotation endpoints. Files use the paths of /files.
+type annotationsRequest struct {
+	UserData   userData `json:"UserData"`
+	Files      []string `json:"Files"`
+	Favorite   *bool    `json:"Favorite"`
+	Rating     *int     `json:"Rating"`
+	AddTags    []string `json:"AddTags"`
+	RemoveTags []string `json:"RemoveTags"`
+}
+
+func (req *annotationsRequest) requestUser() string { return req.UserData.User }
+
+type annotationsCountResponse struct {
+	Count int `json:"Count"`
+}
+
+// annotationFilter selects files by their annotations in listing requests (/files, /albums/files).
+type annotationFilter struct {
+	// Favorite lists only favorites.
+	Favorite bool `json:"Favorite"`
+	// MinRating lists only files rated at least MinRating.
+	MinRating int `json:"MinRating"`
+	// Tags lists only files with all of these tags.
+	Tags []string `json:"Tags"`
+}
+
+func (f annotationFilter) active() bool {
+	return f.Favorite || f.MinRating > 0 || len(f.Tags) > 0
+}
+
+func (f annotationFilter) matches(a *store.Annotation) bool {
+	if a == nil || (f.Favorite && !a.Favorite) || a.Rating < f.MinRating {
+		return false
+	}
+	for _, want := range normalizeTags(f.Tags) {
+		found := false
+		for _, tag := range a.Tags {
+			found = found || tag == want
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// filterAnnotated returns the files of user that match f. files are paths as /files returns them
+// for deviceId ("" = all devices, paths start with the device id). Library files ("@lib/...") carry
+// no annotations of user and are left out when a filter is set.
+func filterAnnotated(user, deviceId string, files []string, f annotationFilter) []string {
+	if !f.active() || config.AuthDBPath == "" {
+		return files
+	}
+	annotations, err := store.ListAnnotations(canonicalUserId(user), deviceId)
+	if err != nil {
+		logger.ErrorF("Annotations of %s: %v", user, err)
+		return files
+	}
+	filtered := make([]string, 0, len(files))
+	for _, file := range files {
+		ref := store.FileRef{DeviceId: deviceId, Path: normalizeRequestPath(file)}
+		if deviceId == "" {
+			ref.DeviceId, ref.Path, _ = strings.Cut(ref.Path, "/")
+		}
+		if !isLibraryPath(file) && f.matches(annotations[ref]) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// normalizeTags trims and lowercases tags and drops empty and duplicate ones.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// SetAnnotationsHandler sets favorite, rating and/or tags on many files at once. Omitted fields
+// are left unchanged; Rating 0 removes the rating. Tags are case-insensitive. Files that do not
+// exist are skipped.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Files": [], "Favorite": true, "Rating": 4, "AddTags": [], "RemoveTags": [] }
+// -> { "Count": <files updated> }
+func SetAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req annotationsRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	change := store.AnnotationChange{Favorite: req.Favorite, Rating: req.Rating,
+		AddTags: normalizeTags(req.AddTags), RemoveTags: normalizeTags(req.RemoveTags)}
+	if change.Rating != nil && (*change.Rating < 0 || *change.Rating > maxRating) {
+		utils.RenderError(w, InvalidRating(maxRating), http.StatusBadRequest)
+		return
+	}
+	for _, tag := range change.AddTags {
+		if len(tag) > maxTagLength {
+			utils.RenderError(w, TagTooLong(maxTagLength), http.StatusBadRequest)
+			return
+		}
+	}
+	files := existingFileRefs(userId, req.UserData.DeviceId, req.Files)
+	if err := store.SetAnnotations(userId, files, change); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		recordChange(userId, f.DeviceId, store.ChangeAnnotations, f.Path, "")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(annotationsCountResponse{Count: len(files)})
+}
+
+// ClearAnnotationsHandler removes favorite, rating and tags from many files at once. Files need
+// not exist, so annotations of deleted files can be cleaned up.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Files": [] } -> { "Count": <files cleared> }
+func ClearAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req annotationsRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	files, err := store.ClearAnnotations(userId, fileRefsFromPaths(req.UserData.DeviceId, req.Files))
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		recordChange(userId, f.DeviceId, store.ChangeAnnotations, f.Path, "")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(annotationsCountResponse{Count: len(files)})
+}
+
+// GetAnnotationsHandler returns the annotations of the given files, or of all annotated files of
+// the device (all devices if DeviceId is empty) when Files is empty. Files in Trash keep their
+// annotations under "Trash/..." paths.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Files": [] }
+// -> [ { "DeviceId": "", "Path": "", "Favorite": false, "Rating": 0, "Tags": [], "UpdatedAt": 0 } ]
+func GetAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req annotationsRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	all, err := store.ListAnnotations(userId, deviceId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	annotations := make([]*store.Annotation, 0)
+	if len(req.Files) == 0 {
+		for _, a := range all {
+			annotations = append(annotations, a)
+		}
+		sort.Slice(annotations, func(i, j int) bool {
+			if annotations[i].DeviceId != annotations[j].DeviceId {
+				return annotations[i].DeviceId < annotations[j].DeviceId
+			}
+			return annotations[i].Path < annotations[j].Path
+		})
+	} else {
+		for _, ref := range fileRefsFromPaths(deviceId, req.Files) {
+			if a := all[ref]; a != nil {
+				annotations = append(annotations, a)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(annotations)
+}
+
+// ListTagsHandler returns the tags of the user with the number of files carrying each.
+// POST body: { "UserData": { "User": "" } } -> [ { "Tag": "", "Count": 0 } ]
+func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := decodeUserRequest(w, r, &annotationsRequest{})
+	if !ok {
+		return
+	}
+	tags, err := store.ListTags(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(tags)
+}
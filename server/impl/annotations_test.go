@@ -0,0 +1,99 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestAnnotationFilterMatches(t *testing.T) {
+	a := &store.Annotation{Favorite: true, Rating: 3, Tags: []string{"beach", "family"}}
+	tests := []struct {
+		name   string
+		filter annotationFilter
+		a      *store.Annotation
+		want   bool
+	}{
+		{"favorite", annotationFilter{Favorite: true}, a, true},
+		{"not favorite", annotationFilter{Favorite: true}, &store.Annotation{Rating: 5}, false},
+		{"min rating", annotationFilter{MinRating: 3}, a, true},
+		{"rating too low", annotationFilter{MinRating: 4}, a, false},
+		{"all tags", annotationFilter{Tags: []string{"Beach ", "family"}}, a, true},
+		{"missing tag", annotationFilter{Tags: []string{"beach", "dog"}}, a, false},
+		{"not annotated", annotationFilter{MinRating: 1}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.a); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" Beach", "beach", "", "Family "})
+	if want := []string{"beach", "family"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+}
+
+func TestAnnotationsHandlersCount(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, token := createTestUser(t, "alice@example.com")
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "07", "a.jpg"))
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "07", "b.jpg"))
+
+	post := func(handler http.HandlerFunc, body string) (int, int) {
+		r := httptest.NewRequest(http.MethodPost, "/annotations", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		var resp annotationsCountResponse
+		_ = json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp.Count
+	}
+	// b.jpg twice, and a missing file.
+	body := `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["2024/07/a.jpg","2024/07/b.jpg",` +
+		`"2024/07/b.jpg","2024/07/missing.jpg"],"Favorite":true}`
+	if code, count := post(SetAnnotationsHandler, body); code != http.StatusOK || count != 2 {
+		t.Fatalf("set: %d, Count %d; want 200, 2", code, count)
+	}
+	annotations, err := store.ListAnnotations(userId, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 2 || annotations[store.FileRef{DeviceId: "phone", Path: "2024/07/missing.jpg"}] != nil {
+		t.Fatalf("annotations = %v", annotations)
+	}
+	body = `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["2024/07/a.jpg","2024/07/missing.jpg"]}`
+	if code, count := post(ClearAnnotationsHandler, body); code != http.StatusOK || count != 1 {
+		t.Fatalf("clear: %d, Count %d; want 200, 1", code, count)
+	}
+}
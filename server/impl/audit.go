@@ -0,0 +1,155 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Audited actions.
+const (
+	AuditLogin             = "login"
+	AuditLoginTwoFactor    = "login.2fa"
+	AuditLoginOIDC         = "login.oidc"
+	AuditRegister          = "register"
+	AuditTrash             = "trash"
+	AuditRestore           = "restore"
+	AuditDocumentDetection = "document_detection"
+	AuditAdminSettings     = "admin.settings"
+	AuditAdminInvite       = "admin.invite"
+	AuditAdminUser         = "admin.user"
+	AuditAdminStorage      = "admin.storage"
+	AuditShare             = "share"
+	AuditLibrary           = "library"
+)
+
+// Audit results.
+const (
+	auditOK     = "ok"
+	auditDenied = "denied"
+	auditFailed = "failed"
+)
+
+// auditSystemActor is the actor of actions the server takes on its own, e.g. document detection after upload.
+const auditSystemActor = "system"
+
+// Limits of GET /admin/audit.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// audit appends an entry for a request. The actor is the session user if the request has a
+// valid token, else userId. Failures are logged; they never fail the request.
+func audit(r *http.Request, userId, device, action string, paths []string, result, detail string) {
+	actor := SessionUserId(r)
+	if actor == "" {
+		actor = userId
+	}
+	recordAudit(store.AuditEntry{Actor: actor, UserId: userId, Device: device, IP: clientIP(r),
+		Action: action, Paths: paths, Result: result, Detail: detail})
+}
+
+func recordAudit(e store.AuditEntry) {
+	if err := store.AppendAudit(e); err != nil {
+		logger.ErrorF("Audit %s for %s: %v", e.Action, e.UserId, err)
+	}
+}
+
+// clientIP returns the IP address of the direct peer. Forwarding headers are not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// AuditLogHandler returns audit entries, newest first. Requires an admin token.
+// GET /admin/audit?user=<userId>&action=trash&from=<unix>&to=<unix>&before=<id>&limit=100
+// -> [ { "Id": 0, "At": 0, "Actor": "", "UserId": "", "Device": "", "IP": "", "Action": "", "Paths": [], "Result": "", "Detail": "" } ]
+// For the next page pass the Id of the last entry as before.
+func AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := store.AuditFilter{Action: q.Get("action"), Limit: defaultAuditLimit}
+	if user := q.Get("user"); user != "" {
+		filter.UserId = user
+		if id := store.GetUserIdByEmail(user); id != "" {
+			filter.UserId = id
+		}
+	}
+	for name, dst := range map[string]*int64{"from": &filter.From, "to": &filter.To, "before": &filter.BeforeId} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				utils.RenderError(w, err, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxAuditLimit)
+	}
+	entries, err := store.QueryAudit(filter)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// StartAuditRetention deletes audit entries older than config.AuditRetentionDays,
+// now and then once a day. Does nothing if the retention is 0 (keep forever).
+func StartAuditRetention() {
+	if config.AuditRetentionDays <= 0 {
+		return
+	}
+	go func() {
+		for {
+			before := time.Now().AddDate(0, 0, -config.AuditRetentionDays)
+			if n, err := store.PruneAudit(before); err != nil {
+				logger.ErrorF("Audit retention: %v", err)
+			} else if n > 0 {
+				logger.InfoF("Audit retention: deleted %d entries older than %d days", n, config.AuditRetentionDays)
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuditLogHandler_requiresToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit?action=trash", nil)
+	rr := httptest.NewRecorder()
+	AuditLogHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:51234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if got := clientIP(req); got != "2001:db8::1" {
+		t.Errorf("clientIP() = %q, want %q", got, "2001:db8::1")
+	}
+}
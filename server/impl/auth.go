@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/passwords"
 	"github.com/takecontrolsoft/sync_server/server/store"
 	"github.com/takecontrolsoft/sync_server/server/utils"
 )
@@ -36,12 +37,15 @@ type loginResponse struct {
 }
 
 type registerRequest struct {
-	User     string `json:"User"`
-	Password string `json:"Password"`
+	User       string `json:"User"`
+	Password   string `json:"Password"`
+	InviteCode string `json:"InviteCode"`
 }
 
 // LoginHandler validates user/password and returns a session token.
 // POST body: { "User": "", "Password": "" } -> { "Token": "" } or 401.
+// If the user has two-factor authentication (or it is required for everyone), the response is
+// { "TwoFactorRequired": true, "Challenge": "" } and the token is issued by /auth/login/2fa.
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -57,6 +61,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !store.VerifyUser(req.User, req.Password) {
+		audit(r, store.GetUserIdByEmail(req.User), "", AuditLogin, nil, auditDenied, req.User)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -65,17 +70,26 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if enrolled := store.HasTOTPEnabled(userId); enrolled || require2FA() {
+		audit(r, userId, "", AuditLogin, nil, auditOK, "second factor required")
+		renderTwoFactorChallenge(w, userId, !enrolled)
+		return
+	}
 	token, err := store.CreateToken(userId)
 	if err != nil {
 		utils.RenderError(w, err, http.StatusInternalServerError)
 		return
 	}
+	audit(r, userId, "", AuditLogin, nil, auditOK, "")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(loginResponse{Token: token, UserId: userId})
 }
 
-// RegisterHandler creates a user. POST body: { "User": "", "Password": "" }.
+// RegisterHandler creates a user. POST body: { "User": "", "Password": "", "InviteCode": "" }.
+// Depending on the registration mode, registration is open, requires an unused invite code
+// or is disabled (403). Returns 400 if the password violates the password policy (minimum
+// length, breached list) and 409 if the username is already taken.
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -90,11 +104,45 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	userId, err := store.CreateUser(req.User, req.Password)
+	inviteCode := strings.TrimSpace(req.InviteCode)
+	switch registrationMode() {
+	case config.RegistrationDisabled:
+		audit(r, "", "", AuditRegister, nil, auditDenied, req.User+": registration disabled")
+		utils.RenderError(w, RegistrationClosed, http.StatusForbidden)
+		return
+	case config.RegistrationInvite:
+		if inviteCode == "" {
+			audit(r, "", "", AuditRegister, nil, auditDenied, req.User+": invite code required")
+			utils.RenderError(w, InviteCodeRequired, http.StatusForbidden)
+			return
+		}
+	default:
+		// Open registration ignores invite codes so they are not consumed needlessly.
+		inviteCode = ""
+	}
+	if err := passwords.CheckPolicy(req.Password); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	userId, err := store.RegisterUser(req.User, req.Password, inviteCode)
+	if err == store.ErrUserExists {
+		utils.RenderError(w, err, http.StatusConflict)
+		return
+	}
+	if err == store.ErrInvalidInvite {
+		audit(r, "", "", AuditRegister, nil, auditDenied, req.User+": invalid invite code")
+		utils.RenderError(w, err, http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		utils.RenderError(w, err, http.StatusInternalServerError)
 		return
 	}
+	audit(r, userId, "", AuditRegister, nil, auditOK, req.User)
+	if require2FA() {
+		renderTwoFactorChallenge(w, userId, !store.HasTOTPEnabled(userId))
+		return
+	}
 	token, _ := store.CreateToken(userId)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -102,19 +150,119 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // ResolveToUserId returns the folder name used for storage path (UploadDirectory/<this>/deviceId).
-// Always returns normalized (lowercase) email so the main folder is the user's email.
-// When auth DB is set: User in requests can be username (email) or userId; we resolve to email for path.
-// When auth DB is not set: returns lowercase user as-is.
+// When auth DB is set, user in requests can be the username (email) or the userId; both resolve to
+// the userId, so the folder does not change when the user's email changes. Folders created before
+// that are named after the lowercased email: until MigrateStorageFolders has moved them, the legacy
+// folder is returned if it exists and the id folder does not.
+// When auth DB is not set (or the user is unknown): returns lowercase user as-is.
 func ResolveToUserId(user string) string {
 	if user == "" {
 		return ""
 	}
-	if config.AuthDBPath != "" && store.UserIdExists(user) {
-		email := store.GetUsernameByUserId(user)
-		if email != "" {
-			return strings.ToLower(email)
+	if config.AuthDBPath != "" {
+		userId := user
+		if !store.UserIdExists(userId) {
+			userId = store.GetUserIdByEmail(user)
+		}
+		if userId != "" {
+			return storageFolder(userId, store.GetUsernameByUserId(userId))
 		}
 	}
 	return strings.ToLower(user)
 }
 
+// SessionUserId returns the user id of the session token sent as "Authorization: Bearer <token>",
+// or empty string if the request has no valid token.
+func SessionUserId(r *http.Request) string {
+	h := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return ""
+	}
+	return store.ValidateToken(strings.TrimSpace(h[7:]))
+}
+
+// IsAdmin returns true if userId belongs to the admin account (SYNC_ADMIN_USER).
+func IsAdmin(userId string) bool {
+	if userId == "" || config.AdminUser == "" {
+		return false
+	}
+	return strings.EqualFold(store.GetUsernameByUserId(userId), config.AdminUser)
+}
+
+// authorizeUserAccess checks that the request may act on the files of user. When auth is enabled
+// it requires a session token of that user or of the admin, and writes 401/403 otherwise.
+// Returns the session user id (empty when auth is disabled).
+func authorizeUserAccess(w http.ResponseWriter, r *http.Request, user string) (string, bool) {
+	if config.AuthDBPath == "" {
+		return "", true
+	}
+	sessionUser := SessionUserId(r)
+	if sessionUser == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return "", false
+	}
+	if ResolveToUserId(user) != ResolveToUserId(sessionUser) && !IsAdmin(sessionUser) {
+		w.WriteHeader(http.StatusForbidden)
+		return "", false
+	}
+	return sessionUser, true
+}
+
+// userRequest is a JSON request body that acts on the files of a user.
+type userRequest interface {
+	requestUser() string
+}
+
+// decodeUserRequest decodes the POST body into req and checks with authorizeUserAccess that the
+// caller may act on the files of its user. Needs the auth DB. Returns the user id of that user;
+// on failure the error response is written.
+func decodeUserRequest(w http.ResponseWriter, r *http.Request, req userRequest) (string, bool) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return "", false
+	}
+	if !requireAuthDB(w) {
+		return "", false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return "", false
+	}
+	user := req.requestUser()
+	if user == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", false
+	}
+	if _, ok := authorizeUserAccess(w, r, user); !ok {
+		return "", false
+	}
+	return canonicalUserId(user), true
+}
+
+// canonicalUserId returns the user id for a user id or username, so stored references survive
+// email changes. Returns user unchanged when auth is disabled or the user is unknown.
+func canonicalUserId(user string) string {
+	if config.AuthDBPath != "" {
+		if store.UserIdExists(user) {
+			return user
+		}
+		if id := store.GetUserIdByEmail(user); id != "" {
+			return id
+		}
+	}
+	return user
+}
+
+// requireAdmin writes 401/403 and returns false unless the request carries an admin session token.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return "", false
+	}
+	if !IsAdmin(userId) {
+		w.WriteHeader(http.StatusForbidden)
+		return "", false
+	}
+	return userId, true
+}
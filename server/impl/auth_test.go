@@ -0,0 +1,83 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// openTestAuthDB opens an empty auth DB for the test and closes it when the test ends.
+func openTestAuthDB(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.db")
+	restore := config.AuthDBPath
+	config.AuthDBPath = path
+	if err := store.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+		config.AuthDBPath = restore
+	})
+}
+
+// createTestUser adds a user to the test auth DB and returns its id and a session token.
+func createTestUser(t *testing.T, username string) (string, string) {
+	t.Helper()
+	userId, err := store.CreateUser(username, "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := store.CreateToken(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userId, token
+}
+
+func TestRegisterHandler_registrationMode(t *testing.T) {
+	restore := config.RegistrationMode
+	defer func() { config.RegistrationMode = restore }()
+	tests := []struct {
+		name string
+		mode string
+		body string
+		want int
+	}{
+		{"disabled", config.RegistrationDisabled, `{"User":"a@example.com","Password":"pw"}`, http.StatusForbidden},
+		{"invite without code", config.RegistrationInvite, `{"User":"a@example.com","Password":"pw"}`, http.StatusForbidden},
+		{"missing password", config.RegistrationOpen, `{"User":"a@example.com","Password":""}`, http.StatusBadRequest},
+		{"short password", config.RegistrationOpen, `{"User":"a@example.com","Password":"pw"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.RegistrationMode = tt.mode
+			req := httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			RegisterHandler(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("got status %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Page sizes of /changes.
+const (
+	defaultChangesLimit = 500
+	maxChangesLimit     = 5000
+)
+
+type changesRequest struct {
+	UserData userData `json:"UserData"`
+	// Cursor is the Cursor of the previous response (0 = from the start of the log).
+	Cursor int64 `json:"Cursor"`
+	Limit  int   `json:"Limit"`
+}
+
+func (req *changesRequest) requestUser() string { return req.UserData.User }
+
+type changesResponse struct {
+	Changes []store.Change `json:"Changes"`
+	// Cursor is the Seq of the last returned change (the request cursor if there are none).
+	Cursor int64 `json:"Cursor"`
+	// Latest is the Seq of the newest change of the user.
+	Latest  int64 `json:"Latest"`
+	HasMore bool  `json:"HasMore"`
+	// Expired is true if changes of the user after Cursor were already deleted by the retention:
+	// the client must list its files again and then follow the log from Latest.
+	Expired bool `json:"Expired"`
+}
+
+// GetChangesHandler returns the changes of the user's files after Cursor, oldest first: uploads
+// ("add"), moves to and from Trash ("trash", "restore" with NewPath), new metadata or thumbnails
+// ("metadata"), annotation updates ("annotations") and files deleted outside the server ("delete").
+// With a DeviceId only that device's changes are returned. A new client lists the files once and
+// then follows the log from Latest. Changes are kept for config.ChangesRetentionDays.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Cursor": 0, "Limit": 500 }
+// -> { "Changes": [ { "Seq": 1, "DeviceId": "", "Kind": "add", "Path": "", "NewPath": "", "At": 0 } ], "Cursor": 1, "Latest": 1,
+// "HasMore": false, "Expired": false }
+func GetChangesHandler(w http.ResponseWriter, r *http.Request) {
+	var req changesRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultChangesLimit
+	}
+	limit = min(limit, maxChangesLimit)
+	changes, err := store.ListChanges(userId, strings.TrimSpace(req.UserData.DeviceId), req.Cursor, limit+1)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	latest, err := store.LatestChangeSeq(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	pruned, err := store.PrunedChangeSeq(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	resp := changesResponse{Changes: changes, Cursor: req.Cursor, Latest: latest, Expired: req.Cursor < pruned}
+	if len(changes) > limit {
+		resp.Changes, resp.HasMore = changes[:limit], true
+	}
+	if n := len(resp.Changes); n > 0 {
+		resp.Cursor = resp.Changes[n-1].Seq
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// StartChangesRetention deletes change log entries older than config.ChangesRetentionDays,
+// now and then once a day. Does nothing if the retention is 0 (keep forever).
+func StartChangesRetention() {
+	if config.ChangesRetentionDays <= 0 {
+		return
+	}
+	go func() {
+		for {
+			before := time.Now().AddDate(0, 0, -config.ChangesRetentionDays)
+			if n, err := store.PruneChanges(before); err != nil {
+				logger.ErrorF("Change log retention: %v", err)
+			} else if n > 0 {
+				logger.InfoF("Change log retention: deleted %d changes older than %d days", n, config.ChangesRetentionDays)
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
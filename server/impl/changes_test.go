@@ -0,0 +1,194 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestFileChangesAreRecorded(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, token := createTestUser(t, "alice@example.com")
+
+	var body bytes.Buffer
+	m := multipart.NewWriter(&body)
+	part, err := m.CreateFormFile("phone", "image.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write(fakeFileBytes("image.jpeg"))
+	m.Close()
+	user, _ := json.Marshal([]byte("alice@example.com"))
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", m.FormDataContentType())
+	r.Header.Set("user", string(user))
+	r.Header.Set("date", "2024-05-03")
+	rr := httptest.NewRecorder()
+	UploadHandler(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", rr.Code, rr.Body)
+	}
+	file := "2024/05/image.jpeg"
+
+	post := func(handler http.HandlerFunc, body string) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%d %s", rr.Code, rr.Body)
+		}
+	}
+	post(MoveToTrashHandler, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["`+file+`"]}`)
+	post(RestoreHandler, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["Trash/`+file+`"]}`)
+	deviceDir := filepath.Join(tmp, userId, "phone")
+	if _, _, err := indexDeviceDir(deviceDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(deviceDir, filepath.FromSlash(file))); err != nil {
+		t.Fatal(err)
+	}
+	if _, removed, err := indexDeviceDir(deviceDir); err != nil || removed != 1 {
+		t.Fatalf("removed = %d, %v", removed, err)
+	}
+
+	changes, err := store.ListChanges(userId, "phone", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		// Metadata is extracted in the background; its changes are not checked here.
+		if c.Kind != store.ChangeMetadata {
+			got = append(got, c.Kind+" "+c.Path+" "+c.NewPath)
+		}
+	}
+	want := []string{
+		"add " + file + " ",
+		"trash " + file + " Trash/" + file,
+		"restore Trash/" + file + " " + file,
+		"delete " + file + " ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// getChanges posts a /changes request for user with the session token.
+func getChanges(t *testing.T, token, user string, cursor int64, limit int) changesResponse {
+	t.Helper()
+	body, _ := json.Marshal(changesRequest{UserData: userData{User: user}, Cursor: cursor, Limit: limit})
+	r := httptest.NewRequest(http.MethodPost, "/changes", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	GetChangesHandler(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%d %s", rr.Code, rr.Body)
+	}
+	var resp changesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestGetChangesHandler(t *testing.T) {
+	openTestAuthDB(t)
+	userId, token := createTestUser(t, "alice@example.com")
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	for i, path := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		c := store.Change{UserId: userId, DeviceId: "phone", Kind: store.ChangeAdd, Path: path}
+		if i == 0 {
+			c.At = old
+		}
+		if err := store.RecordChange(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := getChanges(t, token, "alice@example.com", 0, 2)
+	if len(first.Changes) != 2 || !first.HasMore || first.Cursor != first.Changes[1].Seq || first.Expired {
+		t.Fatalf("first page = %+v", first)
+	}
+	last := getChanges(t, token, "alice@example.com", first.Cursor, 2)
+	if len(last.Changes) != 1 || last.HasMore || last.Cursor != last.Latest || last.Changes[0].Path != "c.jpg" {
+		t.Fatalf("last page = %+v", last)
+	}
+
+	if _, err := store.PruneChanges(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if resp := getChanges(t, token, "alice@example.com", 0, 2); !resp.Expired {
+		t.Fatalf("cursor 0 after pruning = %+v; want Expired", resp)
+	}
+	if resp := getChanges(t, token, "alice@example.com", first.Changes[0].Seq, 2); resp.Expired || len(resp.Changes) != 2 {
+		t.Fatalf("cursor after pruned seq = %+v", resp)
+	}
+}
+
+func TestGetChangesHandlerExpiredPerUser(t *testing.T) {
+	openTestAuthDB(t)
+	aliceId, aliceToken := createTestUser(t, "alice@example.com")
+	bobId, bobToken := createTestUser(t, "bob@example.com")
+	_, carolToken := createTestUser(t, "carol@example.com")
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	for _, c := range []store.Change{
+		{UserId: bobId, DeviceId: "phone", Kind: store.ChangeAdd, Path: "b.jpg", At: old},
+		{UserId: aliceId, DeviceId: "phone", Kind: store.ChangeAdd, Path: "a.jpg", At: old},
+		{UserId: aliceId, DeviceId: "phone", Kind: store.ChangeAdd, Path: "a2.jpg", At: old},
+	} {
+		if err := store.RecordChange(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bobLatest := getChanges(t, bobToken, "bob@example.com", 0, 10).Latest
+	if _, err := store.PruneChanges(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Alice's changes after Bob's cursor were pruned; Bob's own changes were all seen.
+	if resp := getChanges(t, bobToken, "bob@example.com", bobLatest, 10); resp.Expired {
+		t.Fatalf("bob = %+v; want not Expired", resp)
+	}
+	// A user without changes is never expired.
+	if resp := getChanges(t, carolToken, "carol@example.com", 0, 10); resp.Expired || resp.Latest != 0 {
+		t.Fatalf("carol = %+v; want not Expired", resp)
+	}
+	// Alice lists her files again and resumes from Latest, which survives the pruning.
+	resp := getChanges(t, aliceToken, "alice@example.com", 0, 10)
+	if !resp.Expired || resp.Latest == 0 {
+		t.Fatalf("alice from 0 = %+v; want Expired with Latest", resp)
+	}
+	if resp := getChanges(t, aliceToken, "alice@example.com", resp.Latest, 10); resp.Expired {
+		t.Fatalf("alice from Latest = %+v; want not Expired", resp)
+	}
+}
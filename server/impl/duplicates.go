@@ -0,0 +1,444 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/bits"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Duplicate kinds.
+const (
+	// duplicateExact files have the same content.
+	duplicateExact = "exact"
+	// duplicateSimilar files look alike: re-saved, resized or lightly edited copies.
+	duplicateSimilar = "similar"
+)
+
+// Near-duplicate search.
+const (
+	// nearDuplicateDistance is the most bits in which the perceptual hashes of similar images differ.
+	nearDuplicateDistance = 5
+	// phashBands splits hashes into more bands than nearDuplicateDistance, so similar images
+	// share at least one band and only images in the same band bucket are compared.
+	phashBands = nearDuplicateDistance + 1
+)
+
+// Page sizes of /duplicates.
+const (
+	defaultDuplicatesLimit = 100
+	maxDuplicatesLimit     = 1000
+)
+
+// perceptualHash returns the difference hash of img: 64 bits telling whether each pixel of a 9x8
+// grayscale version is brighter than its right neighbor. Copies that were re-encoded, resized or
+// lightly edited differ in a few bits.
+func perceptualHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[y*small.Stride+x*4]
+			right := small.Pix[y*small.Stride+(x+1)*4]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// thumbnailPHash returns the hex perceptual hash of the thumbnail of the image rel in deviceDir,
+// or store.PHashFailed if it cannot be decoded.
+func thumbnailPHash(deviceDir, rel string) string {
+	img, err := imaging.Open(ThumbnailBasePath(deviceDir, rel))
+	if err != nil {
+		logger.ErrorF("Perceptual hash of %s: %v", rel, err)
+		return store.PHashFailed
+	}
+	return fmt.Sprintf("%016x", perceptualHash(img))
+}
+
+// backfillPHashes computes the perceptual hashes of images indexed before they were computed.
+func backfillPHashes() {
+	total := 0
+	for {
+		entries, err := store.MediaWithoutPHash(200)
+		if err != nil {
+			logger.ErrorF("Perceptual hashes: %v", err)
+			return
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, e := range entries {
+			deviceDir := filepath.Join(config.UploadDirectory, ResolveToUserId(e.UserId), e.DeviceId)
+			if err := store.SetMediaPHash(e.UserId, e.DeviceId, e.Path, thumbnailPHash(deviceDir, e.Path)); err != nil {
+				logger.ErrorF("Perceptual hashes: %v", err)
+				return
+			}
+		}
+		total += len(entries)
+	}
+	if total > 0 {
+		logger.InfoF("Perceptual hashes: computed %d", total)
+	}
+}
+
+// DuplicateFile is a file of a duplicate group.
+type DuplicateFile struct {
+	FileInfo
+	// Make and Model are the camera of the original EXIF; re-saved copies usually lost them.
+	Make  string `json:"Make"`
+	Model string `json:"Model"`
+}
+
+// DuplicateGroup is a set of files with the same or similar content.
+type DuplicateGroup struct {
+	// Id is the first path of the group; it stays the same while the group does.
+	Id   string `json:"Id"`
+	Kind string `json:"Kind"`
+	// Keep is the suggested file to keep.
+	Keep  string          `json:"Keep"`
+	Files []DuplicateFile `json:"Files"`
+}
+
+// keeperLess returns true if a is a better file to keep than b: higher resolution, then original
+// EXIF, then larger, then captured earlier.
+func keeperLess(a, b DuplicateFile) bool {
+	if pa, pb := a.Width*a.Height, b.Width*b.Height; pa != pb {
+		return pa > pb
+	}
+	if ea, eb := a.Make != "" || a.Model != "", b.Make != "" || b.Model != ""; ea != eb {
+		return ea
+	}
+	if a.Size != b.Size {
+		return a.Size > b.Size
+	}
+	if a.CapturedAt != b.CapturedAt {
+		return a.CapturedAt < b.CapturedAt
+	}
+	return a.Path < b.Path
+}
+
+// groupDuplicates groups entries with the same content hash or with perceptual hashes at most
+// nearDuplicateDistance bits apart. Every file of a group is a copy of its keeper or similar to it,
+// so chains of images that are each similar to the next do not end up in one group. Paths are as
+// /files lists them for deviceId. Groups are ordered by Id, their files best keeper first.
+func groupDuplicates(entries []store.MediaEntry, deviceId string) []DuplicateGroup {
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) { parent[find(a)] = find(b) }
+
+	byHash := make(map[string]int)
+	type bucket struct {
+		band  int
+		value uint64
+	}
+	buckets := make(map[bucket][]int)
+	phashes := make([]uint64, len(entries))
+	hasPHash := make([]bool, len(entries))
+	for i, e := range entries {
+		if e.Hash != "" {
+			if j, ok := byHash[e.Hash]; ok {
+				union(i, j)
+			} else {
+				byHash[e.Hash] = i
+			}
+		}
+		phash, err := strconv.ParseUint(e.PHash, 16, 64)
+		if err != nil {
+			continue
+		}
+		phashes[i], hasPHash[i] = phash, true
+		for band := 0; band < phashBands; band++ {
+			from, to := band*64/phashBands, (band+1)*64/phashBands
+			b := bucket{band, phash << from >> (64 - (to - from))}
+			buckets[b] = append(buckets[b], i)
+		}
+	}
+	for _, members := range buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				i, j := members[x], members[y]
+				if find(i) != find(j) && bits.OnesCount64(phashes[i]^phashes[j]) <= nearDuplicateDistance {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range entries {
+		members[find(i)] = append(members[find(i)], i)
+	}
+	groups := make([]DuplicateGroup, 0)
+	for _, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		files := make(map[int]DuplicateFile, len(indexes))
+		for _, i := range indexes {
+			e := entries[i]
+			p := fileRefPath(deviceId, store.FileRef{DeviceId: e.DeviceId, Path: e.Path})
+			files[i] = DuplicateFile{FileInfo: entryFileInfo(e, p), Make: e.Make, Model: e.Model}
+		}
+		sort.Slice(indexes, func(a, b int) bool { return keeperLess(files[indexes[a]], files[indexes[b]]) })
+		// Split the files around keepers, best first: a file joins the cluster holding a copy of
+		// it, else the first cluster whose keeper it is similar to, else starts a new cluster.
+		var clusters [][]int
+		clusterOfHash := make(map[string]int)
+		for _, i := range indexes {
+			c, ok := clusterOfHash[entries[i].Hash]
+			if !ok || entries[i].Hash == "" {
+				c = -1
+				for k, cluster := range clusters {
+					keeper := cluster[0]
+					if hasPHash[i] && hasPHash[keeper] && bits.OnesCount64(phashes[i]^phashes[keeper]) <= nearDuplicateDistance {
+						c = k
+						break
+					}
+				}
+				if c < 0 {
+					c = len(clusters)
+					clusters = append(clusters, nil)
+				}
+				if entries[i].Hash != "" {
+					clusterOfHash[entries[i].Hash] = c
+				}
+			}
+			clusters[c] = append(clusters[c], i)
+		}
+		for _, cluster := range clusters {
+			if len(cluster) < 2 {
+				continue
+			}
+			group := DuplicateGroup{Kind: duplicateExact, Files: make([]DuplicateFile, 0, len(cluster))}
+			for _, i := range cluster {
+				f := files[i]
+				group.Files = append(group.Files, f)
+				if entries[i].Hash == "" || entries[i].Hash != entries[cluster[0]].Hash {
+					group.Kind = duplicateSimilar
+				}
+				if group.Id == "" || f.Path < group.Id {
+					group.Id = f.Path
+				}
+			}
+			group.Keep = group.Files[0].Path
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Id < groups[j].Id })
+	return groups
+}
+
+// userDuplicates returns the duplicate groups of the files outside Trash on deviceId ("" = all
+// devices) of userId.
+func userDuplicates(userId, deviceId string) ([]DuplicateGroup, error) {
+	entries, err := store.SearchMedia(userId, store.MediaQuery{DeviceId: deviceId})
+	if err != nil {
+		return nil, err
+	}
+	return groupDuplicates(entries, deviceId), nil
+}
+
+type duplicatesRequest struct {
+	UserData userData `json:"UserData"`
+	// Kind lists only "exact" or "similar" groups ("" = both).
+	Kind string `json:"Kind"`
+	// Limit is the page size in groups (default 100, at most 1000).
+	Limit int `json:"Limit"`
+	// Cursor is the NextCursor of the previous page ("" = first page).
+	Cursor string `json:"Cursor"`
+}
+
+func (req *duplicatesRequest) requestUser() string { return req.UserData.User }
+
+type duplicatesResponse struct {
+	Groups     []DuplicateGroup `json:"Groups"`
+	NextCursor string           `json:"NextCursor"`
+}
+
+// DuplicatesHandler lists groups of the user's files outside Trash that are exact duplicates (same
+// content) or similar images (same perceptual hash give or take a few bits), with the suggested
+// file to keep: the highest resolution, then one with the original EXIF. Files of all devices are
+// compared unless DeviceId is set; paths are as /files lists them.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Kind": "exact" | "similar" | "", "Limit": 100, "Cursor": "" }
+// -> { "Groups": [ { "Id": "", "Kind": "exact", "Keep": "", "Files": [ { ...as /files/list..., "Make": "", "Model": "" } ] } ],
+// "NextCursor": "" }
+func DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	var req duplicatesRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	if req.Kind != "" && req.Kind != duplicateExact && req.Kind != duplicateSimilar {
+		utils.RenderError(w, InvalidListOption("Kind", req.Kind), http.StatusBadRequest)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultDuplicatesLimit
+	}
+	req.Limit = min(req.Limit, maxDuplicatesLimit)
+	groups, err := userDuplicates(userId, strings.TrimSpace(req.UserData.DeviceId))
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	resp := duplicatesResponse{Groups: make([]DuplicateGroup, 0)}
+	for _, g := range groups {
+		if g.Id <= req.Cursor || (req.Kind != "" && g.Kind != req.Kind) {
+			continue
+		}
+		if len(resp.Groups) == req.Limit {
+			resp.NextCursor = resp.Groups[len(resp.Groups)-1].Id
+			break
+		}
+		resp.Groups = append(resp.Groups, g)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// duplicateResolution keeps one file of a group and trashes the others.
+type duplicateResolution struct {
+	Id string `json:"Id"`
+	// Keep is the path of the file to keep ("" = the suggested one).
+	Keep string `json:"Keep"`
+	// Files are the paths of the group's files the user reviewed, as /duplicates listed them.
+	Files []string `json:"Files"`
+}
+
+// sameFiles returns whether the group has exactly the files paths.
+func (g DuplicateGroup) sameFiles(paths []string) bool {
+	reviewed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		reviewed[p] = true
+	}
+	if len(reviewed) != len(g.Files) {
+		return false
+	}
+	for _, f := range g.Files {
+		if !reviewed[f.Path] {
+			return false
+		}
+	}
+	return true
+}
+
+type resolveDuplicatesRequest struct {
+	UserData userData              `json:"UserData"`
+	Groups   []duplicateResolution `json:"Groups"`
+}
+
+func (req *resolveDuplicatesRequest) requestUser() string { return req.UserData.User }
+
+type resolveDuplicatesResponse struct {
+	// Trashed are the paths moved to Trash.
+	Trashed []string `json:"Trashed"`
+	// Skipped are the ids of groups that no longer exist, do not contain Keep or whose files
+	// changed since they were reviewed.
+	Skipped []string `json:"Skipped"`
+}
+
+// ResolveDuplicatesHandler moves all files of each group but the one to keep to Trash, with their
+// thumbnails and metadata. Each group must list the Files the user reviewed; groups are built again
+// and one whose files are no longer exactly those is skipped, so files that joined a group since it
+// was listed are not trashed by accident. DeviceId must be the one the groups were listed with.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Groups": [ { "Id": "", "Keep": "", "Files": [] } ] }
+// -> { "Trashed": [], "Skipped": [] }
+func ResolveDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	var req resolveDuplicatesRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	for _, res := range req.Groups {
+		if len(res.Files) == 0 {
+			utils.RenderError(w, DuplicateFilesRequired(res.Id), http.StatusBadRequest)
+			return
+		}
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	groups, err := userDuplicates(userId, deviceId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	byId := make(map[string]DuplicateGroup, len(groups))
+	for _, g := range groups {
+		byId[g.Id] = g
+	}
+	resp := resolveDuplicatesResponse{Trashed: make([]string, 0), Skipped: make([]string, 0)}
+	// Trashed paths by device, for the audit log.
+	trashed := make(map[string][]string)
+	for _, res := range req.Groups {
+		g, found := byId[res.Id]
+		keep := res.Keep
+		if keep == "" {
+			keep = g.Keep
+		}
+		isMember := false
+		for _, f := range g.Files {
+			isMember = isMember || f.Path == keep
+		}
+		if !found || !isMember || !g.sameFiles(res.Files) {
+			resp.Skipped = append(resp.Skipped, res.Id)
+			continue
+		}
+		for _, f := range g.Files {
+			if f.Path == keep {
+				continue
+			}
+			dir, rel, ok := listedFileDir(userId, deviceId, f.Path)
+			if ok && MoveRelativePathToTrash(dir, rel) {
+				resp.Trashed = append(resp.Trashed, f.Path)
+				trashed[filepath.Base(dir)] = append(trashed[filepath.Base(dir)], rel)
+			}
+		}
+		delete(byId, res.Id)
+	}
+	for device, paths := range trashed {
+		audit(r, userId, device, AuditTrash, paths, auditOK, "duplicates")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
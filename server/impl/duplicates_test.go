@@ -0,0 +1,168 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestPerceptualHash(t *testing.T) {
+	photo := image.NewNRGBA(image.Rect(0, 0, 360, 240))
+	other := image.NewNRGBA(image.Rect(0, 0, 360, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 360; x++ {
+			photo.Set(x, y, color.Gray{uint8((x/40 + y/30) % 2 * 200)})
+			other.Set(x, y, color.Gray{uint8(255 - x*255/360)})
+		}
+	}
+	hash := perceptualHash(photo)
+	if d := bits.OnesCount64(hash ^ perceptualHash(imaging.Resize(photo, 120, 80, imaging.Lanczos))); d > nearDuplicateDistance {
+		t.Errorf("resized copy differs in %d bits", d)
+	}
+	if d := bits.OnesCount64(hash ^ perceptualHash(other)); d <= nearDuplicateDistance {
+		t.Errorf("different image differs in only %d bits", d)
+	}
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	entries := []store.MediaEntry{
+		{DeviceId: "phone", Path: "2024/07/a.jpg", Hash: "h1", PHash: "f0f0f0f0f0f0f0f0", Width: 4000, Height: 3000, Make: "Pixel"},
+		{DeviceId: "tablet", Path: "2024/07/a.jpg", Hash: "h1", PHash: "f0f0f0f0f0f0f0f0", Width: 4000, Height: 3000},
+		// A WhatsApp re-save: smaller, without EXIF, three bits off.
+		{DeviceId: "phone", Path: "2024/07/IMG-WA0001.jpg", Hash: "h2", PHash: "f0f0f0f0f0f0f0f7", Width: 1600, Height: 1200},
+		{DeviceId: "phone", Path: "2024/07/b.mp4", Hash: "h3"},
+		{DeviceId: "tablet", Path: "2024/08/b.mp4", Hash: "h3"},
+		{DeviceId: "phone", Path: "2024/07/c.jpg", Hash: "h4", PHash: "0f0f0f0f0f0f0f0f"},
+		{DeviceId: "phone", Path: "2024/07/d.jpg", Hash: "h5", PHash: store.PHashFailed},
+	}
+	groups := groupDuplicates(entries, "")
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	similar, exact := groups[0], groups[1]
+	if similar.Id != "phone/2024/07/IMG-WA0001.jpg" || similar.Kind != duplicateSimilar ||
+		similar.Keep != "phone/2024/07/a.jpg" || len(similar.Files) != 3 {
+		t.Fatalf("similar = %+v", similar)
+	}
+	if similar.Files[2].Path != "phone/2024/07/IMG-WA0001.jpg" {
+		t.Fatalf("re-save is not last: %+v", similar.Files)
+	}
+	if exact.Id != "phone/2024/07/b.mp4" || exact.Kind != duplicateExact || len(exact.Files) != 2 {
+		t.Fatalf("exact = %+v", exact)
+	}
+	if groups := groupDuplicates(entries[:2], "phone"); len(groups) != 1 || groups[0].Id != "2024/07/a.jpg" {
+		t.Fatalf("one device = %+v", groups)
+	}
+}
+
+func TestGroupDuplicatesChain(t *testing.T) {
+	// b is 3 bits from a and c 3 bits from b, but c is 6 bits from a.
+	entries := []store.MediaEntry{
+		{DeviceId: "phone", Path: "2024/07/a.jpg", Hash: "h1", PHash: "f0f0f0f0f0f0f0f0", Width: 4000, Height: 3000},
+		{DeviceId: "phone", Path: "2024/07/b.jpg", Hash: "h2", PHash: "f0f0f0f0f0f0f0f7", Width: 1600, Height: 1200},
+		{DeviceId: "phone", Path: "2024/07/c.jpg", Hash: "h3", PHash: "f0f0f0f0f0f0f7f7", Width: 800, Height: 600},
+		{DeviceId: "tablet", Path: "2024/07/c.jpg", Hash: "h3", PHash: "f0f0f0f0f0f0f7f7", Width: 800, Height: 600},
+	}
+	groups := groupDuplicates(entries, "")
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	similar, exact := groups[0], groups[1]
+	if similar.Kind != duplicateSimilar || similar.Keep != "phone/2024/07/a.jpg" || len(similar.Files) != 2 ||
+		similar.Files[1].Path != "phone/2024/07/b.jpg" {
+		t.Fatalf("similar = %+v", similar)
+	}
+	if exact.Kind != duplicateExact || exact.Id != "phone/2024/07/c.jpg" || len(exact.Files) != 2 {
+		t.Fatalf("exact = %+v", exact)
+	}
+	if groups := groupDuplicates(entries[:3], ""); len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("chain without copies = %+v", groups)
+	}
+}
+
+func TestResolveDuplicatesHandler(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, token := createTestUser(t, "alice@example.com")
+	// Files not migrated yet are still in the email folder.
+	deviceDir := filepath.Join(tmp, "alice@example.com", "phone")
+	writeCopy := func(rel string) {
+		t.Helper()
+		p := filepath.Join(deviceDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("same content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCopy("2024/07/a.jpg")
+	writeCopy("2024/07/b.jpg")
+	ReconcileCatalog()
+
+	resolve := func(files string) (int, resolveDuplicatesResponse) {
+		t.Helper()
+		body := `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Groups":[{"Id":"2024/07/a.jpg","Keep":"2024/07/a.jpg"` +
+			files + `}]}`
+		r := httptest.NewRequest(http.MethodPost, "/duplicates/resolve", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		ResolveDuplicatesHandler(rr, r)
+		var resp resolveDuplicatesResponse
+		_ = json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+	if code, _ := resolve(""); code != http.StatusBadRequest {
+		t.Fatalf("without Files: %d; want 400", code)
+	}
+	// A third copy joined the group after it was reviewed.
+	writeCopy("2024/08/c.jpg")
+	ReconcileCatalog()
+	if code, resp := resolve(`,"Files":["2024/07/a.jpg","2024/07/b.jpg"]`); code != http.StatusOK ||
+		len(resp.Skipped) != 1 || len(resp.Trashed) != 0 {
+		t.Fatalf("changed group: %d %+v", code, resp)
+	}
+	if _, err := os.Stat(filepath.Join(deviceDir, "2024", "08", "c.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	code, resp := resolve(`,"Files":["2024/07/a.jpg","2024/07/b.jpg","2024/08/c.jpg"]`)
+	if code != http.StatusOK || len(resp.Skipped) != 0 || len(resp.Trashed) != 2 {
+		t.Fatalf("reviewed group: %d %+v", code, resp)
+	}
+	entries, err := store.QueryAudit(store.AuditFilter{Action: AuditTrash, Limit: 10})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("audit = %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.UserId != userId || e.Actor != userId || e.Device != "phone" || len(e.Paths) != 2 {
+		t.Fatalf("audit entry = %+v", e)
+	}
+}
@@ -0,0 +1,103 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// deviceDirOwner returns the user id and device id of a device folder UploadDirectory/<user>/<device>.
+func deviceDirOwner(deviceDir string) (string, string, bool) {
+	rel, err := filepath.Rel(config.UploadDirectory, deviceDir)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 2 || parts[0] == ".." || parts[0] == "." {
+		return "", "", false
+	}
+	return canonicalUserId(parts[0]), parts[1], true
+}
+
+// recordFileChange appends a change of the file path in the device folder deviceDir to the
+// change log of its user. newPath is the path after a move, else empty.
+func recordFileChange(deviceDir, kind, path, newPath string) {
+	if config.AuthDBPath == "" {
+		return
+	}
+	if userId, deviceId, ok := deviceDirOwner(deviceDir); ok {
+		recordChange(userId, deviceId, kind, path, newPath)
+	}
+}
+
+// recordChange appends a change of the file path on deviceId to the change log of userId.
+func recordChange(userId, deviceId, kind, path, newPath string) {
+	change := store.Change{UserId: userId, DeviceId: deviceId, Kind: kind,
+		Path: filepath.ToSlash(path), NewPath: filepath.ToSlash(newPath)}
+	if err := store.RecordChange(change); err != nil {
+		logger.ErrorF("Record %s of %s: %v", kind, path, err)
+	}
+}
+
+// onFileMoved is called after a media file (with its thumbnail and metadata) was moved within the
+// device folder deviceDir, e.g. to or from Trash. from and to are relative paths with forward slashes.
+// It keeps the references stored in the auth DB pointing at the file and records the move.
+func onFileMoved(deviceDir, from, to string) {
+	if config.AuthDBPath == "" {
+		return
+	}
+	userId, deviceId, ok := deviceDirOwner(deviceDir)
+	if !ok {
+		return
+	}
+	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
+	if err := store.MoveAlbumItems(userId, deviceId, from, to); err != nil {
+		logger.ErrorF("Update albums for %s -> %s: %v", from, to, err)
+	}
+	if err := store.MoveAnnotations(userId, deviceId, from, to); err != nil {
+		logger.ErrorF("Update annotations for %s -> %s: %v", from, to, err)
+	}
+	if err := store.MoveMedia(userId, deviceId, from, to); err != nil {
+		logger.ErrorF("Update media index for %s -> %s: %v", from, to, err)
+	}
+	kind := store.ChangeTrash
+	if strings.HasPrefix(from, trashPrefix) {
+		kind = store.ChangeRestore
+	}
+	recordChange(userId, deviceId, kind, from, to)
+}
+
+// onFileDeleted is called when the media file path of the device folder deviceDir no longer exists.
+// It drops the file from the media index and records the deletion.
+func onFileDeleted(deviceDir, path string) {
+	if config.AuthDBPath == "" {
+		return
+	}
+	userId, deviceId, ok := deviceDirOwner(deviceDir)
+	if !ok {
+		return
+	}
+	path = filepath.ToSlash(path)
+	if err := store.DeleteMedia(userId, deviceId, path); err != nil {
+		logger.ErrorF("Remove %s from media index: %v", path, err)
+	}
+	recordChange(userId, deviceId, store.ChangeDelete, path, "")
+}
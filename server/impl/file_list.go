@@ -0,0 +1,344 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Sort orders of /files/list.
+const (
+	sortCaptured = "captured"
+	sortName     = "name"
+	sortSize     = "size"
+)
+
+// Page sizes of /files/list.
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listOptions sort, filter by media type and page the results of /files/list and /search.
+type listOptions struct {
+	// Sort is "captured" (default), "name" or "size"; Desc reverses it.
+	Sort string `json:"Sort"`
+	Desc bool   `json:"Desc"`
+	// Type lists only "image", "video" or "audio" files ("" = all).
+	Type string `json:"Type"`
+	// Limit is the page size (default 100, at most 1000).
+	Limit int `json:"Limit"`
+	// Cursor is the NextCursor of the previous page ("" = first page).
+	Cursor string `json:"Cursor"`
+}
+
+// listFilesRequest is the body of /files/list: the /files request plus list options.
+type listFilesRequest struct {
+	folderData
+	listOptions
+}
+
+// FileInfo describes a listed file.
+type FileInfo struct {
+	// Path is the path as /files returns it.
+	Path string `json:"Path"`
+	Size int64  `json:"Size"`
+	// ModifiedAt is the modification time of the file (unix seconds).
+	ModifiedAt int64 `json:"ModifiedAt"`
+	// Type is "image", "video", "audio" or "".
+	Type     string  `json:"Type"`
+	MimeType string  `json:"MimeType"`
+	Width    int     `json:"Width"`
+	Height   int     `json:"Height"`
+	Duration float64 `json:"Duration"`
+	// CapturedAt is the capture time from the metadata, else ModifiedAt (unix seconds).
+	CapturedAt int64 `json:"CapturedAt"`
+	// Metadata and Thumbnail are true once the upload processing created them.
+	Metadata  bool `json:"Metadata"`
+	Thumbnail bool `json:"Thumbnail"`
+}
+
+type listFilesResponse struct {
+	Files []FileInfo `json:"Files"`
+	// NextCursor requests the next page; empty on the last page.
+	NextCursor string `json:"NextCursor"`
+}
+
+// listCursor is the position after the last file of a page. It is bound to the sort order, so a
+// page boundary stays stable when files are added or removed between requests.
+type listCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Key  int64  `json:"k,omitempty"`
+	Name string `json:"n,omitempty"`
+	Path string `json:"p"`
+}
+
+func encodeListCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(s string) (listCursor, bool) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return listCursor{}, false
+	}
+	return c, true
+}
+
+// cursorOf returns the cursor positioned at info.
+func cursorOf(sortBy string, desc bool, info FileInfo) listCursor {
+	c := listCursor{Sort: sortBy, Desc: desc, Path: info.Path}
+	switch sortBy {
+	case sortCaptured:
+		c.Key = info.CapturedAt
+	case sortSize:
+		c.Key = info.Size
+	case sortName:
+		c.Name = strings.ToLower(path.Base(info.Path))
+	}
+	return c
+}
+
+// cursorLess returns true if a sorts before b. Ties of the sort key are ordered by path.
+func cursorLess(a, b listCursor) bool {
+	if a.Desc {
+		a, b = b, a
+	}
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.Path < b.Path
+}
+
+// listedFileDir maps a path listed for user and deviceId ("" = all devices) to the device folder
+// that holds it and the path in that folder. Library paths map to the owner's storage.
+func listedFileDir(user, deviceId, file string) (string, string, bool) {
+	file = normalizeRequestPath(file)
+	folder := ResolveToUserId(user)
+	if folder == "" {
+		folder = user
+	}
+	if isLibraryPath(file) {
+		item, ok := parseLibraryPath(file)
+		if !ok {
+			return "", "", false
+		}
+		folder, deviceId, file = ResolveToUserId(item.OwnerId), item.DeviceId, item.Path
+	} else if deviceId == "" {
+		deviceId, file, _ = strings.Cut(file, "/")
+	}
+	if deviceId == "" || file == "" {
+		return "", "", false
+	}
+	return filepath.Join(config.UploadDirectory, folder, deviceId), file, true
+}
+
+// fileInfo returns the info of a listed file from the media catalog, else from the disk, or false
+// if it does not exist.
+func fileInfo(user, deviceId, file string) (FileInfo, bool) {
+	dir, rel, ok := listedFileDir(user, deviceId, file)
+	if !ok {
+		return FileInfo{}, false
+	}
+	info, ok := catalogFileInfo(dir, rel)
+	if !ok {
+		info, _, ok = mediaFileInfo(dir, rel)
+	}
+	info.Path = normalizeRequestPath(file)
+	return info, ok
+}
+
+// listUserFiles returns the info of all files outside Trash on deviceId ("" = all devices) of user,
+// with paths as /files lists them. It answers from the media catalog, else walks the disk.
+func listUserFiles(user, deviceId string) []FileInfo {
+	infos := make([]FileInfo, 0)
+	if useCatalog() {
+		entries, err := store.SearchMedia(canonicalUserId(user), store.MediaQuery{DeviceId: deviceId})
+		if err == nil {
+			for _, e := range entries {
+				infos = append(infos, entryFileInfo(e, fileRefPath(deviceId, store.FileRef{DeviceId: e.DeviceId, Path: e.Path})))
+			}
+			return infos
+		}
+		logger.ErrorF("List files from media catalog: %v", err)
+	}
+	userId := ResolveToUserId(user)
+	if userId == "" {
+		userId = user
+	}
+	devices := []string{deviceId}
+	if deviceId == "" {
+		devices = devices[:0]
+		entries, _ := os.ReadDir(filepath.Join(config.UploadDirectory, userId))
+		for _, e := range entries {
+			if e.IsDir() {
+				devices = append(devices, e.Name())
+			}
+		}
+	}
+	for _, dev := range devices {
+		files, _ := ListAllRelativeFiles(filepath.Join(config.UploadDirectory, userId, dev))
+		for _, file := range files {
+			if info, ok := fileInfo(user, dev, file); ok {
+				info.Path = fileRefPath(deviceId, store.FileRef{DeviceId: dev, Path: info.Path})
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
+
+// mediaFileInfo returns the info (with Path rel) and the media info of the file rel in the device
+// folder dir, or false if it does not exist. Without metadata the type comes from the extension.
+func mediaFileInfo(dir, rel string) (FileInfo, MediaInfo, bool) {
+	stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil || stat.IsDir() {
+		return FileInfo{}, MediaInfo{}, false
+	}
+	info := FileInfo{Path: rel, Size: stat.Size(), ModifiedAt: stat.ModTime().Unix()}
+	media, hasMetadata := ReadMediaInfo(MetadataPath(dir, rel))
+	if !hasMetadata {
+		media.MimeType, _, _ = strings.Cut(mime.TypeByExtension(strings.ToLower(path.Ext(rel))), ";")
+		media.Type = mediaTypeName(media.MimeType)
+	}
+	info.Type, info.MimeType = media.Type, media.MimeType
+	info.Width, info.Height, info.Duration = media.Width, media.Height, media.Duration
+	info.CapturedAt = media.CapturedAt
+	if info.CapturedAt == 0 {
+		info.CapturedAt = info.ModifiedAt
+	}
+	info.Metadata = hasMetadata
+	info.Thumbnail = hasThumbnail(dir, rel, info.Type)
+	return info, media, true
+}
+
+// hasThumbnail returns true if the thumbnail of the file rel of type mediaType exists in dir.
+// Thumbnails of videos and audio are JPEGs with an added extension (see serveImage).
+func hasThumbnail(dir, rel, mediaType string) bool {
+	thumbnail := ThumbnailBasePath(dir, rel)
+	if mediaType != "image" {
+		thumbnail += ".jpeg"
+	}
+	_, err := os.Stat(thumbnail)
+	return err == nil
+}
+
+// validate sets the defaults of o and checks it. Returns the position to continue after (nil for
+// the first page); on failure 400 is written.
+func (o *listOptions) validate(w http.ResponseWriter) (*listCursor, bool) {
+	if o.Sort == "" {
+		o.Sort = sortCaptured
+	}
+	if o.Sort != sortCaptured && o.Sort != sortName && o.Sort != sortSize {
+		utils.RenderError(w, InvalidListOption("Sort", o.Sort), http.StatusBadRequest)
+		return nil, false
+	}
+	if o.Type != "" && mediaTypeName(o.Type+"/") == "" {
+		utils.RenderError(w, InvalidListOption("Type", o.Type), http.StatusBadRequest)
+		return nil, false
+	}
+	if o.Limit <= 0 {
+		o.Limit = defaultListLimit
+	}
+	o.Limit = min(o.Limit, maxListLimit)
+	if o.Cursor == "" {
+		return nil, true
+	}
+	c, ok := decodeListCursor(o.Cursor)
+	if !ok || c.Sort != o.Sort || c.Desc != o.Desc {
+		utils.RenderError(w, InvalidCursor, http.StatusBadRequest)
+		return nil, false
+	}
+	return &c, true
+}
+
+// page sorts infos and returns the page after the cursor after.
+func (o *listOptions) page(infos []FileInfo, after *listCursor) listFilesResponse {
+	sort.Slice(infos, func(i, j int) bool {
+		return cursorLess(cursorOf(o.Sort, o.Desc, infos[i]), cursorOf(o.Sort, o.Desc, infos[j]))
+	})
+	start := 0
+	if after != nil {
+		start = sort.Search(len(infos), func(i int) bool {
+			return cursorLess(*after, cursorOf(o.Sort, o.Desc, infos[i]))
+		})
+	}
+	end := min(start+o.Limit, len(infos))
+	resp := listFilesResponse{Files: infos[start:end]}
+	if end < len(infos) {
+		resp.NextCursor = encodeListCursor(cursorOf(o.Sort, o.Desc, infos[end-1]))
+	}
+	return resp
+}
+
+// ListFilesHandler lists the files of a folder like /files, with size, type, dimensions, duration,
+// capture time and thumbnail status, sorted and in pages. Favorite, MinRating, Tags and
+// IncludeShared work as in /files.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Folder": "2024/07", "Sort": "captured" | "name" | "size",
+// "Desc": false, "Type": "image" | "video" | "audio" | "", "Limit": 100, "Cursor": "" }
+// -> { "Files": [ { "Path": "", "Size": 0, "ModifiedAt": 0, "Type": "", "MimeType": "", "Width": 0, "Height": 0,
+// "Duration": 0, "CapturedAt": 0, "Metadata": true, "Thumbnail": true } ], "NextCursor": "" }
+func ListFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req listFilesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.UserData.User == "" || strings.Contains(req.Folder, "..") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := authorizeUserAccess(w, r, req.UserData.User); !ok {
+		return
+	}
+	after, ok := req.listOptions.validate(w)
+	if !ok {
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	infos := make([]FileInfo, 0)
+	for _, file := range listFolderFiles(req.folderData) {
+		if info, ok := fileInfo(req.UserData.User, deviceId, file); ok && (req.Type == "" || info.Type == req.Type) {
+			infos = append(infos, info)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(req.listOptions.page(infos, after))
+}
@@ -0,0 +1,96 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+)
+
+func listFiles(t *testing.T, body string) (int, listFilesResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	ListFilesHandler(rr, httptest.NewRequest(http.MethodPost, "/files/list", strings.NewReader(body)))
+	var resp listFilesResponse
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr.Code, resp
+}
+
+func TestListFilesPaging(t *testing.T) {
+	if config.AuthDBPath != "" {
+		t.Skip("needs auth disabled")
+	}
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+
+	dir := filepath.Join(tmp, "u", "phone")
+	for _, name := range []string{"a.jpg", "b.mp4", "c.jpg", "d.jpg"} {
+		writeTestFile(t, filepath.Join(dir, "2024", "07", name))
+	}
+	// b.mp4 has metadata with a capture time; the others fall back to their modification time.
+	meta := `[{"Fields":{"MIMEType":"video/mp4","ImageWidth":640,"ImageHeight":480,"Duration":"3.5 s","CreateDate":"2001:01:01 00:00:00"}}]`
+	writeTestFile(t, MetadataPath(dir, "2024/07/b.mp4"))
+	if err := os.WriteFile(MetadataPath(dir, "2024/07/b.mp4"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, ThumbnailBasePath(dir, "2024/07/b.mp4")+".jpeg")
+
+	code, page := listFiles(t, `{"UserData":{"User":"u","DeviceId":"phone"},"Folder":"2024/07","Limit":3}`)
+	if code != http.StatusOK || len(page.Files) != 3 || page.NextCursor == "" {
+		t.Fatalf("first page: %d %+v", code, page)
+	}
+	first := page.Files[0]
+	if first.Path != "2024/07/b.mp4" || first.Type != "video" || first.Width != 640 || first.Duration != 3.5 ||
+		first.CapturedAt != 978307200 || !first.Metadata || !first.Thumbnail {
+		t.Fatalf("first file = %+v", first)
+	}
+	if page.Files[1].Type != "image" || page.Files[1].Metadata || page.Files[1].Thumbnail {
+		t.Fatalf("file without metadata = %+v", page.Files[1])
+	}
+	code, next := listFiles(t, `{"UserData":{"User":"u","DeviceId":"phone"},"Folder":"2024/07","Limit":3,"Cursor":"`+page.NextCursor+`"}`)
+	if code != http.StatusOK || len(next.Files) != 1 || next.NextCursor != "" {
+		t.Fatalf("second page: %d %+v", code, next)
+	}
+
+	_, byName := listFiles(t, `{"UserData":{"User":"u"},"Folder":"2024/07","Sort":"name","Desc":true,"Type":"image"}`)
+	var names []string
+	for _, f := range byName.Files {
+		names = append(names, f.Path)
+	}
+	if strings.Join(names, ",") != "phone/2024/07/d.jpg,phone/2024/07/c.jpg,phone/2024/07/a.jpg" {
+		t.Fatalf("images by name desc = %v", names)
+	}
+
+	if code, _ := listFiles(t, `{"UserData":{"User":"u","DeviceId":"phone"},"Folder":"2024/07","Sort":"name","Cursor":"`+page.NextCursor+`"}`); code != http.StatusBadRequest {
+		t.Fatalf("cursor of another sort order: %d", code)
+	}
+	if code, _ := listFiles(t, `{"UserData":{"User":"u"},"Type":"document"}`); code != http.StatusBadRequest {
+		t.Fatalf("unknown type: %d", code)
+	}
+}
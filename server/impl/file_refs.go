@@ -0,0 +1,80 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// fileRefFromPath converts a file path of a request to a file reference. Paths are the ones /files
+// returns: with a deviceId they are relative to that device ("2024/07/photo.jpg"), otherwise they
+// start with the device id ("phone/2024/07/photo.jpg"). Paths in Trash and derived folders are rejected.
+func fileRefFromPath(deviceId, file string) (store.FileRef, bool) {
+	deviceId = strings.TrimSpace(deviceId)
+	file = strings.Trim(normalizeRequestPath(file), "/")
+	if deviceId == "" {
+		deviceId, file, _ = strings.Cut(file, "/")
+	}
+	if deviceId == "" || strings.ContainsAny(deviceId, `/\`) || !isSharePath(file) {
+		return store.FileRef{}, false
+	}
+	return store.FileRef{DeviceId: deviceId, Path: file}, true
+}
+
+// fileRefPath returns the path of ref as /files lists it for deviceId ("" = all devices).
+func fileRefPath(deviceId string, ref store.FileRef) string {
+	if deviceId == "" {
+		return ref.DeviceId + "/" + ref.Path
+	}
+	return ref.Path
+}
+
+// fileRefsFromPaths converts request paths with fileRefFromPath, skipping invalid ones.
+func fileRefsFromPaths(deviceId string, files []string) []store.FileRef {
+	refs := make([]store.FileRef, 0, len(files))
+	for _, f := range files {
+		if ref, ok := fileRefFromPath(deviceId, f); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// existingFileRefs converts request paths with fileRefFromPath and returns the files of userId
+// that exist, each once. Invalid and missing paths are skipped.
+func existingFileRefs(userId, deviceId string, files []string) []store.FileRef {
+	userFolder := ResolveToUserId(userId)
+	refs := make([]store.FileRef, 0, len(files))
+	seen := make(map[store.FileRef]bool)
+	for _, ref := range fileRefsFromPaths(deviceId, files) {
+		if !seen[ref] && fileRefExists(userFolder, ref) {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// fileRefExists returns true if the file of ref exists in the storage folder userFolder.
+func fileRefExists(userFolder string, ref store.FileRef) bool {
+	_, err := os.Stat(filepath.Join(config.UploadDirectory, userFolder, ref.DeviceId, filepath.FromSlash(ref.Path)))
+	return err == nil
+}
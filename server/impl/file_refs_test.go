@@ -0,0 +1,46 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestFileRefFromPath(t *testing.T) {
+	tests := []struct {
+		deviceId, file string
+		want           store.FileRef
+		ok             bool
+	}{
+		{"phone", "2024/07/a.jpg", store.FileRef{DeviceId: "phone", Path: "2024/07/a.jpg"}, true},
+		{"phone", `2024\07\a.jpg`, store.FileRef{DeviceId: "phone", Path: "2024/07/a.jpg"}, true},
+		{"", "tablet/2024/07/a.jpg", store.FileRef{DeviceId: "tablet", Path: "2024/07/a.jpg"}, true},
+		{"", "a.jpg", store.FileRef{}, false},
+		{"phone", "Trash/2024/07/a.jpg", store.FileRef{}, false},
+		{"phone", "2024/../../x/a.jpg", store.FileRef{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := fileRefFromPath(tt.deviceId, tt.file)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("fileRefFromPath(%q, %q) = %+v, %v; want %+v, %v", tt.deviceId, tt.file, got, ok, tt.want, tt.ok)
+		}
+		if ok && fileRefPath(tt.deviceId, got) != normalizeRequestPath(tt.file) {
+			t.Errorf("fileRefPath(%q, %+v) = %q", tt.deviceId, got, fileRefPath(tt.deviceId, got))
+		}
+	}
+}
@@ -30,77 +30,91 @@ import (
 type folderData struct {
 	UserData userData
 	Folder   string
+	// IncludeShared adds the files of the user's shared libraries in Folder as "@lib/..." paths.
+	IncludeShared bool
+	annotationFilter
 }
 
 func GetFilesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
-		var files = make([]string, 0)
 		var result folderData
 		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
 			utils.RenderError(w, errors.Errorf("$Required json input {UserData: { User: '', DeviceId: ''}, Folder: ''}"), http.StatusBadRequest)
 			return
 		}
-		userFromClient := result.UserData.User
-		deviceId := strings.TrimSpace(result.UserData.DeviceId)
-		folder := result.Folder
-		userId := ResolveToUserId(userFromClient)
-		if userId == "" {
-			userId = userFromClient
+		files := listFolderFiles(result)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			if utils.RenderIfError(err, w, http.StatusInternalServerError) {
+				return
+			}
 		}
-		userDir := filepath.Join(config.UploadDirectory, userId)
-		if deviceId == "" {
-			// All devices for this account: list files from each device with "deviceId/path" prefix
-			entries, errRead := os.ReadDir(userDir)
-			if errRead == nil {
-				for _, e := range entries {
-					if !e.IsDir() {
-						continue
+	}
+}
+
+// listFolderFiles returns the paths of the files in the folder of the request as /files lists them:
+// relative to the device, or prefixed with the device id when DeviceId is empty.
+func listFolderFiles(result folderData) []string {
+	var files = make([]string, 0)
+	userFromClient := result.UserData.User
+	deviceId := strings.TrimSpace(result.UserData.DeviceId)
+	folder := result.Folder
+	userId := ResolveToUserId(userFromClient)
+	if userId == "" {
+		userId = userFromClient
+	}
+	userDir := filepath.Join(config.UploadDirectory, userId)
+	if catalogFiles, ok := catalogFolderFiles(userFromClient, deviceId, folder); ok {
+		files = catalogFiles
+	} else if deviceId == "" {
+		// All devices for this account: list files from each device with "deviceId/path" prefix
+		entries, errRead := os.ReadDir(userDir)
+		if errRead == nil {
+			for _, e := range entries {
+				if !e.IsDir() {
+					continue
+				}
+				devId := e.Name()
+				userDirName := filepath.Join(userDir, devId)
+				if folder == TrashFolder {
+					trashList, _ := ListTrashFiles(userDirName)
+					for _, p := range trashList {
+						files = append(files, devId+"/"+p)
 					}
-					devId := e.Name()
-					userDirName := filepath.Join(userDir, devId)
-					if folder == TrashFolder {
-						trashList, _ := ListTrashFiles(userDirName)
-						for _, p := range trashList {
-							files = append(files, devId+"/"+p)
-						}
-					} else {
-						dirName := filepath.Join(userDirName, folder)
-						dirEntries, err := os.ReadDir(dirName)
-						if err == nil {
-							for _, entry := range dirEntries {
-								if !entry.IsDir() {
-									files = append(files, devId+"/"+filepath.Join(folder, entry.Name()))
-								}
+				} else {
+					dirName := filepath.Join(userDirName, folder)
+					dirEntries, err := os.ReadDir(dirName)
+					if err == nil {
+						for _, entry := range dirEntries {
+							if !entry.IsDir() {
+								files = append(files, devId+"/"+filepath.Join(folder, entry.Name()))
 							}
 						}
 					}
 				}
 			}
+		}
+	} else {
+		userDirName := filepath.Join(userDir, deviceId)
+		if folder == TrashFolder {
+			files, _ = ListTrashFiles(userDirName)
 		} else {
-			userDirName := filepath.Join(userDir, deviceId)
-			if folder == TrashFolder {
-				files, _ = ListTrashFiles(userDirName)
-			} else {
-				dirName := filepath.Join(userDirName, folder)
-				entries, err := os.ReadDir(dirName)
-				if err == nil {
-					for _, entry := range entries {
-						if !entry.IsDir() {
-							file := filepath.Join(folder, entry.Name())
-							files = append(files, file)
-						}
+			dirName := filepath.Join(userDirName, folder)
+			entries, err := os.ReadDir(dirName)
+			if err == nil {
+				for _, entry := range entries {
+					if !entry.IsDir() {
+						file := filepath.Join(folder, entry.Name())
+						files = append(files, file)
 					}
 				}
 			}
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		if err := json.NewEncoder(w).Encode(files); err != nil {
-			if utils.RenderIfError(err, w, http.StatusInternalServerError) {
-				return
-			}
-		}
 	}
+	if result.IncludeShared && folder != TrashFolder {
+		files = append(files, sharedFiles(userFromClient, deviceId, folder)...)
+	}
+	return filterAnnotated(userFromClient, deviceId, files, result.annotationFilter)
 }
-
@@ -0,0 +1,160 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// FolderStats summarizes the files of a folder.
+type FolderStats struct {
+	Count  int `json:"Count"`
+	Images int `json:"Images"`
+	Videos int `json:"Videos"`
+	Audio  int `json:"Audio"`
+	// Size is the total size of the files in bytes.
+	Size int64 `json:"Size"`
+	// FirstCapturedAt and LastCapturedAt are the oldest and newest capture time (unix seconds).
+	FirstCapturedAt int64 `json:"FirstCapturedAt"`
+	LastCapturedAt  int64 `json:"LastCapturedAt"`
+	// Cover is the newest file with a thumbnail, as /files lists it; empty if none has one.
+	Cover string `json:"Cover"`
+
+	coverAt int64
+}
+
+// FolderSummaryMonth is a folder below a year, such as "2024/07".
+type FolderSummaryMonth struct {
+	// Folder is the path of the folder with forward slashes, as /files expects it.
+	Folder string `json:"Folder"`
+	FolderStats
+}
+
+// FolderSummaryYear is a year folder with its folders, newest first.
+type FolderSummaryYear struct {
+	Year string `json:"Year"`
+	FolderStats
+	Months []FolderSummaryMonth `json:"Months"`
+}
+
+type folderSummaryResponse struct {
+	FolderStats
+	Years []FolderSummaryYear `json:"Years"`
+}
+
+// add counts info in s.
+func (s *FolderStats) add(info FileInfo) {
+	if s.Count == 0 || info.CapturedAt < s.FirstCapturedAt {
+		s.FirstCapturedAt = info.CapturedAt
+	}
+	if s.Count == 0 || info.CapturedAt > s.LastCapturedAt {
+		s.LastCapturedAt = info.CapturedAt
+	}
+	s.Count++
+	s.Size += info.Size
+	switch info.Type {
+	case "image":
+		s.Images++
+	case "video":
+		s.Videos++
+	case "audio":
+		s.Audio++
+	}
+	if info.Thumbnail && (s.Cover == "" || info.CapturedAt > s.coverAt ||
+		(info.CapturedAt == s.coverAt && info.Path < s.Cover)) {
+		s.Cover, s.coverAt = info.Path, info.CapturedAt
+	}
+}
+
+// buildFolderSummary groups files by year and folder, newest first. Paths are as /files lists them
+// for deviceId; with no device the first segment is the device id and not part of the folder.
+func buildFolderSummary(deviceId string, infos []FileInfo) folderSummaryResponse {
+	years := make(map[string]*FolderSummaryYear)
+	months := make(map[string]*FolderSummaryMonth)
+	var resp folderSummaryResponse
+	for _, info := range infos {
+		rel := info.Path
+		if deviceId == "" {
+			_, rel, _ = strings.Cut(rel, "/")
+		}
+		folder := path.Dir(rel)
+		if folder == "." {
+			continue
+		}
+		resp.add(info)
+		year, _, _ := strings.Cut(folder, "/")
+		if years[year] == nil {
+			years[year] = &FolderSummaryYear{Year: year, Months: make([]FolderSummaryMonth, 0)}
+		}
+		years[year].add(info)
+		if folder == year {
+			continue
+		}
+		if months[folder] == nil {
+			months[folder] = &FolderSummaryMonth{Folder: folder}
+		}
+		months[folder].add(info)
+	}
+	for folder, m := range months {
+		year, _, _ := strings.Cut(folder, "/")
+		years[year].Months = append(years[year].Months, *m)
+	}
+	resp.Years = make([]FolderSummaryYear, 0, len(years))
+	for _, y := range years {
+		sort.Slice(y.Months, func(i, j int) bool { return y.Months[i].Folder > y.Months[j].Folder })
+		resp.Years = append(resp.Years, *y)
+	}
+	sort.Slice(resp.Years, func(i, j int) bool { return resp.Years[i].Year > resp.Years[j].Year })
+	return resp
+}
+
+// FolderSummaryHandler returns the year folders and their folders of the user, newest first, with
+// the number of files by type, total size, capture time range and a cover file. Files of all
+// devices are merged unless DeviceId is set. Files in Trash and outside a folder are not counted.
+// POST body: { "UserData": { "User": "", "DeviceId": "" } }
+// -> { "Count": 0, ..., "Years": [ { "Year": "2024", "Count": 0, "Images": 0, "Videos": 0, "Audio": 0, "Size": 0,
+// "FirstCapturedAt": 0, "LastCapturedAt": 0, "Cover": "", "Months": [ { "Folder": "2024/07", "Count": 0, ... } ] } ] }
+func FolderSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		UserData userData `json:"UserData"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	user := req.UserData.User
+	if user == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := authorizeUserAccess(w, r, user); !ok {
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(buildFolderSummary(deviceId, listUserFiles(user, deviceId)))
+}
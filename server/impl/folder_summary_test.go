@@ -0,0 +1,56 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import "testing"
+
+func TestBuildFolderSummary(t *testing.T) {
+	infos := []FileInfo{
+		{Path: "phone/2024/07/a.jpg", Size: 10, Type: "image", CapturedAt: 300, Thumbnail: true},
+		{Path: "tablet/2024/07/b.mp4", Size: 20, Type: "video", CapturedAt: 500},
+		{Path: "phone/2024/08/c.m4a", Size: 5, Type: "audio", CapturedAt: 700},
+		{Path: "phone/2024/x.jpg", Size: 1, Type: "image", CapturedAt: 100, Thumbnail: true},
+		{Path: "phone/2001/01/d.jpg", Size: 7, Type: "image", CapturedAt: 50, Thumbnail: true},
+		{Path: "phone/root.jpg", Size: 99, Type: "image", CapturedAt: 900, Thumbnail: true},
+	}
+	resp := buildFolderSummary("", infos)
+	if resp.Count != 5 || resp.Size != 43 || resp.FirstCapturedAt != 50 || resp.LastCapturedAt != 700 ||
+		resp.Cover != "phone/2024/07/a.jpg" {
+		t.Fatalf("totals = %+v", resp.FolderStats)
+	}
+	if len(resp.Years) != 2 || resp.Years[0].Year != "2024" || resp.Years[1].Year != "2001" {
+		t.Fatalf("years = %+v", resp.Years)
+	}
+	y := resp.Years[0]
+	if y.Count != 4 || y.Images != 2 || y.Videos != 1 || y.Audio != 1 || y.Size != 36 {
+		t.Fatalf("2024 = %+v", y.FolderStats)
+	}
+	if len(y.Months) != 2 || y.Months[0].Folder != "2024/08" || y.Months[1].Folder != "2024/07" {
+		t.Fatalf("months = %+v", y.Months)
+	}
+	m := y.Months[1]
+	if m.Count != 2 || m.FirstCapturedAt != 300 || m.LastCapturedAt != 500 || m.Cover != "phone/2024/07/a.jpg" {
+		t.Fatalf("2024/07 = %+v", m.FolderStats)
+	}
+	if y.Months[0].Cover != "" {
+		t.Fatalf("cover without thumbnail = %q", y.Months[0].Cover)
+	}
+
+	one := buildFolderSummary("phone", []FileInfo{{Path: "2024/07/a.jpg", Type: "image", Thumbnail: true}})
+	if len(one.Years) != 1 || one.Years[0].Months[0].Folder != "2024/07" || one.Cover != "2024/07/a.jpg" {
+		t.Fatalf("one device = %+v", one)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-errors/errors"
@@ -39,13 +40,19 @@ type folder struct {
 	Months []string
 }
 
+type foldersRequest struct {
+	userData
+	// IncludeShared adds the folders of the files in the user's shared libraries.
+	IncludeShared bool
+}
+
 func GetFoldersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var folders = make([]folder, 0)
 
-		var result userData
+		var result foldersRequest
 		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
-			utils.RenderError(w, errors.Errorf("$Required json input { User: '', DeviceId: ''}"), http.StatusBadRequest)
+			utils.RenderError(w, errors.Errorf("$Required json input { User: '', DeviceId: '', IncludeShared: false }"), http.StatusBadRequest)
 			return
 		}
 		userFromClient := result.User
@@ -57,7 +64,9 @@ func GetFoldersHandler(w http.ResponseWriter, r *http.Request) {
 		userDir := filepath.Join(config.UploadDirectory, userId)
 		separator := string(os.PathSeparator)
 
-		if deviceId == "" {
+		if catalogList, ok := catalogFolders(userFromClient, deviceId); ok {
+			folders = catalogList
+		} else if deviceId == "" {
 			// All devices for this account: list device dirs, walk each, merge folders
 			entries, errRead := os.ReadDir(userDir)
 			if errRead != nil {
@@ -131,6 +140,9 @@ func GetFoldersHandler(w http.ResponseWriter, r *http.Request) {
 				logger.ErrorF("Reading folder %s failed %v", dirName, err.Error())
 			}
 		}
+		if result.IncludeShared {
+			folders = mergeSharedFolders(folders, userFromClient, deviceId)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -142,3 +154,26 @@ func GetFoldersHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// mergeSharedFolders adds the year and month folders of the user's shared library files to folders.
+func mergeSharedFolders(folders []folder, user, deviceId string) []folder {
+	yearMonths := make(map[string]map[string]bool)
+	for _, f := range folders {
+		yearMonths[f.Year] = make(map[string]bool)
+		for _, m := range f.Months {
+			yearMonths[f.Year][m] = true
+		}
+	}
+	sharedFolders(user, deviceId, yearMonths)
+	merged := make([]folder, 0, len(yearMonths))
+	for yr, monthsSet := range yearMonths {
+		months := make([]string, 0, len(monthsSet))
+		for m := range monthsSet {
+			months = append(months, m)
+		}
+		sort.Strings(months)
+		merged = append(merged, folder{Year: yr, Months: months})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Year < merged[j].Year })
+	return merged
+}
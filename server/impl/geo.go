@@ -0,0 +1,191 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Clustering of /geo.
+const (
+	// geoCellPixels is the size of a cluster cell on the map in pixels (256 per tile).
+	geoCellPixels = 64
+	// geoItemsZoom and higher zoom levels return every file as an item.
+	geoItemsZoom = 18
+	maxGeoZoom   = 22
+	// maxMercatorLatitude is the latitude of the edge of Web Mercator maps.
+	maxMercatorLatitude = 85.05112878
+)
+
+type geoRequest struct {
+	UserData userData         `json:"UserData"`
+	Bounds   *store.GeoBounds `json:"Bounds"`
+	// Zoom is the zoom level of the map (0-22).
+	Zoom int `json:"Zoom"`
+	// Type returns only "image", "video" or "audio" files ("" = all).
+	Type string `json:"Type"`
+}
+
+func (req *geoRequest) requestUser() string { return req.UserData.User }
+
+// GeoCluster is a group of files close together on the map.
+type GeoCluster struct {
+	// Latitude and Longitude are the center of the files.
+	Latitude  float64 `json:"Latitude"`
+	Longitude float64 `json:"Longitude"`
+	Count     int     `json:"Count"`
+	// Bounds is the box around the files; zoom into it to split the cluster.
+	Bounds store.GeoBounds `json:"Bounds"`
+	// Sample is the newest file with a thumbnail, as /files lists it; empty if none has one.
+	Sample string `json:"Sample"`
+}
+
+// GeoItem is a single file on the map.
+type GeoItem struct {
+	Path       string  `json:"Path"`
+	Latitude   float64 `json:"Latitude"`
+	Longitude  float64 `json:"Longitude"`
+	Type       string  `json:"Type"`
+	CapturedAt int64   `json:"CapturedAt"`
+	Thumbnail  bool    `json:"Thumbnail"`
+}
+
+type geoResponse struct {
+	Clusters []GeoCluster `json:"Clusters"`
+	Items    []GeoItem    `json:"Items"`
+}
+
+// validBounds returns true if b is a box of valid latitudes; longitudes may cross the antimeridian.
+func validBounds(b *store.GeoBounds) bool {
+	return b.South <= b.North && b.South >= -90 && b.North <= 90
+}
+
+// mercatorPixel returns the position of lat, lon on a Web Mercator map of zoom level zoom in pixels.
+func mercatorPixel(lat, lon float64, zoom int) (float64, float64) {
+	world := 256 * math.Exp2(float64(zoom))
+	lat = math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, lat)) * math.Pi / 180
+	x := (lon + 180) / 360 * world
+	y := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * world
+	return x, y
+}
+
+// clusterGeo groups items that fall into the same cell of geoCellPixels on the map of zoom level
+// zoom. Cells with a single file, and all files from geoItemsZoom on, are returned as items.
+// Clusters are ordered by count, items newest first.
+func clusterGeo(items []GeoItem, zoom int) geoResponse {
+	resp := geoResponse{Clusters: make([]GeoCluster, 0), Items: make([]GeoItem, 0)}
+	type cell struct{ x, y int64 }
+	cells := make(map[cell][]GeoItem)
+	for _, item := range items {
+		if zoom >= geoItemsZoom {
+			resp.Items = append(resp.Items, item)
+			continue
+		}
+		x, y := mercatorPixel(item.Latitude, item.Longitude, zoom)
+		c := cell{int64(x / geoCellPixels), int64(y / geoCellPixels)}
+		cells[c] = append(cells[c], item)
+	}
+	for _, members := range cells {
+		if len(members) == 1 {
+			resp.Items = append(resp.Items, members[0])
+			continue
+		}
+		first := members[0]
+		cluster := GeoCluster{Count: len(members), Bounds: store.GeoBounds{North: first.Latitude,
+			South: first.Latitude, East: first.Longitude, West: first.Longitude}}
+		var sampleAt int64
+		for _, m := range members {
+			cluster.Latitude += m.Latitude / float64(len(members))
+			cluster.Longitude += m.Longitude / float64(len(members))
+			cluster.Bounds.North = math.Max(cluster.Bounds.North, m.Latitude)
+			cluster.Bounds.South = math.Min(cluster.Bounds.South, m.Latitude)
+			cluster.Bounds.East = math.Max(cluster.Bounds.East, m.Longitude)
+			cluster.Bounds.West = math.Min(cluster.Bounds.West, m.Longitude)
+			if m.Thumbnail && (cluster.Sample == "" || m.CapturedAt > sampleAt ||
+				(m.CapturedAt == sampleAt && m.Path < cluster.Sample)) {
+				cluster.Sample, sampleAt = m.Path, m.CapturedAt
+			}
+		}
+		resp.Clusters = append(resp.Clusters, cluster)
+	}
+	sort.Slice(resp.Clusters, func(i, j int) bool {
+		a, b := resp.Clusters[i], resp.Clusters[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Latitude != b.Latitude {
+			return a.Latitude > b.Latitude
+		}
+		return a.Longitude < b.Longitude
+	})
+	sort.Slice(resp.Items, func(i, j int) bool {
+		a, b := resp.Items[i], resp.Items[j]
+		if a.CapturedAt != b.CapturedAt {
+			return a.CapturedAt > b.CapturedAt
+		}
+		return a.Path < b.Path
+	})
+	return resp
+}
+
+// GeoHandler returns the user's GPS-tagged files outside Trash in Bounds for a map at zoom level
+// Zoom: files close together on the map as clusters, single files as items. From zoom level 18 on
+// all files are items. Paths are relative to DeviceId, or start with the device id without one.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Bounds": { "North": 0, "South": 0, "East": 0, "West": 0 },
+// "Zoom": 0, "Type": "" } -> { "Clusters": [ { "Latitude": 0, "Longitude": 0, "Count": 0, "Bounds": { ... }, "Sample": "" } ],
+// "Items": [ { "Path": "", "Latitude": 0, "Longitude": 0, "Type": "", "CapturedAt": 0, "Thumbnail": true } ] }
+func GeoHandler(w http.ResponseWriter, r *http.Request) {
+	var req geoRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	if req.Bounds == nil || !validBounds(req.Bounds) {
+		utils.RenderError(w, InvalidBounds, http.StatusBadRequest)
+		return
+	}
+	if req.Zoom < 0 || req.Zoom > maxGeoZoom {
+		utils.RenderError(w, InvalidListOption("Zoom", strconv.Itoa(req.Zoom)), http.StatusBadRequest)
+		return
+	}
+	if req.Type != "" && mediaTypeName(req.Type+"/") == "" {
+		utils.RenderError(w, InvalidListOption("Type", req.Type), http.StatusBadRequest)
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	entries, err := store.SearchMedia(userId, store.MediaQuery{DeviceId: deviceId, MediaType: req.Type, Bounds: req.Bounds})
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	items := make([]GeoItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, GeoItem{Path: fileRefPath(deviceId, store.FileRef{DeviceId: e.DeviceId, Path: e.Path}),
+			Latitude: e.Latitude, Longitude: e.Longitude, Type: e.MediaType, CapturedAt: e.CapturedAt,
+			Thumbnail: e.HasThumbnail})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(clusterGeo(items, req.Zoom))
+}
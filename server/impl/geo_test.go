@@ -0,0 +1,59 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMercatorPixel(t *testing.T) {
+	if x, y := mercatorPixel(0, 0, 0); x != 128 || math.Abs(y-128) > 1e-9 {
+		t.Fatalf("center = %v, %v", x, y)
+	}
+	if x, y := mercatorPixel(90, -180, 1); x != 0 || y > 1e-6 {
+		t.Fatalf("north-west corner = %v, %v", x, y)
+	}
+}
+
+func TestClusterGeo(t *testing.T) {
+	items := []GeoItem{
+		// Barcelona, a few hundred meters apart.
+		{Path: "p/a.jpg", Latitude: 41.3870, Longitude: 2.1700, CapturedAt: 100, Thumbnail: true},
+		{Path: "p/b.jpg", Latitude: 41.3890, Longitude: 2.1720, CapturedAt: 300, Thumbnail: true},
+		{Path: "p/c.mp4", Latitude: 41.3880, Longitude: 2.1710, CapturedAt: 500},
+		// Girona, 90 km away.
+		{Path: "p/d.jpg", Latitude: 41.9794, Longitude: 2.8214, CapturedAt: 200, Thumbnail: true},
+	}
+	resp := clusterGeo(items, 10)
+	if len(resp.Clusters) != 1 || len(resp.Items) != 1 || resp.Items[0].Path != "p/d.jpg" {
+		t.Fatalf("zoom 10 = %+v", resp)
+	}
+	c := resp.Clusters[0]
+	if c.Count != 3 || c.Sample != "p/b.jpg" || math.Abs(c.Latitude-41.388) > 1e-9 || math.Abs(c.Longitude-2.171) > 1e-9 {
+		t.Fatalf("cluster = %+v", c)
+	}
+	if c.Bounds.North != 41.3890 || c.Bounds.South != 41.3870 || c.Bounds.East != 2.1720 || c.Bounds.West != 2.1700 {
+		t.Fatalf("cluster bounds = %+v", c.Bounds)
+	}
+	if resp := clusterGeo(items, 2); len(resp.Clusters) != 1 || resp.Clusters[0].Count != 4 || len(resp.Items) != 0 {
+		t.Fatalf("zoom 2 = %+v", resp)
+	}
+	resp = clusterGeo(items, geoItemsZoom)
+	if len(resp.Clusters) != 0 || len(resp.Items) != 4 || resp.Items[0].Path != "p/c.mp4" {
+		t.Fatalf("zoom %d = %+v", geoItemsZoom, resp)
+	}
+}
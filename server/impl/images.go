@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/disintegration/imaging"
@@ -37,62 +38,188 @@ type fileData struct {
 	UserData userData
 	File     string
 	Quality  string
+	Size     string
+	Format   string
 }
 
+// imageOptions selects what /img returns: the original (Quality "full") or a thumbnail rendition
+// of Size ("s250", "f1280", ...; default s250, or the largest fit rendition for Quality "high") in
+// Format ("jpeg" or "webp"; default: webp if the Accept header allows it, else jpeg).
+type imageOptions struct {
+	Quality string
+	Size    string
+	Format  string
+	Accept  string
+}
+
+// queryImageOptions returns the image options of a GET request.
+func queryImageOptions(r *http.Request) imageOptions {
+	q := r.URL.Query()
+	return imageOptions{Quality: q.Get("Quality"), Size: q.Get("Size"), Format: q.Get("Format"), Accept: r.Header.Get("Accept")}
+}
+
+// GetImageHandler returns an image or its thumbnail.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "File": "", "Quality": "full" | "high" | "",
+// "Size": "s250", "Format": "jpeg" | "webp" | "" }
+// GET /img?User=...&DeviceId=...&File=...&Quality=...&Size=...&Format=...&Expires=...&Signature=... requires a
+// signed URL from /sign-url.
+// File can be a "@lib/..." path of a library the user is a member of; POST then needs the user's session token.
 func GetImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		userFromClient, deviceId, file := q.Get("User"), q.Get("DeviceId"), normalizeRequestPath(q.Get("File"))
+		if userFromClient == "" || deviceId == "" || file == "" || strings.Contains(file, "..") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !verifySignedRequest(w, r, signedImage, userFromClient, deviceId, file) {
+			return
+		}
+		if folder, deviceId, file, ok := resolveMediaFile(w, r, userFromClient, deviceId, file, true); ok {
+			serveImage(w, folder, deviceId, file, queryImageOptions(r))
+		}
+		return
+	}
 	if r.Method == "POST" {
 		var result fileData
 		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
 			utils.RenderError(w, errors.Errorf("$Required json input {UserData: { User: '', DeviceId: ''}, 	File: ''}"), http.StatusBadRequest)
 			return
 		}
-		userFromClient := result.UserData.User
-		deviceId := result.UserData.DeviceId
-		userId := ResolveToUserId(userFromClient)
-		if userId == "" {
-			userId = userFromClient
-		}
-		file := result.File
-		quality := result.Quality
-		userDirName := filepath.Join(config.UploadDirectory, userId, deviceId)
-		originalFilePath := filepath.Join(userDirName, file)
-		if quality == "full" {
-			// Serve original file as-is — no decode/re-encode, no quality change.
-			if err := serveOriginalFile(w, originalFilePath, file); err != nil {
-				utils.RenderError(w, err, http.StatusInternalServerError)
-			}
-			return
+		if folder, deviceId, file, ok := resolveMediaFile(w, r, result.UserData.User, result.UserData.DeviceId, result.File, false); ok {
+			serveImage(w, folder, deviceId, file, imageOptions{Quality: result.Quality, Size: result.Size,
+				Format: result.Format, Accept: r.Header.Get("Accept")})
 		}
+	}
+}
 
-		path := ""
-		thumbnailAddedExtension, err := utils.GetThumbnailFileAddedExtension(originalFilePath)
-		if err != nil {
-			utils.RenderError(w, err, http.StatusInternalServerError)
-			return
-		}
-		path = fmt.Sprintf("%s%s", ThumbnailBasePath(userDirName, file), thumbnailAddedExtension)
-		src, err := utils.GetImageFromFilePath(path)
-		if err != nil {
+// serveImage writes the original ("full") or a stored thumbnail rendition of file in the storage
+// folder userId/deviceId; see [imageOptions]. Missing renditions are created first. If that fails,
+// the base thumbnail is re-encoded: as a 1920px JPEG ("high") or PNG (default).
+func serveImage(w http.ResponseWriter, userId, deviceId, file string, opts imageOptions) {
+	userDirName := filepath.Join(config.UploadDirectory, userId, deviceId)
+	originalFilePath := filepath.Join(userDirName, file)
+	quality := opts.Quality
+	if quality == "full" {
+		// Serve original file as-is — no decode/re-encode, no quality change.
+		if err := serveOriginalFile(w, originalFilePath, file); err != nil {
 			utils.RenderError(w, err, http.StatusInternalServerError)
-			return
 		}
+		return
+	}
+	if served, err := serveRendition(w, userDirName, file, opts); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	} else if served {
+		return
+	}
 
-		if quality == "high" {
-			metadataPath := MetadataPath(userDirName, file)
-			orientation := GetOrientationFromMetadata(metadataPath)
-			src = applyEXIFOrientation(src, orientation)
-			src = resizeMaxLongEdge(src, 1920)
-			w.Header().Set("Content-Type", "image/jpeg")
-			w.WriteHeader(http.StatusOK)
-			jpeg.Encode(w, src, &jpeg.Options{Quality: 85})
-			return
-		}
+	path := ""
+	thumbnailAddedExtension, err := utils.GetThumbnailFileAddedExtension(originalFilePath)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	path = fmt.Sprintf("%s%s", ThumbnailBasePath(userDirName, file), thumbnailAddedExtension)
+	src, err := utils.GetImageFromFilePath(path)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
 
-		// Thumbnail: PNG
-		w.Header().Set("Content-Type", "image/png")
+	if quality == "high" {
+		metadataPath := MetadataPath(userDirName, file)
+		orientation := GetOrientationFromMetadata(metadataPath)
+		src = applyEXIFOrientation(src, orientation)
+		src = resizeMaxLongEdge(src, 1920)
+		w.Header().Set("Content-Type", "image/jpeg")
 		w.WriteHeader(http.StatusOK)
-		png.Encode(w, src)
+		jpeg.Encode(w, src, &jpeg.Options{Quality: 85})
+		return
+	}
+
+	// Thumbnail: PNG
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	png.Encode(w, src)
+}
+
+// serveRendition writes the stored rendition of file in userDirName selected by opts, creating
+// the renditions of file if it has none. Returns false if there is none to serve, and an error
+// for an invalid size or format.
+func serveRendition(w http.ResponseWriter, userDirName, file string, opts imageOptions) (bool, error) {
+	requested := thumbnailRendition{Name: "s250", Square: true, Size: 250}
+	if opts.Quality == "high" {
+		requested = thumbnailRendition{Name: "f1920", Size: 1920}
+	}
+	if opts.Size != "" {
+		var ok bool
+		if requested, ok = parseRendition(opts.Size); !ok {
+			return false, InvalidListOption("thumbnail size", opts.Size)
+		}
+	}
+	var formats []string
+	switch opts.Format {
+	case renditionJPEG:
+		formats = []string{renditionJPEG}
+	case renditionWebP:
+		formats = []string{renditionWebP, renditionJPEG}
+	case "":
+		w.Header().Set("Vary", "Accept")
+		formats = []string{renditionJPEG}
+		if acceptsWebP(opts.Accept) {
+			formats = []string{renditionWebP, renditionJPEG}
+		}
+	default:
+		return false, InvalidListOption("image format", opts.Format)
+	}
+	rendition, ok := pickRendition(requested)
+	if !ok {
+		return false, nil
+	}
+	base := ThumbnailBasePath(userDirName, file)
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, format := range formats {
+			if f, err := os.Open(renditionPath(base, rendition, format)); err == nil {
+				defer f.Close()
+				w.Header().Set("Content-Type", renditionContentTypes[format])
+				if stat, err := f.Stat(); err == nil {
+					w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+				}
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.Copy(w, f)
+				return true, nil
+			}
+		}
+		if attempt == 0 {
+			if err := buildRenditions(userDirName, file); err != nil {
+				return false, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// acceptsWebP returns true if the Accept header lists image/webp (or image/*) without q=0.
+func acceptsWebP(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+		if mediaRange != "image/webp" && mediaRange != "image/*" {
+			continue
+		}
+		rejected := false
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				rejected = err == nil && q == 0
+			}
+		}
+		if !rejected {
+			return true
+		}
 	}
+	return false
 }
 
 // serveOriginalFile streams the file unchanged; Content-Type from extension.
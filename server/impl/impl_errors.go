@@ -63,3 +63,63 @@ func (r *RequestError) InternalServerError() bool {
 func (r *RequestError) BadRequest() bool {
 	return r.StatusCode == http.StatusBadRequest
 }
+
+// An error for OIDC login requests when no identity provider is configured.
+var OIDCNotConfigured = errors.Errorf("OpenID Connect login is not configured.").Err
+
+// An error for an OIDC callback with an unknown or expired state.
+var OIDCInvalidState = errors.Errorf("Invalid or expired login state.").Err
+
+// An error for an OIDC user that cannot be linked to or provisioned as a local account.
+var OIDCAccountNotLinked = errors.Errorf("No account is linked to this identity and the email is not verified.").Err
+
+// An error for an unknown registration mode.
+func InvalidRegistrationMode(mode string) error {
+	return errors.Errorf("Registration mode '%s' is not one of open, invite, disabled.", mode).Err
+}
+
+// An error for registration requests while registration is disabled.
+var RegistrationClosed = errors.Errorf("Registration is disabled.").Err
+
+// An error for registration requests without an invite code while registration is invite-only.
+var InviteCodeRequired = errors.Errorf("An invite code is required to register.").Err
+
+// An error for a legacy storage folder that could not be fully moved to the user id folder.
+func StorageMigrationIncomplete(folder string) error {
+	return errors.Errorf("Storage folder '%s' could not be fully migrated; resolve the conflicts reported by /admin/storage/migrate.", folder).Err
+}
+
+// An error for a signed URL that is missing its signature, has expired or was modified.
+var SignedURLInvalid = errors.Errorf("Invalid or expired signed URL.").Err
+
+// An error for a share link after too many wrong passwords from the same client.
+var ShareAttemptsExceeded = errors.Errorf("Too many wrong passwords for this share. Try again later.").Err
+
+// An error for share link and library requests while no auth DB is configured to store them.
+var AuthDBRequired = errors.Errorf("This feature needs the auth database.").Err
+
+// An error for a star rating outside 0..max.
+func InvalidRating(max int) error {
+	return errors.Errorf("Rating must be between 0 and %d.", max).Err
+}
+
+// An error for a tag longer than max characters.
+func TagTooLong(max int) error {
+	return errors.Errorf("Tags can have at most %d characters.", max).Err
+}
+
+// An error for an unknown listing option value.
+func InvalidListOption(option, value string) error {
+	return errors.Errorf("'%s' is not a valid %s.", value, option).Err
+}
+
+// An error for a listing cursor that does not belong to the listing it was sent with.
+var InvalidCursor = errors.Errorf("Invalid cursor for this listing.").Err
+
+// An error for a duplicate group to resolve that does not list the files the user reviewed.
+func DuplicateFilesRequired(id string) error {
+	return errors.Errorf("Group '%s' must list the Files that were reviewed.", id).Err
+}
+
+// An error for a missing map box or one with invalid latitudes.
+var InvalidBounds = errors.Errorf("Bounds must be a box with South <= North between -90 and 90.").Err
@@ -0,0 +1,118 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// defaultInviteHours is the validity of an invite code when the admin does not set one.
+const defaultInviteHours = 72
+
+type createInviteRequest struct {
+	ExpiresInHours int `json:"ExpiresInHours"`
+}
+
+type inviteCodeRequest struct {
+	Code string `json:"Code"`
+}
+
+// CreateInviteHandler creates a single-use invite code for registration. Requires an admin token.
+// POST body: { "ExpiresInHours": 72 } -> { "Code": "", "ExpiresAt": <unix> }
+func CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adminId, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req createInviteRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	hours := req.ExpiresInHours
+	if hours <= 0 {
+		hours = defaultInviteHours
+	}
+	code, err := utils.RandomHex(12)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(hours) * time.Hour)
+	if err := store.CreateInvite(code, adminId, expiresAt); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, adminId, "", AuditAdminInvite, nil, auditOK, "create "+code)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(store.Invite{Code: code, CreatedBy: adminId, CreatedAt: time.Now().Unix(), ExpiresAt: expiresAt.Unix()})
+}
+
+// ListInvitesHandler returns all invite codes with their state. Requires an admin token.
+// GET -> [ { "Code": "", "ExpiresAt": 0, "UsedBy": "", ... } ]
+func ListInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	invites, err := store.ListInvites()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(invites)
+}
+
+// RevokeInviteHandler revokes an unused invite code. Requires an admin token.
+// POST body: { "Code": "" } -> 200, or 404 if there is no unused invite with this code.
+func RevokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adminId, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req inviteCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	revoked, err := store.RevokeInvite(req.Code)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	audit(r, adminId, "", AuditAdminInvite, nil, auditOK, "revoke "+req.Code)
+	w.WriteHeader(http.StatusOK)
+}
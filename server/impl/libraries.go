@@ -0,0 +1,488 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// libraryPrefix starts the path of a library file in /files, /img, /stream and /move-to-trash:
+// "@lib/<libraryId>/<ownerId>/<deviceId>/2024/07/photo.jpg". The file stays in the owner's storage.
+const libraryPrefix = "@lib/"
+
+type libraryRequest struct {
+	LibraryId string `json:"LibraryId"`
+	Name      string `json:"Name"`
+}
+
+type libraryMemberRequest struct {
+	LibraryId string `json:"LibraryId"`
+	User      string `json:"User"`
+	// Role is "view", "contribute" or "manage"; empty removes the member.
+	Role string `json:"Role"`
+}
+
+type libraryFilesRequest struct {
+	LibraryId string   `json:"LibraryId"`
+	UserData  userData `json:"UserData"`
+	Files     []string `json:"Files"`
+}
+
+type libraryFilesResponse struct {
+	Files []string `json:"Files"`
+}
+
+// isLibraryPath returns true if file names a library file.
+func isLibraryPath(file string) bool {
+	return strings.HasPrefix(file, libraryPrefix)
+}
+
+// libraryPath returns the "@lib/..." path of item.
+func libraryPath(item store.LibraryItem) string {
+	return libraryPrefix + item.LibraryId + "/" + item.OwnerId + "/" + item.DeviceId + "/" + item.Path
+}
+
+// parseLibraryPath splits a "@lib/..." path into the library item it names.
+func parseLibraryPath(file string) (store.LibraryItem, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(normalizeRequestPath(file), libraryPrefix), "/", 4)
+	if !isLibraryPath(file) || len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" ||
+		!isSharePath(parts[3]) {
+		return store.LibraryItem{}, false
+	}
+	return store.LibraryItem{LibraryId: parts[0], OwnerId: parts[1], DeviceId: parts[2], Path: parts[3]}, true
+}
+
+// libraryFilePath returns the absolute path of the original of item.
+func libraryFilePath(item store.LibraryItem) string {
+	return filepath.Join(config.UploadDirectory, ResolveToUserId(item.OwnerId), item.DeviceId, filepath.FromSlash(item.Path))
+}
+
+// resolveMediaFile maps a request for file by user to the storage folder, device and path that hold
+// the original. Library files resolve to the owner's storage if the request is by user (a signed
+// URL, or see authorizeUserAccess), user is a member of the library and the file is in it; otherwise
+// 401/403/404 is written and false returned. Other files belong to user.
+func resolveMediaFile(w http.ResponseWriter, r *http.Request, user, deviceId, file string, signed bool) (string, string, string, bool) {
+	if !isLibraryPath(file) {
+		return ResolveToUserId(user), deviceId, file, true
+	}
+	item, ok := parseLibraryPath(file)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", "", "", false
+	}
+	if !signed {
+		if _, ok := authorizeUserAccess(w, r, user); !ok {
+			return "", "", "", false
+		}
+	}
+	if !store.LibraryRoleAllows(store.LibraryRole(item.LibraryId, canonicalUserId(user)), store.LibraryView) {
+		w.WriteHeader(http.StatusForbidden)
+		return "", "", "", false
+	}
+	if found, err := store.GetLibraryItem(item.LibraryId, item.OwnerId, item.DeviceId, item.Path); err != nil || found == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return "", "", "", false
+	}
+	return ResolveToUserId(item.OwnerId), item.DeviceId, item.Path, true
+}
+
+// visibleLibraryItems returns the library items user can browse whose originals exist (trashed files
+// of the owner are hidden until restored), without the files user sees in the own listing of deviceId
+// ("" = all devices). A file in several libraries is returned once.
+func visibleLibraryItems(user, deviceId string) []store.LibraryItem {
+	visible := make([]store.LibraryItem, 0)
+	if config.AuthDBPath == "" {
+		return visible
+	}
+	member := canonicalUserId(user)
+	items, err := store.ListMemberLibraryItems(member)
+	if err != nil {
+		logger.ErrorF("Library items of %s: %v", member, err)
+		return visible
+	}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.OwnerId == member && (deviceId == "" || deviceId == item.DeviceId) {
+			continue
+		}
+		key := item.OwnerId + "/" + item.DeviceId + "/" + item.Path
+		if seen[key] {
+			continue
+		}
+		if _, err := os.Stat(libraryFilePath(item)); err != nil {
+			continue
+		}
+		seen[key] = true
+		visible = append(visible, item)
+	}
+	return visible
+}
+
+// sharedFolders adds the year and month folders of the library files user can browse to yearMonths.
+func sharedFolders(user, deviceId string, yearMonths map[string]map[string]bool) {
+	for _, item := range visibleLibraryItems(user, deviceId) {
+		dir := path.Dir(item.Path)
+		year := strings.SplitN(dir, "/", 2)[0]
+		if dir == "." || len(year) != 4 {
+			continue
+		}
+		if yearMonths[year] == nil {
+			yearMonths[year] = make(map[string]bool)
+		}
+		if dir != year {
+			yearMonths[year][filepath.FromSlash(dir)] = true
+		}
+	}
+}
+
+// sharedFiles returns the "@lib/..." paths of the library files directly in folder.
+func sharedFiles(user, deviceId, folder string) []string {
+	files := make([]string, 0)
+	folder = strings.Trim(normalizeRequestPath(folder), "/")
+	for _, item := range visibleLibraryItems(user, deviceId) {
+		if path.Dir(item.Path) == folder {
+			files = append(files, libraryPath(item))
+		}
+	}
+	return files
+}
+
+// trashLibraryFile handles a "@lib/..." path sent to /move-to-trash by user. The owner's original is
+// moved to the owner's Trash (and shows up in the library again when restored). Anyone else only
+// removes the file from the library: contributors their own additions, managers any file.
+// The caller checks with authorizeUserAccess that the request is by user. Returns true if something changed.
+func trashLibraryFile(user, file string) bool {
+	ref, ok := parseLibraryPath(file)
+	if !ok {
+		return false
+	}
+	item, err := store.GetLibraryItem(ref.LibraryId, ref.OwnerId, ref.DeviceId, ref.Path)
+	if err != nil || item == nil {
+		return false
+	}
+	member := canonicalUserId(user)
+	if item.OwnerId == member {
+		return MoveRelativePathToTrash(filepath.Join(config.UploadDirectory, ResolveToUserId(item.OwnerId), item.DeviceId), item.Path)
+	}
+	if !canRemoveLibraryItem(item, member) {
+		return false
+	}
+	removed, err := store.RemoveLibraryItem(item.LibraryId, item.OwnerId, item.DeviceId, item.Path)
+	if err != nil {
+		logger.ErrorF("Remove %s from library: %v", file, err)
+	}
+	return removed
+}
+
+// canRemoveLibraryItem returns true if userId may remove item from its library: managers can
+// remove any item, contributors the items they added.
+func canRemoveLibraryItem(item *store.LibraryItem, userId string) bool {
+	role := store.LibraryRole(item.LibraryId, userId)
+	return store.LibraryRoleAllows(role, store.LibraryManage) ||
+		(store.LibraryRoleAllows(role, store.LibraryContribute) && item.AddedBy == userId)
+}
+
+// librarySession writes 404 without auth DB and 401 without a session token; returns the session user.
+func librarySession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !requireAuthDB(w) {
+		return "", false
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return "", false
+	}
+	return userId, true
+}
+
+// requireLibraryRole writes 404 (unknown library) or 403 and returns nil unless userId has role need.
+func requireLibraryRole(w http.ResponseWriter, libraryId, userId, need string) *store.Library {
+	lib, err := store.GetLibrary(libraryId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return nil
+	}
+	if lib == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	if !store.LibraryRoleAllows(store.LibraryRole(libraryId, userId), need) && !IsAdmin(userId) {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	return lib
+}
+
+// ListLibrariesHandler returns the libraries the caller is a member of, with the caller's role.
+// GET -> [ { "Id": "", "Name": "", "OwnerId": "", "CreatedAt": 0, "Role": "view" | "contribute" | "manage" } ]
+func ListLibrariesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	libs, err := store.ListLibraries(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(libs)
+}
+
+// CreateLibraryHandler creates a library owned by the caller, who becomes its first manager.
+// POST body: { "Name": "Family" } -> { "Id": "", "Name": "", "OwnerId": "", "CreatedAt": 0, "Role": "manage" }
+func CreateLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	var req libraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id, err := utils.RandomHex(16)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	lib := store.Library{Id: id, Name: name, OwnerId: userId}
+	if err := store.CreateLibrary(lib); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	lib.Role = store.LibraryManage
+	audit(r, userId, "", AuditLibrary, nil, auditOK, "create "+id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(lib)
+}
+
+// DeleteLibraryHandler deletes a library with its members and items. The files stay with their
+// owners. Only the owner of the library (or the admin) can delete it.
+// POST body: { "LibraryId": "" }
+func DeleteLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	var req libraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	lib := requireLibraryRole(w, req.LibraryId, userId, store.LibraryManage)
+	if lib == nil {
+		return
+	}
+	if lib.OwnerId != userId && !IsAdmin(userId) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := store.DeleteLibrary(lib.Id); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, userId, "", AuditLibrary, nil, auditOK, "delete "+lib.Id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// LibraryMembersHandler lists (GET, any member) or changes (POST, managers) the members of a library.
+// GET /libraries/members?id=<libraryId> -> [ { "UserId": "", "Username": "", "Role": "", "AddedAt": 0 } ]
+// POST body: { "LibraryId": "", "User": "<user id or username>", "Role": "view" | "contribute" | "manage" | "" }
+// An empty Role removes the member. The role of the owner cannot be changed.
+func LibraryMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		lib := requireLibraryRole(w, r.URL.Query().Get("id"), userId, store.LibraryView)
+		if lib == nil {
+			return
+		}
+		members, err := store.ListLibraryMembers(lib.Id)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(members)
+		return
+	}
+	var req libraryMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	lib := requireLibraryRole(w, req.LibraryId, userId, store.LibraryManage)
+	if lib == nil {
+		return
+	}
+	member := canonicalUserId(strings.TrimSpace(req.User))
+	if !store.UserIdExists(member) {
+		utils.RenderError(w, store.ErrUserNotFound, http.StatusNotFound)
+		return
+	}
+	if member == lib.OwnerId {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	var err error
+	if req.Role == "" {
+		_, err = store.RemoveLibraryMember(lib.Id, member)
+	} else {
+		err = store.SetLibraryMember(lib.Id, member, req.Role)
+	}
+	if err == store.ErrInvalidLibraryRole {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, member, "", AuditLibrary, nil, auditOK, "member "+lib.Id+" "+req.Role)
+	w.WriteHeader(http.StatusOK)
+}
+
+// AddLibraryFilesHandler adds files from the caller's own storage to a library. Needs the
+// contribute role. Files that do not exist are skipped.
+// POST body: { "LibraryId": "", "UserData": { "User": "", "DeviceId": "" }, "Files": ["2024/07/photo.jpg", ...] }
+// -> { "Files": ["@lib/<libraryId>/<ownerId>/<deviceId>/2024/07/photo.jpg", ...] }
+func AddLibraryFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	var req libraryFilesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	if deviceId == "" || strings.ContainsAny(deviceId, `/\`) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if canonicalUserId(req.UserData.User) != userId {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	lib := requireLibraryRole(w, req.LibraryId, userId, store.LibraryContribute)
+	if lib == nil {
+		return
+	}
+	added := make([]string, 0, len(req.Files))
+	for _, f := range req.Files {
+		item := store.LibraryItem{LibraryId: lib.Id, OwnerId: userId, DeviceId: deviceId, Path: normalizeRequestPath(f), AddedBy: userId}
+		if !isSharePath(item.Path) {
+			continue
+		}
+		if _, err := os.Stat(libraryFilePath(item)); err != nil {
+			continue
+		}
+		if err := store.AddLibraryItem(item); err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		added = append(added, libraryPath(item))
+	}
+	audit(r, userId, deviceId, AuditLibrary, added, auditOK, "add "+lib.Id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(libraryFilesResponse{Files: added})
+}
+
+// RemoveLibraryFilesHandler removes files from their library without touching the originals.
+// Contributors can remove their own additions, managers any file.
+// POST body: { "Files": ["@lib/...", ...] } -> { "Files": [<removed paths>] }
+func RemoveLibraryFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId, ok := librarySession(w, r)
+	if !ok {
+		return
+	}
+	var req libraryFilesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	removed := make([]string, 0, len(req.Files))
+	for _, f := range req.Files {
+		item, ok := parseLibraryPath(f)
+		if !ok {
+			continue
+		}
+		found, err := store.GetLibraryItem(item.LibraryId, item.OwnerId, item.DeviceId, item.Path)
+		if err != nil || found == nil {
+			continue
+		}
+		if !canRemoveLibraryItem(found, userId) {
+			continue
+		}
+		if ok, err := store.RemoveLibraryItem(item.LibraryId, item.OwnerId, item.DeviceId, item.Path); err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		} else if ok {
+			removed = append(removed, libraryPath(item))
+		}
+	}
+	audit(r, userId, "", AuditLibrary, removed, auditOK, "remove")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(libraryFilesResponse{Files: removed})
+}
@@ -0,0 +1,139 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestParseLibraryPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want store.LibraryItem
+		ok   bool
+	}{
+		{"@lib/lib1/u1/phone/2024/07/a.jpg", store.LibraryItem{LibraryId: "lib1", OwnerId: "u1", DeviceId: "phone", Path: "2024/07/a.jpg"}, true},
+		{`@lib/lib1/u1/phone/2024\07\a.jpg`, store.LibraryItem{LibraryId: "lib1", OwnerId: "u1", DeviceId: "phone", Path: "2024/07/a.jpg"}, true},
+		{"@lib/lib1/u1/phone", store.LibraryItem{}, false},
+		{"@lib/lib1//phone/2024/07/a.jpg", store.LibraryItem{}, false},
+		{"@lib/lib1/u1/phone/../other/a.jpg", store.LibraryItem{}, false},
+		{"@lib/lib1/u1/phone/Trash/2024/07/a.jpg", store.LibraryItem{}, false},
+		{"2024/07/a.jpg", store.LibraryItem{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseLibraryPath(tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseLibraryPath(%q) = %+v, %v; want %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+		if ok && libraryPath(got) != normalizeRequestPath(tt.path) {
+			t.Errorf("libraryPath(%+v) = %q", got, libraryPath(got))
+		}
+	}
+}
+
+func TestLibraryRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, need string
+		want       bool
+	}{
+		{store.LibraryView, store.LibraryView, true},
+		{store.LibraryView, store.LibraryContribute, false},
+		{store.LibraryContribute, store.LibraryView, true},
+		{store.LibraryManage, store.LibraryContribute, true},
+		{"", store.LibraryView, false},
+		{"owner", store.LibraryView, false},
+	}
+	for _, tt := range tests {
+		if got := store.LibraryRoleAllows(tt.role, tt.need); got != tt.want {
+			t.Errorf("LibraryRoleAllows(%q, %q) = %v, want %v", tt.role, tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestLibraryFilesNeedSession(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	ownerId, ownerToken := createTestUser(t, "owner@example.com")
+	memberId, memberToken := createTestUser(t, "member@example.com")
+	writeTestFile(t, filepath.Join(tmp, ownerId, "phone", "2024", "07", "a.jpg"))
+	if err := store.CreateLibrary(store.Library{Id: "lib1", Name: "Family", OwnerId: ownerId}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetLibraryMember("lib1", memberId, store.LibraryView); err != nil {
+		t.Fatal(err)
+	}
+	item := store.LibraryItem{LibraryId: "lib1", OwnerId: ownerId, DeviceId: "phone", Path: "2024/07/a.jpg", AddedBy: ownerId}
+	if err := store.AddLibraryItem(item); err != nil {
+		t.Fatal(err)
+	}
+	file := libraryPath(item)
+
+	send := func(handler http.HandlerFunc, r *http.Request, token string) int {
+		t.Helper()
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		return rr.Code
+	}
+	img := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/img", strings.NewReader(
+			`{"UserData":{"User":"member@example.com","DeviceId":"phone"},"File":"`+file+`","Quality":"full"}`))
+	}
+	if code := send(GetImageHandler, img(), ""); code != http.StatusUnauthorized {
+		t.Fatalf("img without session: %d; want 401", code)
+	}
+	if code := send(GetImageHandler, img(), ownerToken); code != http.StatusForbidden {
+		t.Fatalf("img with another user's session: %d; want 403", code)
+	}
+	if code := send(GetImageHandler, img(), memberToken); code != http.StatusOK {
+		t.Fatalf("img with the member's session: %d; want 200", code)
+	}
+	stream := httptest.NewRequest(http.MethodGet, "/stream?User=member@example.com&DeviceId=phone&File="+url.QueryEscape(file), nil)
+	if code := send(GetStreamHandler, stream, ""); code != http.StatusUnauthorized {
+		t.Fatalf("stream without session: %d; want 401", code)
+	}
+
+	trash := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/move-to-trash", strings.NewReader(
+			`{"UserData":{"User":"owner@example.com","DeviceId":"phone"},"Files":["`+file+`"]}`))
+	}
+	if code := send(MoveToTrashHandler, trash(), ""); code != http.StatusUnauthorized {
+		t.Fatalf("trash without session: %d; want 401", code)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, ownerId, "phone", "2024", "07", "a.jpg")); err != nil {
+		t.Fatalf("original trashed without session: %v", err)
+	}
+	if code := send(MoveToTrashHandler, trash(), ownerToken); code != http.StatusOK {
+		t.Fatalf("trash with the owner's session: %d; want 200", code)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, ownerId, "phone", "Trash", "2024", "07", "a.jpg")); err != nil {
+		t.Fatalf("original not trashed by the owner: %v", err)
+	}
+}
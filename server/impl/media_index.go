@@ -0,0 +1,421 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// catalogReady is set once the startup rescan brought the media catalog in line with the disk.
+// Until then listings walk the disk.
+var catalogReady atomic.Bool
+
+// reconcileMu serializes reconciles of the media catalog.
+var reconcileMu sync.Mutex
+
+// useCatalog returns true if listings can answer from the media catalog.
+func useCatalog() bool {
+	return config.AuthDBPath != "" && catalogReady.Load()
+}
+
+// CatalogReport is the result of reconciling the media catalog with the disk.
+type CatalogReport struct {
+	Indexed int `json:"Indexed"`
+	Removed int `json:"Removed"`
+}
+
+// indexMediaFile adds the file rel of the device folder deviceDir to the media catalog, or updates it.
+// The content hash and the perceptual hash of images are computed again only when the size or
+// modification time changed.
+func indexMediaFile(deviceDir, rel string) {
+	if config.AuthDBPath == "" {
+		return
+	}
+	userId, deviceId, ok := deviceDirOwner(deviceDir)
+	if !ok {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	info, media, ok := mediaFileInfo(deviceDir, rel)
+	if !ok {
+		return
+	}
+	entry := store.MediaEntry{UserId: userId, DeviceId: deviceId, Path: info.Path, Size: info.Size,
+		ModifiedAt: info.ModifiedAt, MimeType: info.MimeType, MediaType: info.Type, Width: info.Width,
+		Height: info.Height, Duration: info.Duration, CapturedAt: info.CapturedAt, Make: media.Make,
+		Model: media.Model, Lens: media.Lens, HasLocation: media.HasLocation, Latitude: media.Latitude,
+		Longitude: media.Longitude, HasMetadata: info.Metadata, HasThumbnail: info.Thumbnail}
+	old, _ := store.GetMedia(userId, deviceId, rel)
+	unchanged := old != nil && old.Hash != "" && old.Size == entry.Size && old.ModifiedAt == entry.ModifiedAt
+	if unchanged {
+		entry.Hash = old.Hash
+	} else if hash, err := fileHash(filepath.Join(deviceDir, filepath.FromSlash(rel))); err == nil {
+		entry.Hash = hash
+	} else {
+		logger.ErrorF("Hash %s: %v", rel, err)
+	}
+	if entry.MediaType == "image" && entry.HasThumbnail {
+		if unchanged && old.PHash != "" {
+			entry.PHash = old.PHash
+		} else {
+			entry.PHash = thumbnailPHash(deviceDir, rel)
+		}
+	}
+	if err := store.UpsertMedia(entry); err != nil {
+		logger.ErrorF("Index %s: %v", rel, err)
+	}
+}
+
+// fileHash returns the hex SHA-256 of the content of the file p.
+func fileHash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// indexDeviceDir brings the media catalog of a device up to date from its folders deviceDirs
+// (see deviceFolders.dirs; a file in several folders is indexed from the first): files that are
+// new or changed since they were indexed, or whose metadata or thumbnail appeared or vanished, are
+// (re)indexed; files that are gone are removed. Returns the number of files indexed and removed.
+// If a folder cannot be listed completely, the files found are indexed but none are removed.
+func indexDeviceDir(deviceDirs ...string) (int, int, error) {
+	if len(deviceDirs) == 0 {
+		return 0, 0, nil
+	}
+	userId, deviceId, ok := deviceDirOwner(deviceDirs[0])
+	if !ok {
+		return 0, 0, nil
+	}
+	stamps, err := store.MediaStamps(userId, deviceId)
+	if err != nil {
+		return 0, 0, err
+	}
+	var listErr error
+	listed := make(map[string]bool)
+	indexed := 0
+	for _, deviceDir := range deviceDirs {
+		files, err := ListAllRelativeFiles(deviceDir)
+		trash, trashErr := ListTrashFiles(deviceDir)
+		if err == nil {
+			err = trashErr
+		}
+		if listErr == nil {
+			listErr = err
+		}
+		for _, rel := range append(files, trash...) {
+			if listed[rel] {
+				continue
+			}
+			stat, err := os.Stat(filepath.Join(deviceDir, filepath.FromSlash(rel)))
+			if err != nil {
+				continue
+			}
+			listed[rel] = true
+			if stamp, found := stamps[rel]; found && stamp.Size == stat.Size() && stamp.ModifiedAt == stat.ModTime().Unix() {
+				hasMetadata, thumbnail := derivedFilesExist(deviceDir, rel)
+				if stamp.HasMetadata == hasMetadata && stamp.HasThumbnail == thumbnail {
+					continue
+				}
+			}
+			indexMediaFile(deviceDir, rel)
+			indexed++
+		}
+	}
+	if listErr != nil {
+		// Files missing from a failed listing are not gone.
+		return indexed, 0, listErr
+	}
+	removed := 0
+	for rel := range stamps {
+		if !listed[rel] {
+			onFileDeleted(deviceDirs[0], rel)
+			removed++
+		}
+	}
+	return indexed, removed, nil
+}
+
+// deviceFolders are the folders holding the files of one device of a user: the folder under
+// the user id and, until the storage migration has moved all its files, the legacy folder under
+// the lowercased email.
+type deviceFolders struct {
+	userId   string
+	deviceId string
+	// folders are the user folders containing the device, the id folder first.
+	folders []string
+}
+
+// dirs returns the paths of the device folders, the id folder first.
+func (d deviceFolders) dirs() []string {
+	dirs := make([]string, len(d.folders))
+	for i, folder := range d.folders {
+		dirs[i] = filepath.Join(config.UploadDirectory, folder, d.deviceId)
+	}
+	return dirs
+}
+
+// listDeviceFolders returns the device folders under config.UploadDirectory grouped by user and
+// device. Callers hold storageMigrationMu so that no files move between the folders meanwhile.
+func listDeviceFolders() ([]deviceFolders, error) {
+	users, err := os.ReadDir(config.UploadDirectory)
+	if err != nil {
+		return nil, err
+	}
+	var devices []deviceFolders
+	index := make(map[string]int)
+	for _, u := range users {
+		if !u.IsDir() {
+			continue
+		}
+		userId := canonicalUserId(u.Name())
+		entries, _ := os.ReadDir(filepath.Join(config.UploadDirectory, u.Name()))
+		for _, d := range entries {
+			if !d.IsDir() {
+				continue
+			}
+			key := userId + "/" + d.Name()
+			i, found := index[key]
+			if !found {
+				i = len(devices)
+				index[key] = i
+				devices = append(devices, deviceFolders{userId: userId, deviceId: d.Name()})
+			}
+			if u.Name() == userId {
+				devices[i].folders = append([]string{u.Name()}, devices[i].folders...)
+			} else {
+				devices[i].folders = append(devices[i].folders, u.Name())
+			}
+		}
+	}
+	return devices, nil
+}
+
+// derivedFilesExist returns whether the metadata and the thumbnail of the file rel exist in deviceDir.
+func derivedFilesExist(deviceDir, rel string) (bool, bool) {
+	_, metaErr := os.Stat(MetadataPath(deviceDir, rel))
+	return metaErr == nil, hasThumbnail(deviceDir, rel, "image") || hasThumbnail(deviceDir, rel, "")
+}
+
+// ReconcileCatalog repairs the media catalog of all users against the disk: files uploaded before
+// the catalog existed, and files added, changed or deleted behind the server's back. Waits for a
+// running storage migration.
+func ReconcileCatalog() CatalogReport {
+	storageMigrationMu.Lock()
+	defer storageMigrationMu.Unlock()
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	var report CatalogReport
+	devices, err := listDeviceFolders()
+	if err != nil {
+		logger.ErrorF("Media catalog: %v", err)
+		return report
+	}
+	for _, d := range devices {
+		i, r, err := indexDeviceDir(d.dirs()...)
+		if err != nil {
+			logger.ErrorF("Media catalog of %s/%s: %v", d.userId, d.deviceId, err)
+		}
+		report.Indexed, report.Removed = report.Indexed+i, report.Removed+r
+	}
+	return report
+}
+
+// ReconcileCatalogHandler repairs the media catalog against the disk (admin only).
+// POST -> { "Indexed": 0, "Removed": 0 }
+func ReconcileCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ReconcileCatalog())
+}
+
+// catalogFolderFiles lists the files of folder like listFolderFiles does from the disk, or returns
+// false if the catalog is not ready.
+func catalogFolderFiles(user, deviceId, folder string) ([]string, bool) {
+	if !useCatalog() {
+		return nil, false
+	}
+	folder = strings.Trim(path.Clean("/"+filepath.ToSlash(folder)), "/")
+	entries, err := store.ListMediaFolder(canonicalUserId(user), deviceId, folder)
+	if err != nil {
+		logger.ErrorF("List %s from media catalog: %v", folder, err)
+		return nil, false
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, fileRefPath(deviceId, store.FileRef{DeviceId: e.DeviceId, Path: e.Path}))
+	}
+	return files, true
+}
+
+// catalogFolders returns the year and month folders like GetFoldersHandler does from the disk, or
+// false if the catalog is not ready.
+func catalogFolders(user, deviceId string) ([]folder, bool) {
+	if !useCatalog() {
+		return nil, false
+	}
+	paths, err := store.ListMediaFolders(canonicalUserId(user), deviceId)
+	if err != nil {
+		logger.ErrorF("List folders from media catalog: %v", err)
+		return nil, false
+	}
+	yearMonths := make(map[string]map[string]bool)
+	for _, p := range paths {
+		year, _, isMonth := strings.Cut(p, "/")
+		if yearMonths[year] == nil {
+			yearMonths[year] = make(map[string]bool)
+		}
+		if isMonth {
+			yearMonths[year][filepath.FromSlash(p)] = true
+		}
+	}
+	folders := make([]folder, 0, len(yearMonths))
+	for year, monthsSet := range yearMonths {
+		months := make([]string, 0, len(monthsSet))
+		for m := range monthsSet {
+			months = append(months, m)
+		}
+		sort.Strings(months)
+		folders = append(folders, folder{Year: year, Months: months})
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Year < folders[j].Year })
+	return folders, true
+}
+
+// catalogFileInfo returns the info of the file rel of the device folder deviceDir from the media
+// catalog, or false if the catalog is not ready or has no entry for it.
+func catalogFileInfo(deviceDir, rel string) (FileInfo, bool) {
+	if !useCatalog() {
+		return FileInfo{}, false
+	}
+	userId, deviceId, ok := deviceDirOwner(deviceDir)
+	if !ok {
+		return FileInfo{}, false
+	}
+	e, err := store.GetMedia(userId, deviceId, filepath.ToSlash(rel))
+	if err != nil || e == nil {
+		return FileInfo{}, false
+	}
+	return entryFileInfo(*e, e.Path), true
+}
+
+// entryFileInfo returns the info of the catalog entry e listed as p.
+func entryFileInfo(e store.MediaEntry, p string) FileInfo {
+	return FileInfo{Path: p, Size: e.Size, ModifiedAt: e.ModifiedAt, Type: e.MediaType, MimeType: e.MimeType,
+		Width: e.Width, Height: e.Height, Duration: e.Duration, CapturedAt: e.CapturedAt,
+		Metadata: e.HasMetadata, Thumbnail: e.HasThumbnail}
+}
+
+// searchRequest is the body of /search. Text filters match case-insensitive substrings; From and
+// To are unix seconds of the capture time. List options and annotation filters work as in /files/list.
+type searchRequest struct {
+	UserData    userData         `json:"UserData"`
+	From        int64            `json:"From"`
+	To          int64            `json:"To"`
+	Make        string           `json:"Make"`
+	Model       string           `json:"Model"`
+	Lens        string           `json:"Lens"`
+	Name        string           `json:"Name"`
+	MinWidth    int              `json:"MinWidth"`
+	MinHeight   int              `json:"MinHeight"`
+	MinDuration float64          `json:"MinDuration"`
+	MaxDuration float64          `json:"MaxDuration"`
+	Bounds      *store.GeoBounds `json:"Bounds"`
+	annotationFilter
+	listOptions
+}
+
+func (req *searchRequest) requestUser() string { return req.UserData.User }
+
+// SearchHandler finds the user's files outside Trash by the indexed metadata and returns them in
+// the format of /files/list. Paths are relative to DeviceId, or start with the device id without one.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "From": 0, "To": 0, "Make": "", "Model": "", "Lens": "",
+// "Name": "", "MinWidth": 0, "MinHeight": 0, "MinDuration": 0, "MaxDuration": 0,
+// "Bounds": { "North": 0, "South": 0, "East": 0, "West": 0 }, "Favorite": false, "MinRating": 0, "Tags": [],
+// "Type": "", "Sort": "captured", "Desc": false, "Limit": 100, "Cursor": "" } -> { "Files": [ ... ], "NextCursor": "" }
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	userId, ok := decodeUserRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	after, ok := req.listOptions.validate(w)
+	if !ok {
+		return
+	}
+	if req.Bounds != nil && !validBounds(req.Bounds) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	entries, err := store.SearchMedia(userId, store.MediaQuery{DeviceId: deviceId, From: req.From, To: req.To,
+		MediaType: req.Type, Make: req.Make, Model: req.Model, Lens: req.Lens, MinWidth: req.MinWidth,
+		MinHeight: req.MinHeight, MinDuration: req.MinDuration, MaxDuration: req.MaxDuration, Bounds: req.Bounds})
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	found := make(map[string]FileInfo, len(entries))
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if name != "" && !strings.Contains(strings.ToLower(path.Base(e.Path)), name) {
+			continue
+		}
+		p := fileRefPath(deviceId, store.FileRef{DeviceId: e.DeviceId, Path: e.Path})
+		found[p] = entryFileInfo(e, p)
+		paths = append(paths, p)
+	}
+	infos := make([]FileInfo, 0, len(paths))
+	for _, p := range filterAnnotated(userId, deviceId, paths, req.annotationFilter) {
+		infos = append(infos, found[p])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(req.listOptions.page(infos, after))
+}
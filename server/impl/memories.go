@@ -0,0 +1,226 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Limits of /memories.
+const (
+	defaultMemoriesPerYear = 6
+	maxMemoriesPerYear     = 50
+	maxMemoriesWindow      = 30
+	// memoriesBurstSeconds: files captured this close to a picked one show the same moment.
+	memoriesBurstSeconds = 10 * 60
+)
+
+type memoriesRequest struct {
+	UserData userData `json:"UserData"`
+	// Date is the day to remember, "2006-01-02" (default: today on the server).
+	Date string `json:"Date"`
+	// Window adds this many days before and after the day (0-30).
+	Window int `json:"Window"`
+	// PerYear is the most files picked per year (default 6, at most 50).
+	PerYear int `json:"PerYear"`
+}
+
+// MemoryYear is the files of an earlier year captured around the same day.
+type MemoryYear struct {
+	Year     int `json:"Year"`
+	YearsAgo int `json:"YearsAgo"`
+	// Count is the number of photos and videos of the year in the window; Files are the picked ones,
+	// oldest first.
+	Count int        `json:"Count"`
+	Files []FileInfo `json:"Files"`
+}
+
+type memoriesResponse struct {
+	Date  string       `json:"Date"`
+	Years []MemoryYear `json:"Years"`
+}
+
+// memoryCandidate is a file in the window with its curation score.
+type memoryCandidate struct {
+	Info  FileInfo
+	Score int
+}
+
+// anniversary returns the day of date in year; February 29 falls on February 28 in other years.
+func anniversary(date time.Time, year int) time.Time {
+	day := date.Day()
+	if date.Month() == time.February && day == 29 && time.Date(year, time.March, 0, 0, 0, 0, 0, time.UTC).Day() != 29 {
+		day = 28
+	}
+	return time.Date(year, date.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// memoryYear returns the earlier year whose anniversary of date is at most window days from the
+// capture time capturedAt (unix seconds), or false if there is none.
+func memoryYear(date time.Time, window int, capturedAt int64) (int, bool) {
+	t := time.Unix(capturedAt, 0).UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	// A window around New Year reaches into the neighboring year.
+	for year := t.Year() - 1; year <= t.Year()+1 && year < date.Year(); year++ {
+		days := day.Sub(anniversary(date, year)).Hours() / 24
+		if days >= -float64(window) && days <= float64(window) {
+			return year, true
+		}
+	}
+	return 0, false
+}
+
+// pickMemories picks at most perYear of the candidates: favorites and highly rated files first,
+// skipping files of the same moment as a picked one and files isDocument rejects. The picks are
+// returned oldest first.
+func pickMemories(candidates []memoryCandidate, perYear int, isDocument func(FileInfo) bool) []FileInfo {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Info.CapturedAt != b.Info.CapturedAt {
+			return a.Info.CapturedAt < b.Info.CapturedAt
+		}
+		return a.Info.Path < b.Info.Path
+	})
+	picked := make([]FileInfo, 0, perYear)
+	for _, c := range candidates {
+		if len(picked) == perYear {
+			break
+		}
+		sameMoment := false
+		for _, p := range picked {
+			if d := c.Info.CapturedAt - p.CapturedAt; d > -memoriesBurstSeconds && d < memoriesBurstSeconds {
+				sameMoment = true
+				break
+			}
+		}
+		if !sameMoment && !isDocument(c.Info) {
+			picked = append(picked, c.Info)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].CapturedAt < picked[j].CapturedAt })
+	return picked
+}
+
+// memoryScore rates a file for curation: favorites, then ratings, then files with a thumbnail.
+func memoryScore(info FileInfo, a *store.Annotation) int {
+	score := 0
+	if info.Thumbnail {
+		score++
+	}
+	if a != nil {
+		score += a.Rating * 2
+		if a.Favorite {
+			score += 20
+		}
+	}
+	return score
+}
+
+// MemoriesHandler returns photos and videos captured on the same day (± Window days) in earlier
+// years, across all devices of the user (or one with DeviceId), newest year first. Each year holds
+// a small curated set: favorites and highly rated files first, one per moment, no document-like
+// images. Trash is excluded.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Date": "2024-07-01", "Window": 0, "PerYear": 6 }
+// -> { "Date": "2024-07-01", "Years": [ { "Year": 2023, "YearsAgo": 1, "Count": 0, "Files": [ ...as /files/list... ] } ] }
+func MemoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req memoriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	user := req.UserData.User
+	if user == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Date == "" {
+		req.Date = time.Now().Format(time.DateOnly)
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		utils.RenderError(w, InvalidListOption("Date", req.Date), http.StatusBadRequest)
+		return
+	}
+	if req.Window < 0 || req.Window > maxMemoriesWindow {
+		utils.RenderError(w, InvalidListOption("Window", strconv.Itoa(req.Window)), http.StatusBadRequest)
+		return
+	}
+	if req.PerYear <= 0 {
+		req.PerYear = defaultMemoriesPerYear
+	}
+	req.PerYear = min(req.PerYear, maxMemoriesPerYear)
+	if _, ok := authorizeUserAccess(w, r, user); !ok {
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	annotations := make(map[store.FileRef]*store.Annotation)
+	if config.AuthDBPath != "" {
+		if annotations, err = store.ListAnnotations(canonicalUserId(user), deviceId); err != nil {
+			logger.ErrorF("Annotations of %s: %v", user, err)
+		}
+	}
+	candidates := make(map[int][]memoryCandidate)
+	for _, info := range listUserFiles(user, deviceId) {
+		if info.Type != "image" && info.Type != "video" {
+			continue
+		}
+		if year, ok := memoryYear(date, req.Window, info.CapturedAt); ok {
+			ref, _ := fileRefFromPath(deviceId, info.Path)
+			candidates[year] = append(candidates[year], memoryCandidate{Info: info, Score: memoryScore(info, annotations[ref])})
+		}
+	}
+	isDocument := func(info FileInfo) bool {
+		dir, rel, ok := listedFileDir(user, deviceId, info.Path)
+		if !ok || info.Type != "image" {
+			return false
+		}
+		// The thumbnail is enough to tell and much faster to decode.
+		image := ThumbnailBasePath(dir, rel)
+		if _, err := os.Stat(image); err != nil {
+			image = filepath.Join(dir, filepath.FromSlash(rel))
+		}
+		return LooksLikeDocument(image)
+	}
+	resp := memoriesResponse{Date: date.Format(time.DateOnly), Years: make([]MemoryYear, 0, len(candidates))}
+	for year, c := range candidates {
+		if files := pickMemories(c, req.PerYear, isDocument); len(files) > 0 {
+			resp.Years = append(resp.Years, MemoryYear{Year: year, YearsAgo: date.Year() - year, Count: len(c), Files: files})
+		}
+	}
+	sort.Slice(resp.Years, func(i, j int) bool { return resp.Years[i].Year > resp.Years[j].Year })
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,75 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryYear(t *testing.T) {
+	unix := func(s string) int64 {
+		tm, _ := time.Parse(time.DateTime, s)
+		return tm.Unix()
+	}
+	cases := []struct {
+		date     string
+		window   int
+		captured string
+		year     int
+		ok       bool
+	}{
+		{"2024-07-01", 0, "2021-07-01 23:59:59", 2021, true},
+		{"2024-07-01", 0, "2021-07-02 00:00:00", 0, false},
+		{"2024-07-01", 2, "2021-06-29 08:00:00", 2021, true},
+		{"2024-07-01", 0, "2024-07-01 08:00:00", 0, false}, // this year
+		{"2024-01-02", 3, "2022-12-31 10:00:00", 2023, true},
+		{"2024-12-31", 3, "2024-01-01 10:00:00", 2023, true},
+		{"2024-12-31", 3, "2023-01-01 10:00:00", 2022, true},
+		{"2024-02-29", 0, "2023-02-28 10:00:00", 2023, true},
+		{"2028-02-29", 0, "2024-02-29 10:00:00", 2024, true},
+	}
+	for _, c := range cases {
+		date, _ := time.Parse(time.DateOnly, c.date)
+		year, ok := memoryYear(date, c.window, unix(c.captured))
+		if year != c.year || ok != c.ok {
+			t.Errorf("memoryYear(%s, %d, %s) = %d, %v", c.date, c.window, c.captured, year, ok)
+		}
+	}
+	date, _ := time.Parse(time.DateOnly, "2024-07-01")
+	if year, ok := memoryYear(date, 0, parseMetadataTime("2021:07:01 00:30:00+02:00")); year != 2021 || !ok {
+		t.Errorf("memoryYear with offset = %d, %v", year, ok)
+	}
+}
+
+func TestPickMemories(t *testing.T) {
+	candidates := []memoryCandidate{
+		{Info: FileInfo{Path: "a.jpg", CapturedAt: 1000}, Score: 1},
+		{Info: FileInfo{Path: "burst.jpg", CapturedAt: 1060}, Score: 21},
+		{Info: FileInfo{Path: "doc.jpg", CapturedAt: 5000}, Score: 11},
+		{Info: FileInfo{Path: "b.jpg", CapturedAt: 9000}, Score: 5},
+		{Info: FileInfo{Path: "c.jpg", CapturedAt: 20000}, Score: 1},
+	}
+	isDocument := func(info FileInfo) bool { return info.Path == "doc.jpg" }
+	picked := pickMemories(candidates, 2, isDocument)
+	if len(picked) != 2 || picked[0].Path != "burst.jpg" || picked[1].Path != "b.jpg" {
+		t.Fatalf("picked %+v", picked)
+	}
+	picked = pickMemories(candidates, 10, isDocument)
+	if len(picked) != 3 || picked[0].Path != "burst.jpg" || picked[2].Path != "c.jpg" {
+		t.Fatalf("picked %+v", picked)
+	}
+}
@@ -19,6 +19,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/barasher/go-exiftool"
 	"github.com/takecontrolsoft/sync_server/server/config"
@@ -60,23 +63,225 @@ func ExtractMetadata(userName string, deviceId string, file string) (string, err
 	return metadataPath, nil
 }
 
-// GetOrientationFromMetadata reads the EXIF Orientation (1-8) from a metadata JSON file.
-// Returns 1 (normal) if the file is missing, invalid, or Orientation is absent.
-func GetOrientationFromMetadata(metadataPath string) int {
+// MediaInfo is the part of the exiftool metadata of a file that listings need.
+type MediaInfo struct {
+	// Type is "image", "video" or "audio" ("" if unknown), from the MIME type.
+	Type     string
+	MimeType string
+	// Width and Height are the pixel dimensions as stored, before EXIF orientation.
+	Width  int
+	Height int
+	// Duration of videos and audio in seconds.
+	Duration float64
+	// CapturedAt is the capture time (unix seconds), 0 if the metadata has none. Times without a
+	// UTC offset are taken as UTC, so their date is the one shown on the device.
+	CapturedAt int64
+	// Make, Model and Lens of the camera.
+	Make  string
+	Model string
+	Lens  string
+	// Latitude and Longitude in decimal degrees if HasLocation.
+	Latitude    float64
+	Longitude   float64
+	HasLocation bool
+}
+
+// Metadata fields with the capture time, in order of preference.
+var captureTimeFields = []string{"SubSecDateTimeOriginal", "DateTimeOriginal", "CreateDate", "MediaCreateDate", "TrackCreateDate"}
+
+// readMetadataFields returns the exiftool fields of a metadata JSON file, or nil if the file is
+// missing or invalid.
+func readMetadataFields(metadataPath string) map[string]interface{} {
 	data, err := os.ReadFile(metadataPath)
 	if err != nil {
-		return 1
+		return nil
 	}
 	var fileInfos []struct {
 		Fields map[string]interface{} `json:"Fields"`
 	}
-	if err := json.Unmarshal(data, &fileInfos); err != nil {
-		return 1
+	if err := json.Unmarshal(data, &fileInfos); err != nil || len(fileInfos) == 0 {
+		return nil
 	}
-	if len(fileInfos) == 0 || fileInfos[0].Fields == nil {
+	return fileInfos[0].Fields
+}
+
+// ReadMediaInfo reads the media info from a metadata JSON file written by ExtractMetadata.
+// Returns false if the file is missing or invalid.
+func ReadMediaInfo(metadataPath string) (MediaInfo, bool) {
+	fields := readMetadataFields(metadataPath)
+	if fields == nil {
+		return MediaInfo{}, false
+	}
+	return mediaInfoFromFields(fields), true
+}
+
+func mediaInfoFromFields(fields map[string]interface{}) MediaInfo {
+	var info MediaInfo
+	info.MimeType, _ = fields["MIMEType"].(string)
+	info.Type = mediaTypeName(info.MimeType)
+	info.Width = int(metadataNumber(fields["ImageWidth"]))
+	info.Height = int(metadataNumber(fields["ImageHeight"]))
+	if info.Width == 0 || info.Height == 0 {
+		info.Width = int(metadataNumber(fields["ExifImageWidth"]))
+		info.Height = int(metadataNumber(fields["ExifImageHeight"]))
+	}
+	info.Duration = parseMetadataDuration(fields["Duration"])
+	for _, name := range captureTimeFields {
+		if s, ok := fields[name].(string); ok {
+			if t := parseMetadataTime(s); t != 0 {
+				info.CapturedAt = t
+				break
+			}
+		}
+	}
+	info.Make = firstMetadataString(fields, "Make", "AndroidMake")
+	info.Model = firstMetadataString(fields, "Model", "AndroidModel")
+	info.Lens = firstMetadataString(fields, "LensModel", "Lens", "LensID")
+	info.Latitude, info.Longitude, info.HasLocation = metadataLocation(fields)
+	return info
+}
+
+// firstMetadataString returns the first non-empty string of the given fields.
+func firstMetadataString(fields map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if s, ok := fields[name].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// metadataLocation returns the GPS position of the metadata: GPSLatitude/GPSLongitude of photos,
+// else GPSPosition or GPSCoordinates ("lat, lon[, altitude]") of videos.
+func metadataLocation(fields map[string]interface{}) (float64, float64, bool) {
+	lat, okLat := parseGPSCoordinate(fields["GPSLatitude"], fields["GPSLatitudeRef"])
+	lon, okLon := parseGPSCoordinate(fields["GPSLongitude"], fields["GPSLongitudeRef"])
+	if !okLat || !okLon {
+		position := firstMetadataString(fields, "GPSPosition", "GPSCoordinates")
+		parts := strings.Split(position, ",")
+		if len(parts) < 2 {
+			return 0, 0, false
+		}
+		lat, okLat = parseGPSCoordinate(parts[0], nil)
+		lon, okLon = parseGPSCoordinate(parts[1], nil)
+	}
+	if !okLat || !okLon || lat < -90 || lat > 90 || lon < -180 || lon > 180 || (lat == 0 && lon == 0) {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseGPSCoordinate parses a coordinate in decimal degrees (41.4034) or as exiftool prints it
+// (41 deg 24' 12.20" N). S and W, in the value or in ref ("South", "West"), make it negative.
+func parseGPSCoordinate(v, ref interface{}) (float64, bool) {
+	var value float64
+	negative := false
+	switch c := v.(type) {
+	case float64:
+		value = c
+	case string:
+		c = strings.TrimSpace(c)
+		if c == "" {
+			return 0, false
+		}
+		switch c[len(c)-1] {
+		case 'S', 'W':
+			negative = true
+			c = c[:len(c)-1]
+		case 'N', 'E':
+			c = c[:len(c)-1]
+		}
+		fields := strings.Fields(strings.NewReplacer("deg", " ", "'", " ", `"`, " ").Replace(c))
+		if len(fields) == 0 || len(fields) > 3 {
+			return 0, false
+		}
+		for i, f := range fields {
+			n, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return 0, false
+			}
+			value += n / []float64{1, 60, 3600}[i]
+		}
+	default:
+		return 0, false
+	}
+	if r, ok := ref.(string); ok && (strings.HasPrefix(r, "S") || strings.HasPrefix(r, "W")) {
+		negative = true
+	}
+	if negative && value > 0 {
+		value = -value
+	}
+	return value, true
+}
+
+// mediaTypeName returns "image", "video" or "audio" for a MIME type, else "".
+func mediaTypeName(mimeType string) string {
+	kind, _, _ := strings.Cut(mimeType, "/")
+	switch kind {
+	case "image", "video", "audio":
+		return kind
+	}
+	return ""
+}
+
+// metadataNumber returns a numeric metadata value, or 0.
+func metadataNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f
+	}
+	return 0
+}
+
+// parseMetadataDuration parses exiftool durations: seconds as a number, "12.34 s" or "0:01:23",
+// optionally followed by " (approx)". Returns 0 if v is not a duration.
+func parseMetadataDuration(v interface{}) float64 {
+	s, ok := v.(string)
+	if !ok {
+		return metadataNumber(v)
+	}
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "(approx)"))
+	if strings.HasSuffix(s, " s") {
+		return metadataNumber(strings.TrimSuffix(s, " s"))
+	}
+	var seconds float64
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// parseMetadataTime parses exiftool date/times like "2024:07:01 12:34:56", optionally with
+// sub-seconds and a UTC offset ("2024:07:01 12:34:56.12+02:00"). The offset is dropped: capture
+// times keep the wall-clock time of the device, as unix seconds of that time in UTC, so that
+// UTC dates are the dates shown on the device. Returns 0 for invalid or zero dates.
+func parseMetadataTime(s string) int64 {
+	s = strings.TrimSpace(s)
+	if len(s) < 19 {
+		return 0
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", s[:19])
+	if err != nil || t.Year() < 1900 {
+		return 0
+	}
+	return t.Unix()
+}
+
+// GetOrientationFromMetadata reads the EXIF Orientation (1-8) from a metadata JSON file.
+// Returns 1 (normal) if the file is missing, invalid, or Orientation is absent.
+func GetOrientationFromMetadata(metadataPath string) int {
+	fields := readMetadataFields(metadataPath)
+	if fields == nil {
 		return 1
 	}
-	o := fileInfos[0].Fields["Orientation"]
+	o := fields["Orientation"]
 	if o == nil {
 		return 1
 	}
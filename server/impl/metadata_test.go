@@ -0,0 +1,89 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import "testing"
+
+func TestParseMetadataTime(t *testing.T) {
+	cases := map[string]int64{
+		"2024:07:01 12:00:00":           1719835200,
+		"2024:07:01 12:00:00Z":          1719835200,
+		"2024:07:01 12:00:00+02:00":     1719835200,
+		"2024:07:01 12:00:00.123+02:00": 1719835200,
+		"2024:07:01 12:00:00-05:00":     1719835200,
+		"0000:00:00 00:00:00":           0,
+		"2024:07":                       0,
+	}
+	for in, want := range cases {
+		if got := parseMetadataTime(in); got != want {
+			t.Errorf("parseMetadataTime(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestParseMetadataDuration(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want float64
+	}{
+		{12.5, 12.5},
+		{"12.5 s", 12.5},
+		{"0:01:23", 83},
+		{"1:00:00 (approx)", 3600},
+		{"unknown", 0},
+		{nil, 0},
+	}
+	for _, c := range cases {
+		if got := parseMetadataDuration(c.in); got != c.want {
+			t.Errorf("parseMetadataDuration(%v) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMediaInfoFromFields(t *testing.T) {
+	info := mediaInfoFromFields(map[string]interface{}{
+		"MIMEType":         "video/mp4",
+		"ImageWidth":       1920.0,
+		"ImageHeight":      1080.0,
+		"Duration":         "0:00:30",
+		"DateTimeOriginal": "0000:00:00 00:00:00",
+		"CreateDate":       "2024:07:01 12:00:00",
+	})
+	want := MediaInfo{Type: "video", MimeType: "video/mp4", Width: 1920, Height: 1080, Duration: 30, CapturedAt: 1719835200}
+	if info != want {
+		t.Fatalf("mediaInfoFromFields = %+v, want %+v", info, want)
+	}
+}
+
+func TestMetadataLocation(t *testing.T) {
+	cases := []struct {
+		fields   map[string]interface{}
+		lat, lon float64
+		ok       bool
+	}{
+		{map[string]interface{}{"GPSLatitude": `41 deg 30' 0.00" N`, "GPSLongitude": `2 deg 15' 0.00" W`}, 41.5, -2.25, true},
+		{map[string]interface{}{"GPSLatitude": 41.5, "GPSLatitudeRef": "South", "GPSLongitude": 2.25, "GPSLongitudeRef": "East"}, -41.5, 2.25, true},
+		{map[string]interface{}{"GPSCoordinates": `41 deg 30' 0.00" S, 2 deg 15' 0.00" E, 12 m Above Sea Level`}, -41.5, 2.25, true},
+		{map[string]interface{}{"GPSPosition": "0, 0"}, 0, 0, false},
+		{map[string]interface{}{"GPSLatitude": "north"}, 0, 0, false},
+	}
+	for _, c := range cases {
+		lat, lon, ok := metadataLocation(c.fields)
+		if ok != c.ok || lat != c.lat || lon != c.lon {
+			t.Errorf("metadataLocation(%v) = %v, %v, %v", c.fields, lat, lon, ok)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/oidc"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// oidcLoginTimeout is how long a started OIDC login (state, nonce, PKCE verifier) stays valid.
+const oidcLoginTimeout = 10 * time.Minute
+
+type oidcPendingLogin struct {
+	nonce    string
+	verifier string
+	// invite is the invite code to consume if the login provisions a new user.
+	invite  string
+	expires time.Time
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+	oidcPending  = make(map[string]oidcPendingLogin)
+)
+
+// getOIDCProvider returns the configured provider, running discovery on first use.
+// A failed discovery is retried on the next login.
+func getOIDCProvider() (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	p, err := oidc.Discover(config.OIDCIssuer, config.OIDCClientID, config.OIDCClientSecret, config.OIDCRedirectURL, config.OIDCScopes)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = p
+	return p, nil
+}
+
+// OIDCLoginHandler starts an OpenID Connect login and redirects to the identity provider.
+// GET /auth/oidc/login[?invite=<code>] (the invite code is needed to provision a new user while
+// registration is invite-only)
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if config.OIDCIssuer == "" {
+		utils.RenderError(w, OIDCNotConfigured, http.StatusNotFound)
+		return
+	}
+	p, err := getOIDCProvider()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusBadGateway)
+		return
+	}
+	state, err := oidc.RandomString(16)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	oidcMu.Lock()
+	for s, pending := range oidcPending {
+		if now.After(pending.expires) {
+			delete(oidcPending, s)
+		}
+	}
+	oidcPending[state] = oidcPendingLogin{nonce: nonce, verifier: verifier,
+		invite: strings.TrimSpace(r.URL.Query().Get("invite")), expires: now.Add(oidcLoginTimeout)}
+	oidcMu.Unlock()
+	http.Redirect(w, r, p.AuthCodeURL(state, nonce, challenge), http.StatusFound)
+}
+
+// OIDCCallbackHandler completes an OpenID Connect login: exchanges the code, validates the
+// ID token and returns a session token for the linked (or newly provisioned) local user.
+// GET /auth/oidc/callback?code=...&state=... -> { "Token": "", "UserId": "" }
+// As with /auth/login, users with two-factor authentication get a challenge for /auth/login/2fa instead.
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if config.OIDCIssuer == "" {
+		utils.RenderError(w, OIDCNotConfigured, http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	if idpErr := q.Get("error"); idpErr != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(idpErr))
+		return
+	}
+	state := q.Get("state")
+	code := q.Get("code")
+	oidcMu.Lock()
+	pending, ok := oidcPending[state]
+	delete(oidcPending, state)
+	oidcMu.Unlock()
+	if !ok || code == "" || time.Now().After(pending.expires) {
+		utils.RenderError(w, OIDCInvalidState, http.StatusBadRequest)
+		return
+	}
+	p, err := getOIDCProvider()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusBadGateway)
+		return
+	}
+	tokens, err := p.Exchange(code, pending.verifier)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusUnauthorized)
+		return
+	}
+	claims, err := p.VerifyIDToken(tokens.IDToken, pending.nonce)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if claims.Email == "" && tokens.AccessToken != "" {
+		// The email may only be released through the userinfo endpoint.
+		if info, err := p.UserInfo(tokens.AccessToken); err == nil && info.Subject == claims.Subject {
+			claims.Email = info.Email
+			claims.EmailVerified = info.EmailVerified
+		}
+	}
+	userId, err := resolveOIDCUser(p.Issuer, claims, pending.invite)
+	if err == RegistrationClosed || err == InviteCodeRequired || err == store.ErrInvalidInvite {
+		audit(r, "", "", AuditLoginOIDC, nil, auditDenied, claims.Email+": "+err.Error())
+		utils.RenderError(w, err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if userId == "" {
+		audit(r, "", "", AuditLoginOIDC, nil, auditDenied, claims.Email+": not linked")
+		utils.RenderError(w, OIDCAccountNotLinked, http.StatusForbidden)
+		return
+	}
+	if store.IsUserDisabled(userId) {
+		audit(r, userId, "", AuditLoginOIDC, nil, auditDenied, "disabled")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if enrolled := store.HasTOTPEnabled(userId); enrolled || require2FA() {
+		audit(r, userId, "", AuditLoginOIDC, nil, auditOK, "second factor required")
+		renderTwoFactorChallenge(w, userId, !enrolled)
+		return
+	}
+	token, err := store.CreateToken(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, userId, "", AuditLoginOIDC, nil, auditOK, "")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(loginResponse{Token: token, UserId: userId})
+}
+
+// resolveOIDCUser returns the local user id for the identity: an already linked user, else an
+// existing user with the same verified email (linked now), else a new user when auto-provisioning
+// is enabled. Returns empty string if the identity cannot be mapped to a local user.
+// New users are subject to the registration mode: RegistrationClosed while registration is
+// disabled, and InviteCodeRequired or store.ErrInvalidInvite without a usable inviteCode while
+// it is invite-only.
+func resolveOIDCUser(issuer string, claims *oidc.Claims, inviteCode string) (string, error) {
+	if userId := store.GetUserIdByOIDCIdentity(issuer, claims.Subject); userId != "" {
+		return userId, nil
+	}
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return "", nil
+	}
+	userId := store.GetUserIdByEmail(email)
+	if userId == "" {
+		if !config.OIDCAutoProvision {
+			return "", nil
+		}
+		switch registrationMode() {
+		case config.RegistrationDisabled:
+			return "", RegistrationClosed
+		case config.RegistrationInvite:
+			if inviteCode == "" {
+				return "", InviteCodeRequired
+			}
+		default:
+			inviteCode = ""
+		}
+		var err error
+		userId, err = store.CreateExternalUser(strings.ToLower(email), inviteCode)
+		if err != nil {
+			return "", err
+		}
+		logger.InfoF("OIDC: provisioned user %s", email)
+	}
+	if err := store.LinkOIDCIdentity(issuer, claims.Subject, userId); err != nil {
+		return "", err
+	}
+	return userId, nil
+}
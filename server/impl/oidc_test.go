@@ -0,0 +1,178 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// startTestIdP runs an identity provider that answers every code with an ID token for email
+// and points the OIDC config at it.
+func startTestIdP(t *testing.T, email, nonce string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		payload, _ := json.Marshal(map[string]interface{}{
+			"iss":            server.URL,
+			"sub":            "subject-1",
+			"aud":            config.OIDCClientID,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          nonce,
+			"email":          email,
+			"email_verified": true,
+		})
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     signed + "." + base64.RawURLEncoding.EncodeToString(sig),
+		})
+	})
+
+	issuer, clientId, redirect := config.OIDCIssuer, config.OIDCClientID, config.OIDCRedirectURL
+	config.OIDCIssuer, config.OIDCClientID = server.URL, "sync-server"
+	config.OIDCRedirectURL = "http://localhost/auth/oidc/callback"
+	t.Cleanup(func() {
+		config.OIDCIssuer, config.OIDCClientID, config.OIDCRedirectURL = issuer, clientId, redirect
+		oidcMu.Lock()
+		oidcProvider = nil
+		oidcMu.Unlock()
+	})
+}
+
+// oidcCallback runs a callback for a login started with nonce and invite and returns the decoded response.
+func oidcCallback(t *testing.T, nonce, invite string) (int, map[string]interface{}) {
+	t.Helper()
+	oidcMu.Lock()
+	oidcPending["state-1"] = oidcPendingLogin{nonce: nonce, verifier: "verifier", invite: invite, expires: time.Now().Add(time.Minute)}
+	oidcMu.Unlock()
+	rr := httptest.NewRecorder()
+	OIDCCallbackHandler(rr, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=c&state=state-1", nil))
+	resp := make(map[string]interface{})
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr.Code, resp
+}
+
+func TestOIDCCallbackHandler_twoFactor(t *testing.T) {
+	openTestAuthDB(t)
+	startTestIdP(t, "mum@example.com", "n-1")
+	userId, err := store.CreateUser("mum@example.com", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := oidcCallback(t, "n-1", "")
+	if code != http.StatusOK || resp["Token"] == nil || resp["Token"] == "" {
+		t.Fatalf("login without TOTP: %d %v", code, resp)
+	}
+
+	if err := store.SetPendingTOTP(userId, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EnableTOTP(userId); err != nil {
+		t.Fatal(err)
+	}
+	code, resp = oidcCallback(t, "n-1", "")
+	if code != http.StatusOK || resp["TwoFactorRequired"] != true || resp["Challenge"] == "" {
+		t.Fatalf("login with TOTP: %d %v", code, resp)
+	}
+	if token, ok := resp["Token"]; ok && token != "" {
+		t.Errorf("login with TOTP returned a session token %v", token)
+	}
+}
+
+func TestOIDCCallbackHandler_registrationMode(t *testing.T) {
+	openTestAuthDB(t)
+	startTestIdP(t, "new@example.com", "n-1")
+	restoreMode, restoreProvision := config.RegistrationMode, config.OIDCAutoProvision
+	config.OIDCAutoProvision = true
+	defer func() { config.RegistrationMode, config.OIDCAutoProvision = restoreMode, restoreProvision }()
+	if err := store.CreateInvite("invite-1", "admin", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		invite string
+		want   int
+	}{
+		{"disabled", config.RegistrationDisabled, "invite-1", http.StatusForbidden},
+		{"invite without code", config.RegistrationInvite, "", http.StatusForbidden},
+		{"invite with unknown code", config.RegistrationInvite, "nope", http.StatusForbidden},
+		{"invite", config.RegistrationInvite, "invite-1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		config.RegistrationMode = tt.mode
+		code, resp := oidcCallback(t, "n-1", tt.invite)
+		if code != tt.want {
+			t.Fatalf("%s: got status %d, want %d", tt.name, code, tt.want)
+		}
+		if provisioned := store.GetUserIdByEmail("new@example.com") != ""; provisioned != (tt.want == http.StatusOK) {
+			t.Fatalf("%s: user provisioned = %v (%v)", tt.name, provisioned, resp)
+		}
+	}
+}
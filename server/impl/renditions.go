@@ -0,0 +1,221 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Thumbnail rendition formats with the extension and Content-Type of their files.
+const (
+	renditionJPEG = "jpeg"
+	renditionWebP = "webp"
+)
+
+var renditionExtensions = map[string]string{renditionJPEG: ".jpg", renditionWebP: ".webp"}
+
+var renditionContentTypes = map[string]string{renditionJPEG: "image/jpeg", renditionWebP: "image/webp"}
+
+// renditionQuality is the JPEG and WebP quality of renditions.
+const renditionQuality = 85
+
+// thumbnailRendition is a thumbnail size: Square crops to Size x Size, else the image is fit
+// into a Size x Size box (never enlarged).
+type thumbnailRendition struct {
+	Name   string
+	Square bool
+	Size   int
+}
+
+// parseRendition parses a size name such as "s250" or "f1280".
+func parseRendition(name string) (thumbnailRendition, bool) {
+	if !config.IsValidThumbnailSize(name) {
+		return thumbnailRendition{}, false
+	}
+	size, _ := strconv.Atoi(name[1:])
+	return thumbnailRendition{Name: name, Square: name[0] == 's', Size: size}, true
+}
+
+// thumbnailRenditions returns the configured renditions.
+func thumbnailRenditions() []thumbnailRendition {
+	renditions := make([]thumbnailRendition, 0, len(config.ThumbnailSizes))
+	for _, name := range config.ThumbnailSizes {
+		if r, ok := parseRendition(name); ok {
+			renditions = append(renditions, r)
+		}
+	}
+	return renditions
+}
+
+// pickRendition returns the configured rendition for a requested size name: the smallest one of
+// the same shape at least as large, else the largest one of that shape, else the closest one of
+// the other shape. Returns false if no renditions are configured.
+func pickRendition(requested thumbnailRendition) (thumbnailRendition, bool) {
+	var best thumbnailRendition
+	found := false
+	better := func(r thumbnailRendition) bool {
+		if r.Square != best.Square {
+			return r.Square == requested.Square
+		}
+		if (r.Size >= requested.Size) != (best.Size >= requested.Size) {
+			return r.Size >= requested.Size
+		}
+		if r.Size >= requested.Size {
+			return r.Size < best.Size
+		}
+		return r.Size > best.Size
+	}
+	for _, r := range thumbnailRenditions() {
+		if !found || better(r) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// renditionPath returns the file of rendition r in format next to the thumbnail base
+// (see [ThumbnailBasePath]), e.g. "Thumbnails/2024/07/photo.jpg@s250.jpg".
+func renditionPath(base string, r thumbnailRendition, format string) string {
+	return base + "@" + r.Name + renditionExtensions[format]
+}
+
+// renditionSuffix returns the "@s250.jpg" suffix of a rendition file name, or "".
+func renditionSuffix(name string) string {
+	at := strings.LastIndex(name, "@")
+	if at < 0 {
+		return ""
+	}
+	suffix := name[at:]
+	for _, ext := range renditionExtensions {
+		if size, ok := strings.CutSuffix(suffix[1:], ext); ok && config.IsValidThumbnailSize(size) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// renditionFiles returns the rendition files that exist next to the thumbnail base, whatever
+// sizes and formats were configured when they were created.
+func renditionFiles(base string) []string {
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.Base(base) + "@"
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, prefix) && renditionSuffix(name) == name[len(prefix)-1:] {
+			files = append(files, filepath.Join(filepath.Dir(base), name))
+		}
+	}
+	return files
+}
+
+// moveRenditions moves the renditions of the thumbnail base srcBase next to dstBase.
+func moveRenditions(srcBase, dstBase string) {
+	for _, src := range renditionFiles(srcBase) {
+		_ = moveFile(src, dstBase+strings.TrimPrefix(src, srcBase))
+	}
+}
+
+// fitImage resizes src to fit rendition r.
+func fitImage(src image.Image, r thumbnailRendition) image.Image {
+	if r.Square {
+		return imaging.Fill(src, r.Size, r.Size, imaging.Center, imaging.Lanczos)
+	}
+	return resizeMaxLongEdge(src, r.Size)
+}
+
+// saveRenditions writes the configured renditions of src next to the thumbnail base. A WebP
+// rendition that fails (e.g. ffmpeg without libwebp) is logged and skipped.
+func saveRenditions(src image.Image, base string) error {
+	if err := os.MkdirAll(filepath.Dir(base), os.ModePerm); err != nil {
+		return err
+	}
+	for _, r := range thumbnailRenditions() {
+		img := fitImage(src, r)
+		for _, format := range config.ThumbnailFormats {
+			p := renditionPath(base, r, format)
+			switch format {
+			case renditionJPEG:
+				if err := writeFileAtomic(p, func(f *os.File) error {
+					return jpeg.Encode(f, img, &jpeg.Options{Quality: renditionQuality})
+				}); err != nil {
+					return err
+				}
+			case renditionWebP:
+				if err := writeFileAtomic(p, func(f *os.File) error { return encodeWebP(img, f) }); err != nil {
+					logger.ErrorF("WebP thumbnail %s: %v", p, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// renditionTempPrefix starts the names of thumbnail files being written.
+const renditionTempPrefix = ".tmp-"
+
+// writeFileAtomic writes path through a temporary file, so readers never see a partial file.
+func writeFileAtomic(path string, write func(*os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), renditionTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// encodeWebP encodes img as WebP into f with ffmpeg.
+func encodeWebP(img image.Image, f *os.File) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return ffmpeg.Input("pipe:", ffmpeg.KwArgs{"f": "png_pipe"}).
+		Output(f.Name(), ffmpeg.KwArgs{"c:v": "libwebp", "quality": renditionQuality, "f": "webp"}).
+		OverWriteOutput().WithInput(&buf).Silent(true).Run()
+}
+
+// buildRenditions creates the renditions of the file rel in deviceDir from the original, for
+// files stored before renditions existed or before the configured sizes changed.
+func buildRenditions(deviceDir, rel string) error {
+	src, err := thumbnailSource(deviceDir, rel)
+	if err != nil {
+		return err
+	}
+	return saveRenditions(src, ThumbnailBasePath(deviceDir, rel))
+}
@@ -0,0 +1,179 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+)
+
+// withThumbnailConfig sets the rendition sizes and formats for a test.
+func withThumbnailConfig(t *testing.T, sizes, formats []string) {
+	restoreSizes, restoreFormats := config.ThumbnailSizes, config.ThumbnailFormats
+	config.ThumbnailSizes, config.ThumbnailFormats = sizes, formats
+	t.Cleanup(func() { config.ThumbnailSizes, config.ThumbnailFormats = restoreSizes, restoreFormats })
+}
+
+func TestPickRendition(t *testing.T) {
+	withThumbnailConfig(t, []string{"s250", "s500", "f1280"}, []string{"jpeg"})
+	for requested, want := range map[string]string{
+		"s250": "s250", "s100": "s250", "s300": "s500", "s2000": "s500", "f640": "f1280", "f1920": "f1280",
+	} {
+		r, _ := parseRendition(requested)
+		if got, ok := pickRendition(r); !ok || got.Name != want {
+			t.Errorf("pickRendition(%s) = %s, want %s", requested, got.Name, want)
+		}
+	}
+	withThumbnailConfig(t, []string{"s250"}, []string{"jpeg"})
+	if got, _ := pickRendition(thumbnailRendition{Name: "f1920", Size: 1920}); got.Name != "s250" {
+		t.Errorf("other shape = %s", got.Name)
+	}
+	if _, ok := parseRendition("x250"); ok {
+		t.Error("x250 parsed")
+	}
+}
+
+func TestRenditionSuffix(t *testing.T) {
+	for name, want := range map[string]string{
+		"photo.jpg@s250.jpg":   "@s250.jpg",
+		"photo.jpg@f1280.webp": "@f1280.webp",
+		"photo@2x.png":         "",
+		"photo.jpg@s250.png":   "",
+		"photo.jpg@s10.jpg":    "",
+		"photo.jpg":            "",
+	} {
+		if got := renditionSuffix(name); got != want {
+			t.Errorf("renditionSuffix(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func writeTestJPEG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, h/2, color.White)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeRendition(t *testing.T) {
+	withThumbnailConfig(t, []string{"s250", "f1280"}, []string{"jpeg"})
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	deviceDir := filepath.Join(tmp, "u", "phone")
+	writeTestJPEG(t, filepath.Join(deviceDir, "2024/07/a.jpg"), 1600, 900)
+
+	serve := func(opts imageOptions) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		serveImage(rr, "u", "phone", "2024/07/a.jpg", opts)
+		return rr
+	}
+	// Renditions missing so far are created on the first request.
+	rr := serve(imageOptions{})
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/jpeg" || rr.Header().Get("Vary") != "Accept" {
+		t.Fatalf("thumbnail: %d %v", rr.Code, rr.Header())
+	}
+	if img, _, err := image.Decode(rr.Body); err != nil || img.Bounds().Dx() != 250 || img.Bounds().Dy() != 250 {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	rr = serve(imageOptions{Quality: "high"})
+	if img, _, err := image.Decode(rr.Body); err != nil || img.Bounds().Dx() != 1280 || img.Bounds().Dy() != 720 {
+		t.Fatalf("high: %v", err)
+	}
+
+	base := ThumbnailBasePath(deviceDir, "2024/07/a.jpg")
+	s250, _ := parseRendition("s250")
+	if err := os.WriteFile(renditionPath(base, s250, renditionWebP), []byte("webp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rr = serve(imageOptions{Accept: "image/avif,image/webp,*/*"})
+	if rr.Header().Get("Content-Type") != "image/webp" || rr.Body.String() != "webp" {
+		t.Fatalf("Accept webp: %v", rr.Header())
+	}
+	if rr = serve(imageOptions{Accept: "image/webp;q=0, */*"}); rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Accept webp;q=0: %v", rr.Header())
+	}
+	if rr = serve(imageOptions{Format: "jpeg", Accept: "image/webp"}); rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Format jpeg: %v", rr.Header())
+	}
+	if rr = serve(imageOptions{Format: "webp", Size: "f2000"}); rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Format webp without a WebP rendition: %v", rr.Header())
+	}
+	for _, opts := range []imageOptions{{Size: "x250"}, {Format: "gif"}} {
+		if rr = serve(opts); rr.Code != http.StatusBadRequest {
+			t.Errorf("%+v: got status %d", opts, rr.Code)
+		}
+	}
+}
+
+func TestCleanOrphanRenditions(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"2024/07/a.jpg", "2024/07/b.jpeg", "2024/07/c@s250.jpg"} {
+		writeTestFile(t, filepath.Join(dir, p))
+		writeTestFile(t, ThumbnailBasePath(dir, p))
+	}
+	for _, p := range []string{"a.jpg@s250.jpg", "a.jpg@f1280.webp", "b.jpeg@s250.jpg", "gone.jpg", "gone.jpg@s250.jpg", "gone.mp4.jpeg", "gone.mp4@s250.jpg"} {
+		writeTestFile(t, ThumbnailBasePath(dir, "2024/07/"+p))
+	}
+	if removed := cleanOrphanThumbnailsInDir(dir, "Thumbnails"); removed != 4 {
+		t.Fatalf("removed %d, want 4", removed)
+	}
+	for _, p := range []string{"a.jpg@s250.jpg", "a.jpg@f1280.webp", "b.jpeg", "b.jpeg@s250.jpg", "c@s250.jpg"} {
+		if _, err := os.Stat(ThumbnailBasePath(dir, "2024/07/"+p)); err != nil {
+			t.Errorf("%s was removed", p)
+		}
+	}
+}
+
+func TestTrashMovesRenditions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJPEG(t, filepath.Join(dir, "2024/07/a.jpg"), 16, 16)
+	for _, p := range []string{"a.jpg", "a.jpg@s250.jpg", "a.jpg@s250.webp", "a.jpg.jpg"} {
+		writeTestFile(t, ThumbnailBasePath(dir, "2024/07/"+p))
+	}
+	if !MoveRelativePathToTrash(dir, "2024/07/a.jpg") {
+		t.Fatal("not moved")
+	}
+	for _, p := range []string{"a.jpg", "a.jpg@s250.jpg", "a.jpg@s250.webp"} {
+		if _, err := os.Stat(ThumbnailBasePath(dir, "Trash/2024/07/"+p)); err != nil {
+			t.Errorf("%s was not moved to Trash", p)
+		}
+	}
+	if _, err := os.Stat(ThumbnailBasePath(dir, "2024/07/a.jpg.jpg")); err != nil {
+		t.Error("the thumbnail of another file was moved")
+	}
+}
@@ -0,0 +1,258 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/mediatypes"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// processWorkers is the number of files processed at the same time.
+const processWorkers = 2
+
+// processQueue holds uploaded files and files found by rescans until a worker processes them. A
+// file is queued at most once; queueing never blocks.
+var processQueue = struct {
+	sync.Mutex
+	jobs    []processJob
+	pending map[string]bool
+	wake    chan struct{}
+	start   sync.Once
+}{pending: make(map[string]bool), wake: make(chan struct{}, 1)}
+
+// queueProcessing adds job to the process queue unless the file is already waiting.
+// Returns false if it was.
+func queueProcessing(job processJob) bool {
+	q := &processQueue
+	q.start.Do(func() {
+		for i := 0; i < processWorkers; i++ {
+			go processWorker()
+		}
+	})
+	key := job.UserId + "/" + job.DeviceId + "/" + job.Path
+	q.Lock()
+	if q.pending[key] {
+		q.Unlock()
+		return false
+	}
+	q.pending[key] = true
+	q.jobs = append(q.jobs, job)
+	q.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func processWorker() {
+	q := &processQueue
+	for {
+		q.Lock()
+		if len(q.jobs) == 0 {
+			q.Unlock()
+			<-q.wake
+			continue
+		}
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		if len(q.jobs) > 0 {
+			// Let the next worker pick up the rest.
+			select {
+			case q.wake <- struct{}{}:
+			default:
+			}
+		}
+		q.Unlock()
+		processFile(job)
+		q.Lock()
+		delete(q.pending, job.UserId+"/"+job.DeviceId+"/"+job.Path)
+		q.Unlock()
+	}
+}
+
+// RescanReport is the result of a rescan of the storage tree.
+type RescanReport struct {
+	// Queued is the number of new or changed files queued for processing.
+	Queued int `json:"Queued"`
+	// Cleaned is the number of thumbnails and metadata files removed because their original is gone.
+	Cleaned int `json:"Cleaned"`
+	CatalogReport
+}
+
+// fileMediaType returns the media type of the file p from its content, else from its extension.
+func fileMediaType(p string) mediatypes.MediaType {
+	f, err := os.Open(p)
+	if err != nil {
+		return mediatypes.Unknown
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if t := utils.GetMediaType(http.DetectContentType(head[:n])); t != mediatypes.Unknown {
+		return t
+	}
+	return utils.GetMediaType(mime.TypeByExtension(strings.ToLower(path.Ext(p))))
+}
+
+// needsProcessing returns whether the file rel of deviceDir, last modified at modTime, needs its
+// metadata and thumbnail created. Files changed after their metadata or thumbnail was created are
+// processed again.
+func needsProcessing(deviceDir, rel string, modTime time.Time) bool {
+	stale := false
+	exists := 0
+	for _, derived := range []string{MetadataPath(deviceDir, rel), ThumbnailBasePath(deviceDir, rel),
+		ThumbnailBasePath(deviceDir, rel) + ".jpeg"} {
+		if stat, err := os.Stat(derived); err == nil {
+			exists++
+			stale = stale || modTime.After(stat.ModTime())
+		}
+	}
+	return exists == 0 || stale
+}
+
+// rescanDeviceDir cleans the derived files of deleted originals in the device folder deviceId of
+// the user folder userId and queues new and changed files for processing. Files the media catalog
+// does not know yet are recorded as added.
+func rescanDeviceDir(userId, deviceId string) RescanReport {
+	var report RescanReport
+	deviceDir := filepath.Join(config.UploadDirectory, userId, deviceId)
+	report.Cleaned += cleanOrphanThumbnailsInDir(deviceDir, "Thumbnails")
+	report.Cleaned += cleanOrphanThumbnailsInDir(deviceDir, TrashFolder+"/Thumbnails")
+	report.Cleaned += cleanOrphanMetadataInDir(deviceDir, "Metadata")
+	report.Cleaned += cleanOrphanMetadataInDir(deviceDir, TrashFolder+"/Metadata")
+
+	var known map[string]store.MediaStamp
+	if owner, _, ok := deviceDirOwner(deviceDir); ok && config.AuthDBPath != "" {
+		var err error
+		if known, err = store.MediaStamps(owner, deviceId); err != nil {
+			logger.ErrorF("Rescan %s: %v", deviceDir, err)
+		}
+	}
+	files, _ := ListAllRelativeFiles(deviceDir)
+	trash, _ := ListTrashFiles(deviceDir)
+	for _, rel := range append(files, trash...) {
+		full := filepath.Join(deviceDir, filepath.FromSlash(rel))
+		stat, err := os.Stat(full)
+		if err != nil {
+			continue
+		}
+		if !needsProcessing(deviceDir, rel, stat.ModTime()) {
+			continue
+		}
+		mediaType := fileMediaType(full)
+		if mediaType == mediatypes.Unknown {
+			continue
+		}
+		job := processJob{UserId: userId, DeviceId: deviceId, Path: rel, MediaType: mediaType, Source: "rescan"}
+		if !queueProcessing(job) {
+			continue
+		}
+		report.Queued++
+		if _, cataloged := known[rel]; !cataloged {
+			recordFileChange(deviceDir, store.ChangeAdd, rel, "")
+		}
+	}
+	return report
+}
+
+// Rescan looks for files added, changed or deleted in the storage tree outside the API: new and
+// changed files get the processing of an upload, the thumbnails and metadata of deleted files are
+// removed and the media catalog is repaired. Waits for a running storage migration.
+func Rescan() RescanReport {
+	storageMigrationMu.Lock()
+	defer storageMigrationMu.Unlock()
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	var report RescanReport
+	devices, err := listDeviceFolders()
+	if err != nil {
+		logger.ErrorF("Rescan: %v", err)
+		return report
+	}
+	for _, d := range devices {
+		for _, folder := range d.folders {
+			r := rescanDeviceDir(folder, d.deviceId)
+			report.Queued += r.Queued
+			report.Cleaned += r.Cleaned
+		}
+		if config.AuthDBPath == "" {
+			continue
+		}
+		i, r, err := indexDeviceDir(d.dirs()...)
+		if err != nil {
+			logger.ErrorF("Media catalog of %s/%s: %v", d.userId, d.deviceId, err)
+		}
+		report.Indexed += i
+		report.Removed += r
+	}
+	return report
+}
+
+// StartRescan rescans the storage tree in the background at startup and then every
+// config.RescanIntervalMinutes, followed by the perceptual hashes still missing. Listings answer
+// from the media catalog after the first rescan.
+func StartRescan() {
+	go func() {
+		for {
+			report := Rescan()
+			catalogReady.Store(true)
+			if report != (RescanReport{}) {
+				logger.InfoF("Rescan: queued %d files, cleaned %d derived files, indexed %d, removed %d",
+					report.Queued, report.Cleaned, report.Indexed, report.Removed)
+			}
+			if config.AuthDBPath != "" {
+				backfillPHashes()
+			}
+			if config.RescanIntervalMinutes <= 0 {
+				return
+			}
+			time.Sleep(time.Duration(config.RescanIntervalMinutes) * time.Minute)
+		}
+	}()
+}
+
+// RescanHandler rescans the storage tree now (admin only). Queued files are processed in the background.
+// POST -> { "Queued": 0, "Cleaned": 0, "Indexed": 0, "Removed": 0 }
+func RescanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(Rescan())
+}
@@ -0,0 +1,91 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestNeedsProcessing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "2024/07/a.jpg"))
+	modTime := time.Now().Add(-time.Hour)
+
+	if !needsProcessing(dir, "2024/07/a.jpg", modTime) {
+		t.Fatal("unprocessed file does not need processing")
+	}
+	writeTestFile(t, MetadataPath(dir, "2024/07/a.jpg"))
+	if needsProcessing(dir, "2024/07/a.jpg", modTime) {
+		t.Fatal("processed file needs processing")
+	}
+	if !needsProcessing(dir, "2024/07/a.jpg", time.Now().Add(time.Hour)) {
+		t.Fatal("changed file does not need processing")
+	}
+
+	writeTestFile(t, filepath.Join(dir, "Trash/2024/07/b.mp4"))
+	writeTestFile(t, ThumbnailBasePath(dir, "Trash/2024/07/b.mp4")+".jpeg")
+	if needsProcessing(dir, "Trash/2024/07/b.mp4", modTime) {
+		t.Fatal("video with thumbnail in Trash needs processing")
+	}
+}
+
+func TestCleanOrphanMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "2024/07/a.jpg"))
+	writeTestFile(t, MetadataPath(dir, "2024/07/a.jpg"))
+	writeTestFile(t, MetadataPath(dir, "2024/07/gone.jpg"))
+	writeTestFile(t, MetadataPath(dir, "Trash/2024/07/gone.jpg"))
+
+	if removed := cleanOrphanMetadataInDir(dir, "Metadata") + cleanOrphanMetadataInDir(dir, "Trash/Metadata"); removed != 2 {
+		t.Fatalf("removed %d, want 2", removed)
+	}
+	if _, err := os.Stat(MetadataPath(dir, "2024/07/a.jpg")); err != nil {
+		t.Fatal("metadata of an existing file was removed")
+	}
+}
+
+func TestRescanRecordsUncatalogedFiles(t *testing.T) {
+	openTestAuthDB(t)
+	restore := config.UploadDirectory
+	config.UploadDirectory = t.TempDir()
+	defer func() { config.UploadDirectory = restore }()
+	userId, _ := createTestUser(t, "rescan@example.com")
+	deviceDir := filepath.Join(config.UploadDirectory, userId, "phone")
+	writeTestFile(t, filepath.Join(deviceDir, "2024/07/known.jpg"))
+	writeTestFile(t, filepath.Join(deviceDir, "2024/07/new.jpg"))
+	// known.jpg is cataloged but its metadata and thumbnail are missing, so it is queued again.
+	if err := store.UpsertMedia(store.MediaEntry{UserId: userId, DeviceId: "phone", Path: "2024/07/known.jpg",
+		MimeType: "image/jpeg", MediaType: "image"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := rescanDeviceDir(userId, "phone"); report.Queued != 2 {
+		t.Fatalf("queued %d, want 2", report.Queued)
+	}
+	changes, err := store.ListChanges(userId, "", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != store.ChangeAdd || changes[0].Path != "2024/07/new.jpg" {
+		t.Fatalf("changes = %+v, want one add of 2024/07/new.jpg", changes)
+	}
+}
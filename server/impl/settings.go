@@ -0,0 +1,93 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Keys of the server settings stored in the auth DB. A stored value overrides the env default.
+const (
+	settingRequire2FA       = "require_2fa"
+	settingRegistrationMode = "registration_mode"
+)
+
+// serverSettings is the body of /admin/settings. On POST, nil fields are left unchanged.
+type serverSettings struct {
+	Require2FA       *bool   `json:"Require2FA,omitempty"`
+	RegistrationMode *string `json:"RegistrationMode,omitempty"`
+}
+
+func currentSettings() serverSettings {
+	require := require2FA()
+	mode := registrationMode()
+	return serverSettings{Require2FA: &require, RegistrationMode: &mode}
+}
+
+// registrationMode returns the effective registration mode (DB setting, else env default).
+func registrationMode() string {
+	if v, ok := store.GetSetting(settingRegistrationMode); ok && config.IsValidRegistrationMode(v) {
+		return v
+	}
+	return config.RegistrationMode
+}
+
+// AdminSettingsHandler returns (GET) or changes (POST) the server settings. Requires an admin token.
+// POST body: { "Require2FA": true, "RegistrationMode": "open" | "invite" | "disabled" }
+func AdminSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adminId, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodPost {
+		var req serverSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RenderError(w, err, http.StatusBadRequest)
+			return
+		}
+		if req.RegistrationMode != nil {
+			if !config.IsValidRegistrationMode(*req.RegistrationMode) {
+				utils.RenderError(w, InvalidRegistrationMode(*req.RegistrationMode), http.StatusBadRequest)
+				return
+			}
+			if err := store.SetSetting(settingRegistrationMode, *req.RegistrationMode); err != nil {
+				utils.RenderError(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Require2FA != nil {
+			if err := store.SetSetting(settingRequire2FA, strconv.FormatBool(*req.Require2FA)); err != nil {
+				utils.RenderError(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+		detail, _ := json.Marshal(req)
+		audit(r, adminId, "", AuditAdminSettings, nil, auditOK, string(detail))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(currentSettings())
+}
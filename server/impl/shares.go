@@ -0,0 +1,494 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/passwords"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// shareAccessHours is how long the access key returned for a password protected share is valid.
+const shareAccessHours = 12
+
+// Wrong share passwords: after sharePasswordFailures of them for a share from one IP address,
+// that address gets 429 for the share until sharePasswordLockout has passed since the first one.
+const (
+	sharePasswordFailures = 5
+	sharePasswordLockout  = 15 * time.Minute
+)
+
+type sharePasswordAttempts struct {
+	failures int
+	since    time.Time
+}
+
+var (
+	shareAttemptsMu sync.Mutex
+	shareAttempts   = make(map[string]*sharePasswordAttempts)
+)
+
+type createShareRequest struct {
+	UserData userData `json:"UserData"`
+	// Folder (e.g. "2024/07") shares the files directly in it; otherwise Files lists the paths.
+	Folder         string   `json:"Folder"`
+	Files          []string `json:"Files"`
+	Password       string   `json:"Password"`
+	ExpiresInHours int      `json:"ExpiresInHours"`
+	AllowDownload  bool     `json:"AllowDownload"`
+}
+
+type createShareResponse struct {
+	Id        string `json:"Id"`
+	URL       string `json:"URL"`
+	ExpiresAt int64  `json:"ExpiresAt"`
+}
+
+type shareIdRequest struct {
+	Id string `json:"Id"`
+}
+
+type sharePasswordRequest struct {
+	Password string `json:"Password"`
+}
+
+type publicShareResponse struct {
+	Id            string   `json:"Id"`
+	Files         []string `json:"Files"`
+	AllowDownload bool     `json:"AllowDownload"`
+	ExpiresAt     int64    `json:"ExpiresAt"`
+	// Access must be passed to /share/img and /share/stream for password protected shares.
+	Access string `json:"Access,omitempty"`
+}
+
+// requireAuthDB writes 404 and returns false when there is no auth DB to store shares and libraries in.
+func requireAuthDB(w http.ResponseWriter) bool {
+	if config.AuthDBPath == "" {
+		utils.RenderError(w, AuthDBRequired, http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// isSharePath returns true if file is a safe relative media path outside Trash and the derived folders.
+func isSharePath(file string) bool {
+	if file == "" || strings.Contains(file, "..") || strings.HasPrefix(file, "/") {
+		return false
+	}
+	first := strings.SplitN(file, "/", 2)[0]
+	return first != TrashFolder && first != "Thumbnails" && first != "Metadata"
+}
+
+// CreateShareHandler creates a public link to files or a folder. Requires a session token of
+// the user (or the admin) when auth is enabled.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Folder": "2024/07" | "", "Files": [], "Password": "", "ExpiresInHours": 0, "AllowDownload": false }
+// -> { "Id": "", "URL": "/share?id=...", "ExpiresAt": <unix or 0> }
+func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	var req createShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	deviceId := strings.TrimSpace(req.UserData.DeviceId)
+	folder := strings.Trim(normalizeRequestPath(req.Folder), "/")
+	files := make([]string, 0, len(req.Files))
+	for _, f := range req.Files {
+		f = normalizeRequestPath(f)
+		if !isSharePath(f) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		files = append(files, f)
+	}
+	if req.UserData.User == "" || deviceId == "" || strings.ContainsAny(deviceId, `/\`) ||
+		(folder == "") == (len(files) == 0) || (folder != "" && !isSharePath(folder)) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sessionUser, ok := authorizeUserAccess(w, r, req.UserData.User)
+	if !ok {
+		return
+	}
+	id, err := utils.RandomHex(16)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	share := store.Share{Id: id, UserId: canonicalUserId(req.UserData.User), DeviceId: deviceId, Folder: folder,
+		Files: files, AllowDownload: req.AllowDownload, CreatedBy: sessionUser}
+	if share.CreatedBy == "" {
+		share.CreatedBy = share.UserId
+	}
+	if req.Password != "" {
+		if share.PasswordHash, err = passwords.Hash(req.Password); err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.ExpiresInHours > 0 {
+		share.ExpiresAt = time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour).Unix()
+	}
+	if err := store.CreateShare(share); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	paths := files
+	if folder != "" {
+		paths = []string{folder}
+	}
+	audit(r, share.UserId, deviceId, AuditShare, paths, auditOK, "create "+id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(createShareResponse{Id: id, URL: "/share?id=" + id, ExpiresAt: share.ExpiresAt})
+}
+
+// ListSharesHandler returns the shares created by the caller with their view counts.
+// GET -> [ { "Id": "", "Folder": "", "Files": [], "ExpiresAt": 0, "Revoked": false, "Views": 0, ... } ]
+func ListSharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	shares, err := store.ListShares(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(shares)
+}
+
+// RevokeShareHandler revokes a share created by the caller (the admin can revoke any share).
+// POST body: { "Id": "" } -> 200, or 404 if the caller has no such share.
+func RevokeShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req shareIdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	createdBy := userId
+	if IsAdmin(userId) {
+		createdBy = ""
+	}
+	revoked, err := store.RevokeShare(req.Id, createdBy)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	audit(r, userId, "", AuditShare, nil, auditOK, "revoke "+req.Id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// activeShare loads the share named by the "id" query parameter. Writes 404 (unknown, revoked
+// or expired) and returns nil on failure.
+func activeShare(w http.ResponseWriter, r *http.Request) *store.Share {
+	share, err := store.GetShare(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return nil
+	}
+	if share == nil || !share.Active() {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	return share
+}
+
+// shareAccessKey returns the key that proves the password of share was entered, valid until expires.
+func shareAccessKey(share *store.Share, expires int64) (string, error) {
+	key, err := urlSigningKey()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(expires, 10) + "." + urlSignature(key, "share", share.Id, share.PasswordHash, "", expires), nil
+}
+
+// checkShareAccess verifies the "access" query parameter of a password protected share.
+func checkShareAccess(w http.ResponseWriter, r *http.Request, share *store.Share) bool {
+	if !share.HasPassword {
+		return true
+	}
+	access := r.URL.Query().Get("access")
+	expiresStr, _, _ := strings.Cut(access, ".")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err == nil && time.Now().Unix() <= expires {
+		if want, err := shareAccessKey(share, expires); err == nil && hmac.Equal([]byte(want), []byte(access)) {
+			return true
+		}
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+// shareAttemptKey identifies the password attempts of one client IP for one share.
+func shareAttemptKey(r *http.Request, share *store.Share) string {
+	return share.Id + "\n" + clientIP(r)
+}
+
+// sharePasswordLocked returns true if the client of r entered too many wrong passwords for share.
+func sharePasswordLocked(r *http.Request, share *store.Share) bool {
+	shareAttemptsMu.Lock()
+	defer shareAttemptsMu.Unlock()
+	a := shareAttempts[shareAttemptKey(r, share)]
+	return a != nil && a.failures >= sharePasswordFailures && time.Since(a.since) < sharePasswordLockout
+}
+
+// failSharePassword counts a wrong password of the client of r for share.
+func failSharePassword(r *http.Request, share *store.Share) {
+	now := time.Now()
+	shareAttemptsMu.Lock()
+	defer shareAttemptsMu.Unlock()
+	for k, a := range shareAttempts {
+		if now.Sub(a.since) >= sharePasswordLockout {
+			delete(shareAttempts, k)
+		}
+	}
+	key := shareAttemptKey(r, share)
+	if a := shareAttempts[key]; a != nil {
+		a.failures++
+		return
+	}
+	shareAttempts[key] = &sharePasswordAttempts{failures: 1, since: now}
+}
+
+// clearSharePasswordFailures forgets the wrong passwords of the client of r for share.
+func clearSharePasswordFailures(r *http.Request, share *store.Share) {
+	shareAttemptsMu.Lock()
+	defer shareAttemptsMu.Unlock()
+	delete(shareAttempts, shareAttemptKey(r, share))
+}
+
+// sharePassword returns the password sent for a protected share: the X-Share-Password header,
+// or the Password of a POST body. Passwords in the query are ignored, so they do not end up in
+// access logs and browser history.
+func sharePassword(r *http.Request) (string, error) {
+	if r.Method == http.MethodPost {
+		var req sharePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", err
+		}
+		return req.Password, nil
+	}
+	return r.Header.Get("X-Share-Password"), nil
+}
+
+// shareFiles returns the paths of the shared files that currently exist.
+func shareFiles(share *store.Share) []string {
+	userDir := filepath.Join(config.UploadDirectory, ResolveToUserId(share.UserId), share.DeviceId)
+	files := make([]string, 0)
+	if share.Folder != "" {
+		entries, err := os.ReadDir(filepath.Join(userDir, filepath.FromSlash(share.Folder)))
+		if err != nil {
+			return files
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, share.Folder+"/"+e.Name())
+			}
+		}
+		sort.Strings(files)
+		return files
+	}
+	for _, f := range share.Files {
+		if _, err := os.Stat(filepath.Join(userDir, filepath.FromSlash(f))); err == nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// sharedFile returns the "File" query parameter if it belongs to share, else writes 404.
+func sharedFile(w http.ResponseWriter, r *http.Request, share *store.Share) (string, bool) {
+	file := normalizeRequestPath(r.URL.Query().Get("File"))
+	if !isSharePath(file) {
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	}
+	if share.Folder != "" {
+		if path.Dir(file) == share.Folder {
+			return file, true
+		}
+	} else {
+		for _, f := range share.Files {
+			if f == file {
+				return file, true
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	return "", false
+}
+
+// PublicShareHandler is the public view of a share link: it lists the shared files. No account is
+// needed. Password protected shares need the password (X-Share-Password header, or a POST body)
+// and return an access key for /share/img and /share/stream. After sharePasswordFailures wrong
+// passwords a client gets 429 for a while. Each successful call counts as a view.
+// GET /share?id=... (header X-Share-Password) or POST /share?id=... body: { "Password": "" }
+// -> { "Id": "", "Files": [], "AllowDownload": false, "ExpiresAt": 0, "Access": "" }
+func PublicShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	share := activeShare(w, r)
+	if share == nil {
+		return
+	}
+	resp := publicShareResponse{Id: share.Id, AllowDownload: share.AllowDownload, ExpiresAt: share.ExpiresAt}
+	if share.HasPassword {
+		if sharePasswordLocked(r, share) {
+			utils.RenderError(w, ShareAttemptsExceeded, http.StatusTooManyRequests)
+			return
+		}
+		password, err := sharePassword(r)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusBadRequest)
+			return
+		}
+		if password == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if ok, _ := passwords.Verify(share.PasswordHash, password); !ok {
+			failSharePassword(r, share)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		clearSharePasswordFailures(r, share)
+		expires := time.Now().Add(shareAccessHours * time.Hour).Unix()
+		if share.ExpiresAt > 0 && share.ExpiresAt < expires {
+			expires = share.ExpiresAt
+		}
+		access, err := shareAccessKey(share, expires)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		resp.Access = access
+	}
+	resp.Files = shareFiles(share)
+	if err := store.RecordShareView(share.Id); err != nil {
+		logger.ErrorF("Share %s: record view: %v", share.Id, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// PublicShareImageHandler serves a thumbnail ("" or "high") of a shared file through the /img
+// pipeline; "full" returns the original and needs the download permission.
+// GET /share/img?id=...&File=...&Quality=...&Size=...&Format=...&access=...
+func PublicShareImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	share := activeShare(w, r)
+	if share == nil || !checkShareAccess(w, r, share) {
+		return
+	}
+	file, ok := sharedFile(w, r, share)
+	if !ok {
+		return
+	}
+	opts := queryImageOptions(r)
+	if opts.Quality == "full" && !share.AllowDownload {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	serveImage(w, ResolveToUserId(share.UserId), share.DeviceId, file, opts)
+}
+
+// PublicShareStreamHandler streams the original of a shared file with Range support.
+// Videos and audio can always be played; other originals need the download permission.
+// GET /share/stream?id=...&File=...&access=...
+func PublicShareStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	share := activeShare(w, r)
+	if share == nil || !checkShareAccess(w, r, share) {
+		return
+	}
+	file, ok := sharedFile(w, r, share)
+	if !ok {
+		return
+	}
+	if !share.AllowDownload && !isPlayableMedia(file) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if share.AllowDownload {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+path.Base(file)+"\"")
+	}
+	serveStream(w, r, ResolveToUserId(share.UserId), share.DeviceId, file)
+}
+
+// isPlayableMedia returns true for the video and audio extensions /stream knows.
+func isPlayableMedia(file string) bool {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".mp4", ".m4v", ".webm", ".mov", ".3gp", ".mkv", ".avi", ".mp3", ".m4a", ".ogg", ".oga", ".wav":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/passwords"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestSharedFile(t *testing.T) {
+	folderShare := &store.Share{Folder: "2024/07"}
+	filesShare := &store.Share{Files: []string{"2024/01/a.jpg"}}
+	tests := []struct {
+		name  string
+		share *store.Share
+		file  string
+		want  bool
+	}{
+		{"in folder", folderShare, "2024/07/a.jpg", true},
+		{"windows separators", folderShare, `2024\07\a.jpg`, true},
+		{"subfolder", folderShare, "2024/07/x/a.jpg", false},
+		{"other folder", folderShare, "2024/08/a.jpg", false},
+		{"traversal", folderShare, "2024/07/../../b/a.jpg", false},
+		{"listed file", filesShare, "2024/01/a.jpg", true},
+		{"unlisted file", filesShare, "2024/01/b.jpg", false},
+		{"trash", &store.Share{Folder: TrashFolder + "/2024/07"}, TrashFolder + "/2024/07/a.jpg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/share/img", nil)
+			q := req.URL.Query()
+			q.Set("File", tt.file)
+			req.URL.RawQuery = q.Encode()
+			if _, got := sharedFile(rr, req, tt.share); got != tt.want {
+				t.Errorf("sharedFile(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShareAccessKey(t *testing.T) {
+	restore := config.URLSigningKey
+	config.URLSigningKey = "test-key"
+	defer func() { config.URLSigningKey = restore }()
+	share := &store.Share{Id: "abc", HasPassword: true, PasswordHash: "hash"}
+	expires := time.Now().Add(time.Hour).Unix()
+	access, err := shareAccessKey(share, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := shareAccessKey(share, time.Now().Add(-time.Minute).Unix())
+	other, _ := shareAccessKey(&store.Share{Id: "abd", HasPassword: true, PasswordHash: "hash"}, expires)
+	tests := []struct {
+		name   string
+		access string
+		want   bool
+	}{
+		{"valid", access, true},
+		{"missing", "", false},
+		{"expired", expired, false},
+		{"other share", other, false},
+		{"extended", strconv.FormatInt(expires+3600, 10) + access[len(strconv.FormatInt(expires, 10)):], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/share/img?access="+tt.access, nil)
+			if got := checkShareAccess(rr, req, share); got != tt.want {
+				t.Errorf("checkShareAccess = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownShareNotFound(t *testing.T) {
+	rr := httptest.NewRecorder()
+	PublicShareHandler(rr, httptest.NewRequest(http.MethodGet, "/share?id=missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestPublicSharePassword(t *testing.T) {
+	openTestAuthDB(t)
+	hash, err := passwords.Hash("open sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateShare(store.Share{Id: "s1", UserId: "alice", DeviceId: "phone", Folder: "2024/07",
+		PasswordHash: hash, CreatedBy: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	view := func(r *http.Request) int {
+		r.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		PublicShareHandler(rr, r)
+		return rr.Code
+	}
+	withHeader := func(password string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/share?id=s1", nil)
+		r.Header.Set("X-Share-Password", password)
+		return r
+	}
+
+	if code := view(httptest.NewRequest(http.MethodGet, "/share?id=s1&password=open+sesame", nil)); code != http.StatusUnauthorized {
+		t.Errorf("password in query: got status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := view(withHeader("open sesame")); code != http.StatusOK {
+		t.Errorf("password header: got status %d, want %d", code, http.StatusOK)
+	}
+	body := bytes.NewBufferString(`{"Password":"open sesame"}`)
+	if code := view(httptest.NewRequest(http.MethodPost, "/share?id=s1", body)); code != http.StatusOK {
+		t.Errorf("password body: got status %d, want %d", code, http.StatusOK)
+	}
+
+	for i := 0; i < sharePasswordFailures; i++ {
+		if code := view(withHeader("wrong")); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d: got status %d, want %d", i+1, code, http.StatusUnauthorized)
+		}
+	}
+	if code := view(withHeader("open sesame")); code != http.StatusTooManyRequests {
+		t.Errorf("after %d wrong passwords: got status %d, want %d", sharePasswordFailures, code, http.StatusTooManyRequests)
+	}
+	other := withHeader("open sesame")
+	other.RemoteAddr = "192.0.2.2:1234"
+	rr := httptest.NewRecorder()
+	PublicShareHandler(rr, other)
+	if rr.Code != http.StatusOK {
+		t.Errorf("other client: got status %d, want %d", rr.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,214 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// Endpoints a URL can be signed for. The kind is part of the signature, so a URL signed
+// for /stream cannot be replayed against /img and vice versa.
+const (
+	signedStream = "stream"
+	signedImage  = "img"
+)
+
+// settingURLSigningKey stores the generated signing key when SYNC_URL_SIGNING_KEY is not set.
+const settingURLSigningKey = "url_signing_key"
+
+// Validity of signed URLs.
+const (
+	defaultSignedURLSeconds = 60 * 60
+	maxSignedURLSeconds     = 7 * 24 * 60 * 60
+)
+
+type signURLRequest struct {
+	UserData userData `json:"UserData"`
+	File     string   `json:"File"`
+	// Kind is "stream" (default) or "img".
+	Kind string `json:"Kind"`
+	// Quality, Size and Format are passed on to /img and covered by the signature; see imageOptions.
+	Quality   string `json:"Quality"`
+	Size      string `json:"Size"`
+	Format    string `json:"Format"`
+	ExpiresIn int    `json:"ExpiresIn"`
+}
+
+type signURLResponse struct {
+	URL     string `json:"URL"`
+	Expires int64  `json:"Expires"`
+}
+
+var (
+	signingKeyMu sync.Mutex
+	signingKey   []byte
+)
+
+// urlSigningKey returns the configured key, else the key stored in the auth DB, generating it on first use.
+func urlSigningKey() ([]byte, error) {
+	if config.URLSigningKey != "" {
+		return []byte(config.URLSigningKey), nil
+	}
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	if signingKey != nil {
+		return signingKey, nil
+	}
+	if v, ok := store.GetSetting(settingURLSigningKey); ok && v != "" {
+		signingKey = []byte(v)
+		return signingKey, nil
+	}
+	v, err := utils.RandomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingURLSigningKey, v); err != nil {
+		return nil, err
+	}
+	signingKey = []byte(v)
+	return signingKey, nil
+}
+
+// urlSignature returns the base64url HMAC-SHA256 over the kind, user, device, path, expiry and
+// any further params.
+func urlSignature(key []byte, kind, user, deviceId, file string, expires int64, params ...string) string {
+	mac := hmac.New(sha256.New, key)
+	fields := append([]string{"v1", kind, user, deviceId, file, strconv.FormatInt(expires, 10)}, params...)
+	mac.Write([]byte(strings.Join(fields, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signedImageParams returns the image options a URL of kind is signed for, so a signed thumbnail
+// URL cannot be turned into one for the original or another rendition.
+func signedImageParams(kind string, image imageOptions) []string {
+	if kind != signedImage {
+		return nil
+	}
+	return []string{image.Quality, image.Size, image.Format}
+}
+
+// signedURL returns the path and query of a signed URL for file.
+func signedURL(kind, user, deviceId, file string, image imageOptions, expires int64) (string, error) {
+	key, err := urlSigningKey()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("User", user)
+	q.Set("DeviceId", deviceId)
+	q.Set("File", file)
+	if kind == signedImage {
+		for name, value := range map[string]string{"Quality": image.Quality, "Size": image.Size, "Format": image.Format} {
+			if value != "" {
+				q.Set(name, value)
+			}
+		}
+	}
+	q.Set("Expires", strconv.FormatInt(expires, 10))
+	q.Set("Signature", urlSignature(key, kind, user, deviceId, file, expires, signedImageParams(kind, image)...))
+	return "/" + kind + "?" + q.Encode(), nil
+}
+
+// verifySignedRequest checks the Expires and Signature query parameters for the given
+// (already normalized) request values and, for /img, the image options in the query. Writes 403
+// and returns false if they are missing, expired or do not match.
+func verifySignedRequest(w http.ResponseWriter, r *http.Request, kind, user, deviceId, file string) bool {
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("Expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		utils.RenderError(w, SignedURLInvalid, http.StatusForbidden)
+		return false
+	}
+	key, err := urlSigningKey()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return false
+	}
+	want := urlSignature(key, kind, user, deviceId, file, expires, signedImageParams(kind, queryImageOptions(r))...)
+	if !hmac.Equal([]byte(want), []byte(q.Get("Signature"))) {
+		utils.RenderError(w, SignedURLInvalid, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// normalizeRequestPath converts Windows separators in a client path to forward slashes.
+func normalizeRequestPath(file string) string {
+	return strings.ReplaceAll(file, "\\", "/")
+}
+
+// SignURLHandler issues a signed, expiring URL for /stream or GET /img that media players
+// can use without headers or a request body. When auth is enabled, a session token is
+// required and the user must be the caller (or the caller an admin).
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "File": "2024/01/video.mp4", "Kind": "stream" | "img", "Quality": "",
+// "Size": "", "Format": "", "ExpiresIn": 3600 }
+// -> { "URL": "/stream?User=...&Expires=...&Signature=...", "Expires": <unix> }
+func SignURLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req signURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	file := normalizeRequestPath(req.File)
+	if req.UserData.User == "" || req.UserData.DeviceId == "" || file == "" || strings.Contains(file, "..") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	kind := req.Kind
+	if kind == "" {
+		kind = signedStream
+	}
+	if kind != signedStream && kind != signedImage {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	user := req.UserData.User
+	if _, ok := authorizeUserAccess(w, r, user); !ok {
+		return
+	}
+	seconds := req.ExpiresIn
+	if seconds <= 0 {
+		seconds = defaultSignedURLSeconds
+	}
+	seconds = min(seconds, maxSignedURLSeconds)
+	expires := time.Now().Add(time.Duration(seconds) * time.Second).Unix()
+	u, err := signedURL(kind, user, req.UserData.DeviceId, file,
+		imageOptions{Quality: req.Quality, Size: req.Size, Format: req.Format}, expires)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(signURLResponse{URL: u, Expires: expires})
+}
@@ -0,0 +1,124 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+)
+
+func TestSignedStreamURL(t *testing.T) {
+	tmp := t.TempDir()
+	restoreDir, restoreKey := config.UploadDirectory, config.URLSigningKey
+	config.UploadDirectory, config.URLSigningKey = tmp, "test-key"
+	defer func() { config.UploadDirectory, config.URLSigningKey = restoreDir, restoreKey }()
+	writeTestFile(t, filepath.Join(tmp, "alice", "phone", "2024", "01", "clip.mp4"))
+
+	body := `{"UserData":{"User":"alice","DeviceId":"phone"},"File":"2024\\01\\clip.mp4","Kind":"stream"}`
+	rr := httptest.NewRecorder()
+	SignURLHandler(rr, httptest.NewRequest(http.MethodPost, "/sign-url", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("sign-url status %d", rr.Code)
+	}
+	var signed signURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&signed); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed.URL, "/stream?") || !strings.Contains(signed.URL, "Signature=") {
+		t.Fatalf("URL = %q", signed.URL)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"valid", signed.URL, http.StatusOK},
+		{"other file", strings.Replace(signed.URL, "clip.mp4", "other.mp4", 1), http.StatusForbidden},
+		{"expired", strings.Replace(signed.URL, "Expires=", "Expires=1", 1), http.StatusForbidden},
+		{"used for img", strings.Replace(signed.URL, "/stream?", "/img?", 1), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if strings.HasPrefix(tt.url, "/img") {
+				GetImageHandler(rr, req)
+			} else {
+				GetStreamHandler(rr, req)
+			}
+			if rr.Code != tt.want {
+				t.Errorf("got status %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedImageURLCoversOptions(t *testing.T) {
+	restore := config.URLSigningKey
+	config.URLSigningKey = "test-key"
+	defer func() { config.URLSigningKey = restore }()
+	u, err := signedURL(signedImage, "alice", "phone", "2024/01/a.jpg",
+		imageOptions{Size: "s250", Format: "jpeg"}, time.Now().Add(time.Hour).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(u string) int {
+		rr := httptest.NewRecorder()
+		verifySignedRequest(rr, httptest.NewRequest(http.MethodGet, u, nil), signedImage, "alice", "phone", "2024/01/a.jpg")
+		return rr.Code
+	}
+	if code := verify(u); code != http.StatusOK {
+		t.Fatalf("signed URL: got status %d, want %d", code, http.StatusOK)
+	}
+	for _, tampered := range []string{
+		u + "&Quality=full",
+		strings.Replace(u, "Size=s250", "Size=f2048", 1),
+		strings.Replace(u, "Format=jpeg", "Format=webp", 1),
+		strings.Replace(strings.Replace(u, "&Size=s250", "", 1), "Size=s250&", "", 1),
+	} {
+		if code := verify(tampered); code != http.StatusForbidden {
+			t.Errorf("%s: got status %d, want %d", tampered, code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestGetImageRequiresSignature(t *testing.T) {
+	rr := httptest.NewRecorder()
+	GetImageHandler(rr, httptest.NewRequest(http.MethodGet, "/img?User=alice&DeviceId=phone&File=a.jpg", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestStreamRequireSignedURLs(t *testing.T) {
+	restore := config.RequireSignedURLs
+	config.RequireSignedURLs = true
+	defer func() { config.RequireSignedURLs = restore }()
+	rr := httptest.NewRecorder()
+	GetStreamHandler(rr, httptest.NewRequest(http.MethodGet, "/stream?User=alice&DeviceId=phone&File=a.mp4", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
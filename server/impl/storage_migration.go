@@ -0,0 +1,214 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// storageMigrationMu serializes runs of the storage folder migration.
+var storageMigrationMu sync.Mutex
+
+// StorageMove describes one legacy (email-named) folder moved to the user's id folder.
+type StorageMove struct {
+	UserId string `json:"UserId"`
+	From   string `json:"From"`
+	To     string `json:"To"`
+	// Merged is true when the id folder already existed and the files were moved one by one.
+	Merged bool `json:"Merged"`
+	Files  int  `json:"Files"`
+}
+
+// StorageMigrationReport is the result of MigrateStorageFolders.
+type StorageMigrationReport struct {
+	Moved []StorageMove `json:"Moved"`
+	// Conflicts lists files (UploadDirectory-relative) left in a legacy folder because
+	// a file with the same path already exists in the id folder.
+	Conflicts []string `json:"Conflicts"`
+	Errors    []string `json:"Errors"`
+}
+
+// storageFolder returns the top-level storage folder of a user: the userId, or the legacy
+// lowercased-email folder while it has not been migrated yet.
+func storageFolder(userId, username string) string {
+	legacy := strings.ToLower(username)
+	if legacy == "" || legacy == userId || !isSafeFolderName(legacy) {
+		return userId
+	}
+	if !dirExists(filepath.Join(config.UploadDirectory, userId)) && dirExists(filepath.Join(config.UploadDirectory, legacy)) {
+		return legacy
+	}
+	return userId
+}
+
+// MigrateStorageFolders moves the email-named storage folders of all users to folders named by
+// user id, merging into the id folder if both exist. Safe to run repeatedly and while serving.
+func MigrateStorageFolders() StorageMigrationReport {
+	report := StorageMigrationReport{Moved: make([]StorageMove, 0), Conflicts: make([]string, 0), Errors: make([]string, 0)}
+	if config.AuthDBPath == "" || config.UploadDirectory == "" {
+		return report
+	}
+	users, err := store.ListUsers()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	storageMigrationMu.Lock()
+	defer storageMigrationMu.Unlock()
+	for _, u := range users {
+		migrateUserFolder(u.Id, u.Username, &report)
+	}
+	return report
+}
+
+// StartStorageMigration runs MigrateStorageFolders in the background and logs the result.
+func StartStorageMigration() {
+	go func() {
+		report := MigrateStorageFolders()
+		for _, m := range report.Moved {
+			logger.InfoF("Storage migration: moved %s to %s (%d files, merged: %v)", m.From, m.To, m.Files, m.Merged)
+		}
+		for _, c := range report.Conflicts {
+			logger.ErrorF("Storage migration: conflict, kept %s", c)
+		}
+		for _, e := range report.Errors {
+			logger.ErrorF("Storage migration: %s", e)
+		}
+	}()
+}
+
+// migrateUserFolder moves UploadDirectory/<lowercased username> to UploadDirectory/<userId>.
+// Must be called with storageMigrationMu held.
+func migrateUserFolder(userId, username string, report *StorageMigrationReport) {
+	legacy := strings.ToLower(username)
+	if legacy == "" || legacy == userId || !isSafeFolderName(legacy) || !isSafeFolderName(userId) {
+		return
+	}
+	from := filepath.Join(config.UploadDirectory, legacy)
+	to := filepath.Join(config.UploadDirectory, userId)
+	if !dirExists(from) {
+		return
+	}
+	if !dirExists(to) {
+		files := countFiles(from)
+		if err := os.Rename(from, to); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return
+		}
+		report.Moved = append(report.Moved, StorageMove{UserId: userId, From: legacy, To: userId, Files: files})
+		return
+	}
+	move := StorageMove{UserId: userId, From: legacy, To: userId, Merged: true}
+	_ = filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return nil
+		}
+		target := filepath.Join(to, rel)
+		if _, err := os.Stat(target); err == nil {
+			report.Conflicts = append(report.Conflicts, filepath.ToSlash(filepath.Join(legacy, rel)))
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return nil
+		}
+		if err := os.Rename(path, target); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return nil
+		}
+		move.Files++
+		return nil
+	})
+	removeEmptyDirs(from)
+	report.Moved = append(report.Moved, move)
+}
+
+func countFiles(dir string) int {
+	var n int
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+// removeEmptyDirs removes dir and its subdirectories that contain no files, deepest first.
+func removeEmptyDirs(dir string) {
+	var dirs []string
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		_ = os.Remove(d) // fails (and is ignored) if not empty
+	}
+}
+
+// MigrateStorageHandler moves legacy email-named storage folders to id-named folders and
+// returns the report. Requires an admin token.
+// POST -> { "Moved": [ { "UserId": "", "From": "", "To": "", "Merged": false, "Files": 0 } ], "Conflicts": [], "Errors": [] }
+func MigrateStorageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	report := MigrateStorageFolders()
+	audit(r, "", "", AuditAdminStorage, nil, auditOK, fmt.Sprintf("migrate: %d moved, %d conflicts", len(report.Moved), len(report.Conflicts)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// migrateUserFolderNow migrates the folder of one user and returns an error if files remain
+// in the legacy folder.
+func migrateUserFolderNow(userId, username string) error {
+	storageMigrationMu.Lock()
+	defer storageMigrationMu.Unlock()
+	var report StorageMigrationReport
+	migrateUserFolder(userId, username, &report)
+	if len(report.Conflicts) > 0 || len(report.Errors) > 0 {
+		return StorageMigrationIncomplete(strings.ToLower(username))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func writeTestFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUserFolderRename(t *testing.T) {
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+
+	writeTestFile(t, filepath.Join(tmp, "a@example.com", "phone", "2024", "a.jpg"))
+	if got := storageFolder("id1", "A@example.com"); got != "a@example.com" {
+		t.Fatalf("storageFolder before migration = %q", got)
+	}
+	var report StorageMigrationReport
+	migrateUserFolder("id1", "A@example.com", &report)
+	if len(report.Moved) != 1 || report.Moved[0].Files != 1 || report.Moved[0].Merged {
+		t.Fatalf("report = %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "id1", "phone", "2024", "a.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if got := storageFolder("id1", "A@example.com"); got != "id1" {
+		t.Fatalf("storageFolder after migration = %q", got)
+	}
+}
+
+func TestMigrateUserFolderMergeKeepsConflicts(t *testing.T) {
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+
+	writeTestFile(t, filepath.Join(tmp, "a@example.com", "phone", "2024", "a.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "a@example.com", "phone", "2024", "b.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "id1", "phone", "2024", "b.jpg"))
+
+	var report StorageMigrationReport
+	migrateUserFolder("id1", "a@example.com", &report)
+	if len(report.Moved) != 1 || !report.Moved[0].Merged || report.Moved[0].Files != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0] != "a@example.com/phone/2024/b.jpg" {
+		t.Fatalf("conflicts = %v", report.Conflicts)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "id1", "phone", "2024", "a.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "a@example.com", "phone", "2024", "b.jpg")); err != nil {
+		t.Fatal("conflicting file must stay in the legacy folder")
+	}
+}
+
+func TestReconcileCatalogLegacyFolder(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, _ := createTestUser(t, "alice@example.com")
+	// A migration that left a conflict behind in the legacy folder.
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "a.jpg"))
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "b.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "alice@example.com", "phone", "2024", "b.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "alice@example.com", "phone", "2024", "c.jpg"))
+
+	if report := ReconcileCatalog(); report.Indexed != 3 || report.Removed != 0 {
+		t.Fatalf("first reconcile = %+v", report)
+	}
+	stamps, err := store.MediaStamps(userId, "phone")
+	if err != nil || len(stamps) != 3 {
+		t.Fatalf("catalog = %v, %v", stamps, err)
+	}
+	// Neither folder removes the files of the other.
+	if report := ReconcileCatalog(); report.Indexed != 0 || report.Removed != 0 {
+		t.Fatalf("second reconcile = %+v", report)
+	}
+
+	// A reconcile waits for a running storage migration.
+	storageMigrationMu.Lock()
+	done := make(chan CatalogReport)
+	go func() { done <- ReconcileCatalog() }()
+	select {
+	case <-done:
+		t.Fatal("reconcile ran during the storage migration")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := os.Remove(filepath.Join(tmp, "alice@example.com", "phone", "2024", "c.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	storageMigrationMu.Unlock()
+	if report := <-done; report.Removed != 1 {
+		t.Fatalf("reconcile after migration = %+v", report)
+	}
+}
@@ -31,6 +31,7 @@ import (
 // GetStreamHandler serves raw media files (video/audio) with HTTP Range support
 // for streaming and seeking without full download.
 // GET /stream?User=...&DeviceId=...&File=... (File is URL-encoded path, e.g. 2024/01/video.mp4)
+// URLs signed by /sign-url add Expires and Signature; they are required if SYNC_REQUIRE_SIGNED_URLS is set.
 func GetStreamHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -48,12 +49,19 @@ func GetStreamHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	userId := ResolveToUserId(userFromClient)
-	if userId == "" {
-		userId = userFromClient
-	}
 	// Normalize path: server expects forward slashes (Windows clients may send backslash).
-	file = strings.ReplaceAll(file, "\\", "/")
+	file = normalizeRequestPath(file)
+	signed := r.URL.Query().Get("Signature") != "" || config.RequireSignedURLs
+	if signed && !verifySignedRequest(w, r, signedStream, userFromClient, deviceId, file) {
+		return
+	}
+	if folder, deviceId, file, ok := resolveMediaFile(w, r, userFromClient, deviceId, file, signed); ok {
+		serveStream(w, r, folder, deviceId, file)
+	}
+}
+
+// serveStream writes file from the storage folder userId/deviceId, honouring Range requests.
+func serveStream(w http.ResponseWriter, r *http.Request, userId, deviceId, file string) {
 	userDirName := filepath.Join(config.UploadDirectory, userId, deviceId)
 	originalFilePath := filepath.Join(userDirName, file)
 	// Ensure resolved path is still under userDirName
@@ -19,7 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
-	"image/png"
+	"image/jpeg"
 	"io"
 	"os"
 	"path/filepath"
@@ -29,6 +29,7 @@ import (
 	"github.com/disintegration/imaging"
 	"github.com/takecontrolsoft/go_multi_log/logger"
 	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/mediatypes"
 	"github.com/takecontrolsoft/sync_server/server/utils"
 )
 
@@ -53,8 +54,7 @@ func BuildVideoThumbnail(userName string, deviceId string, file string) (string,
 	thumbnailPath := ThumbnailBasePath(userDirName, file) + ".jpeg"
 	filePath := filepath.Join(userDirName, file)
 
-	reader := GetFrameFromVideo(filePath, 5)
-	src, err := imaging.Decode(reader)
+	src, err := videoFrame(filePath)
 	if err != nil {
 		return "", err
 	}
@@ -73,10 +73,18 @@ func BuildVideoThumbnail(userName string, deviceId string, file string) (string,
 	if err != nil {
 		return "", err
 	}
+	if err := saveRenditions(src, ThumbnailBasePath(userDirName, file)); err != nil {
+		return "", err
+	}
 
 	return thumbnailPath, nil
 }
 
+// videoFrame returns the frame of the video filePath that thumbnails are made from.
+func videoFrame(filePath string) (image.Image, error) {
+	return imaging.Decode(GetFrameFromVideo(filePath, 5))
+}
+
 // applyEXIFOrientation transforms the image according to EXIF Orientation (1-8).
 // imaging: Rotate90 = 90° CCW, Rotate270 = 90° CW.
 func applyEXIFOrientation(src image.Image, orientation int) image.Image {
@@ -108,18 +116,12 @@ func BuildImageThumbnail(userName string, deviceId string, file string) (string,
 	userDirName := filepath.Join(config.UploadDirectory, userName, deviceId)
 	// Use ThumbnailBasePath so uploads-to-Trash and /img thumbnail lookup use the same path.
 	thumbnailPath := ThumbnailBasePath(userDirName, file)
-	filePath := filepath.Join(userDirName, file)
 
-	src, err := utils.GetImageFromFilePath(filePath)
+	src, err := orientedImage(userDirName, file)
 	if err != nil {
 		return "", err
 	}
 
-	// Apply EXIF orientation so thumbnail is displayed correctly (e.g. phone photos rotated 90°).
-	metadataPath := MetadataPath(userDirName, file)
-	orientation := GetOrientationFromMetadata(metadataPath)
-	src = applyEXIFOrientation(src, orientation)
-
 	// Resize srcImage to width = 300px preserving the aspect ratio.
 	resized := imaging.Resize(src, 300, 0, imaging.Lanczos)
 	// Resize and crop the srcImage to fill the 250x250px area.
@@ -128,15 +130,42 @@ func BuildImageThumbnail(userName string, deviceId string, file string) (string,
 	if err != nil {
 		return "", err
 	}
-	f, err := os.Create(thumbnailPath)
+	// The base thumbnail keeps its extension-less name; its content is JPEG (older ones are PNG).
+	err = writeFileAtomic(thumbnailPath, func(f *os.File) error {
+		return jpeg.Encode(f, thumbnail, &jpeg.Options{Quality: renditionQuality})
+	})
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
-	png.Encode(f, thumbnail)
+	if err := saveRenditions(src, thumbnailPath); err != nil {
+		return "", err
+	}
 	return thumbnailPath, nil
 }
 
+// orientedImage decodes the image file of userDirName and applies its EXIF orientation so
+// thumbnails are displayed correctly (e.g. phone photos rotated 90°).
+func orientedImage(userDirName, file string) (image.Image, error) {
+	src, err := utils.GetImageFromFilePath(filepath.Join(userDirName, file))
+	if err != nil {
+		return nil, err
+	}
+	orientation := GetOrientationFromMetadata(MetadataPath(userDirName, file))
+	return applyEXIFOrientation(src, orientation), nil
+}
+
+// thumbnailSource returns the image the thumbnails of the image or video rel in deviceDir are made from.
+func thumbnailSource(deviceDir, rel string) (image.Image, error) {
+	full := filepath.Join(deviceDir, filepath.FromSlash(rel))
+	switch fileMediaType(full) {
+	case mediatypes.Image:
+		return orientedImage(deviceDir, filepath.ToSlash(rel))
+	case mediatypes.Video:
+		return videoFrame(full)
+	}
+	return nil, BuildThumbnailFailed
+}
+
 func BuildAudioThumbnail(userName string, deviceId string, file string) (string, error) {
 	return "", nil
 }
@@ -0,0 +1,128 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+type timelineRequest struct {
+	UserData userData `json:"UserData"`
+	// Type counts only "image", "video" or "audio" files ("" = all).
+	Type string `json:"Type"`
+}
+
+// TimelineDay is the number of files captured on a day.
+type TimelineDay struct {
+	Day   int `json:"Day"`
+	Count int `json:"Count"`
+}
+
+// TimelineMonth is the number of files captured in a month, with its days newest first.
+type TimelineMonth struct {
+	Month int           `json:"Month"`
+	Count int           `json:"Count"`
+	Days  []TimelineDay `json:"Days"`
+}
+
+// TimelineYear is the number of files captured in a year, with its months newest first.
+type TimelineYear struct {
+	Year   int             `json:"Year"`
+	Count  int             `json:"Count"`
+	Months []TimelineMonth `json:"Months"`
+}
+
+type timelineResponse struct {
+	Count int            `json:"Count"`
+	Years []TimelineYear `json:"Years"`
+}
+
+// buildTimeline groups capture times (unix seconds) by year, month and day, newest first. Days are
+// UTC dates, which are the dates shown on the device since capture times keep its wall-clock time.
+func buildTimeline(times []int64) timelineResponse {
+	days := make(map[time.Time]int)
+	for _, ts := range times {
+		t := time.Unix(ts, 0).UTC()
+		days[time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)]++
+	}
+	dates := make([]time.Time, 0, len(days))
+	for d := range days {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+
+	resp := timelineResponse{Years: make([]TimelineYear, 0)}
+	for _, d := range dates {
+		count := days[d]
+		resp.Count += count
+		if n := len(resp.Years); n == 0 || resp.Years[n-1].Year != d.Year() {
+			resp.Years = append(resp.Years, TimelineYear{Year: d.Year()})
+		}
+		year := &resp.Years[len(resp.Years)-1]
+		year.Count += count
+		if n := len(year.Months); n == 0 || year.Months[n-1].Month != int(d.Month()) {
+			year.Months = append(year.Months, TimelineMonth{Month: int(d.Month())})
+		}
+		month := &year.Months[len(year.Months)-1]
+		month.Count += count
+		month.Days = append(month.Days, TimelineDay{Day: d.Day(), Count: count})
+	}
+	return resp
+}
+
+// TimelineHandler returns the number of files per year, month and day of capture across all devices
+// of the user (or one device with DeviceId), newest first. Days come from the capture time in the
+// metadata, else the modification time. Files in Trash are not counted.
+// POST body: { "UserData": { "User": "", "DeviceId": "" }, "Type": "image" | "video" | "audio" | "" }
+// -> { "Count": 0, "Years": [ { "Year": 2024, "Count": 0, "Months": [ { "Month": 7, "Count": 0, "Days": [ { "Day": 1, "Count": 0 } ] } ] } ] }
+func TimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req timelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	user := req.UserData.User
+	if user == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Type != "" && mediaTypeName(req.Type+"/") == "" {
+		utils.RenderError(w, InvalidListOption("Type", req.Type), http.StatusBadRequest)
+		return
+	}
+	if _, ok := authorizeUserAccess(w, r, user); !ok {
+		return
+	}
+	times := make([]int64, 0)
+	for _, info := range listUserFiles(user, strings.TrimSpace(req.UserData.DeviceId)) {
+		if req.Type == "" || info.Type == req.Type {
+			times = append(times, info.CapturedAt)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(buildTimeline(times))
+}
@@ -0,0 +1,56 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	times := []int64{
+		1719835200, // 2024-07-01 12:00
+		1719878399, // 2024-07-01 23:59:59
+		1719964800, // 2024-07-03
+		1722470400, // 2024-08-01
+		978307200,  // 2001-01-01
+	}
+	data, _ := json.Marshal(buildTimeline(times))
+	want := `{"Count":5,"Years":[` +
+		`{"Year":2024,"Count":4,"Months":[{"Month":8,"Count":1,"Days":[{"Day":1,"Count":1}]},` +
+		`{"Month":7,"Count":3,"Days":[{"Day":3,"Count":1},{"Day":1,"Count":2}]}]},` +
+		`{"Year":2001,"Count":1,"Months":[{"Month":1,"Count":1,"Days":[{"Day":1,"Count":1}]}]}]}`
+	if string(data) != want {
+		t.Fatalf("buildTimeline = %s", data)
+	}
+	if data, _ := json.Marshal(buildTimeline(nil)); string(data) != `{"Count":0,"Years":[]}` {
+		t.Fatalf("empty timeline = %s", data)
+	}
+}
+
+func TestBuildTimelineLocalDates(t *testing.T) {
+	times := []int64{
+		parseMetadataTime("2024:07:01 00:30:00+02:00"),
+		parseMetadataTime("2024:06:30 23:30:00-05:00"),
+	}
+	data, _ := json.Marshal(buildTimeline(times))
+	want := `{"Count":2,"Years":[{"Year":2024,"Count":2,"Months":[` +
+		`{"Month":7,"Count":1,"Days":[{"Day":1,"Count":1}]},` +
+		`{"Month":6,"Count":1,"Days":[{"Day":30,"Count":1}]}]}]}`
+	if string(data) != want {
+		t.Fatalf("buildTimeline = %s", data)
+	}
+}
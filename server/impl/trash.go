@@ -71,6 +71,8 @@ type restoreData struct {
 }
 
 // MoveToTrashHandler moves files (and their thumbnails and metadata) to Trash.
+// Library files ("@lib/...") are moved to Trash only by their owner; see trashLibraryFile. They need
+// the session token of the user.
 // POST body: { "UserData": { "User": "", "DeviceId": "" }, "Files": ["2024/01/photo.jpg", ...] }
 func MoveToTrashHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -93,11 +95,27 @@ func MoveToTrashHandler(w http.ResponseWriter, r *http.Request) {
 		userId = userFromClient
 	}
 	userDir := filepath.Join(config.UploadDirectory, userId, deviceId)
+	// Library files are trashed or removed with the library role of the user, so the request must be theirs.
+	for _, file := range result.Files {
+		if isLibraryPath(file) {
+			if _, ok := authorizeUserAccess(w, r, userFromClient); !ok {
+				return
+			}
+			break
+		}
+	}
 
+	moved := make([]string, 0, len(result.Files))
 	for _, file := range result.Files {
 		if file == "" || strings.Contains(file, "..") {
 			continue
 		}
+		if isLibraryPath(file) {
+			if trashLibraryFile(userFromClient, file) {
+				moved = append(moved, file)
+			}
+			continue
+		}
 		// Skip if already in Trash (API uses forward slash: "Trash/...")
 		if strings.HasPrefix(file, trashPrefix) || file == TrashFolder {
 			continue
@@ -111,45 +129,59 @@ func MoveToTrashHandler(w http.ResponseWriter, r *http.Request) {
 		thumbDst := filepath.Join(userDir, TrashFolder, "Thumbnails", file) + thumbExt
 
 		// Move main file first; then thumbnail and metadata (no-op if src missing).
+		if _, err := os.Stat(originalPath); err != nil {
+			continue
+		}
 		if err := moveFile(originalPath, trashPath); err != nil {
 			continue
 		}
 		_ = moveFile(thumbSrc, thumbDst)
+		moveRenditions(filepath.Join(userDir, "Thumbnails", file), filepath.Join(userDir, TrashFolder, "Thumbnails", file))
 		metaSrc := filepath.Join(userDir, "Metadata", file+".json")
 		metaDst := filepath.Join(userDir, TrashFolder, "Metadata", file+".json")
 		_ = moveFile(metaSrc, metaDst)
+		onFileMoved(userDir, file, trashPrefix+file)
+		moved = append(moved, file)
 	}
+	audit(r, canonicalUserId(userFromClient), deviceId, AuditTrash, moved, auditOK, "")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 }
 
-// MoveRelativePathToTrash moves one file (and its thumbnail and metadata) from
+// MoveRelativePathToTrash moves one file (and its thumbnails and metadata) from
 // the normal folder to Trash. relPath is e.g. "2024/01/photo.jpg". No-op if
-// relPath is already under Trash. Used by upload when document detection is enabled.
-func MoveRelativePathToTrash(userDir, relPath string) {
+// relPath is already under Trash. Used by upload when document detection is enabled
+// and for library files trashed by their owner. Returns true if the file was moved.
+func MoveRelativePathToTrash(userDir, relPath string) bool {
 	if relPath == "" || strings.Contains(relPath, "..") {
-		return
+		return false
 	}
 	// Skip if already in Trash; accept both "Trash/" and OS separator
 	if strings.HasPrefix(relPath, trashPrefix) || relPath == TrashFolder {
-		return
+		return false
 	}
 	if strings.HasPrefix(relPath, TrashFolder+string(os.PathSeparator)) {
-		return
+		return false
 	}
 	originalPath := filepath.Join(userDir, relPath)
+	if _, err := os.Stat(originalPath); err != nil {
+		return false
+	}
 	trashPath := filepath.Join(userDir, TrashFolder, relPath)
 	thumbExt, _ := utils.GetThumbnailFileAddedExtension(originalPath)
 	thumbSrc := filepath.Join(userDir, "Thumbnails", relPath) + thumbExt
 	thumbDst := filepath.Join(userDir, TrashFolder, "Thumbnails", relPath) + thumbExt
 	if moveFile(originalPath, trashPath) != nil {
-		return
+		return false
 	}
 	_ = moveFile(thumbSrc, thumbDst)
+	moveRenditions(filepath.Join(userDir, "Thumbnails", relPath), filepath.Join(userDir, TrashFolder, "Thumbnails", relPath))
 	metaSrc := filepath.Join(userDir, "Metadata", relPath+".json")
 	metaDst := filepath.Join(userDir, TrashFolder, "Metadata", relPath+".json")
 	_ = moveFile(metaSrc, metaDst)
+	onFileMoved(userDir, relPath, trashPrefix+filepath.ToSlash(relPath))
+	return true
 }
 
 // RestoreHandler moves files from Trash back to their original folder (by path).
@@ -177,6 +209,7 @@ func RestoreHandler(w http.ResponseWriter, r *http.Request) {
 	userDir := filepath.Join(config.UploadDirectory, userId, deviceId)
 	// Use package trashPrefix "Trash/" — API always sends forward slashes.
 
+	restored := make([]string, 0, len(result.Files))
 	for _, file := range result.Files {
 		if file == "" || strings.Contains(file, "..") {
 			continue
@@ -189,6 +222,9 @@ func RestoreHandler(w http.ResponseWriter, r *http.Request) {
 		originalFilePath := filepath.Join(userDir, restorePath)
 
 		// Move main file back
+		if _, err := os.Stat(trashFilePath); err != nil {
+			continue
+		}
 		if err := moveFile(trashFilePath, originalFilePath); err != nil {
 			continue
 		}
@@ -198,12 +234,16 @@ func RestoreHandler(w http.ResponseWriter, r *http.Request) {
 		thumbTrash := filepath.Join(userDir, TrashFolder, "Thumbnails", restorePath) + thumbExt
 		thumbOriginal := filepath.Join(userDir, "Thumbnails", restorePath) + thumbExt
 		_ = moveFile(thumbTrash, thumbOriginal)
+		moveRenditions(filepath.Join(userDir, TrashFolder, "Thumbnails", restorePath), filepath.Join(userDir, "Thumbnails", restorePath))
 
 		// Move metadata back from Trash/Metadata
 		metaTrash := filepath.Join(userDir, TrashFolder, "Metadata", restorePath+".json")
 		metaOriginal := filepath.Join(userDir, "Metadata", restorePath+".json")
 		_ = moveFile(metaTrash, metaOriginal)
+		onFileMoved(userDir, file, restorePath)
+		restored = append(restored, file)
 	}
+	audit(r, canonicalUserId(userFromClient), deviceId, AuditRestore, restored, auditOK, "")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
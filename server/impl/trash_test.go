@@ -0,0 +1,82 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// postTrashRequest sends body to handler with the session token and fails the test unless it returns 200.
+func postTrashRequest(t *testing.T, handler http.HandlerFunc, token, body string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%d %s", rr.Code, rr.Body)
+	}
+}
+
+func TestTrashAuditsUserId(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, token := createTestUser(t, "alice@example.com")
+	// Not migrated yet: the files are in the email folder.
+	writeTestFile(t, filepath.Join(tmp, "alice@example.com", "phone", "2024", "07", "a.jpg"))
+
+	postTrashRequest(t, MoveToTrashHandler, token, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["2024/07/a.jpg"]}`)
+	postTrashRequest(t, RestoreHandler, token, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["Trash/2024/07/a.jpg"]}`)
+	for _, action := range []string{AuditTrash, AuditRestore} {
+		entries, err := store.QueryAudit(store.AuditFilter{UserId: userId, Action: action, Limit: 10})
+		if err != nil || len(entries) != 1 || entries[0].UserId != userId {
+			t.Fatalf("%s audit of %s = %+v, %v", action, userId, entries, err)
+		}
+	}
+}
+
+func TestTrashMissingFile(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	userId, token := createTestUser(t, "alice@example.com")
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "07", "a.jpg"))
+
+	postTrashRequest(t, MoveToTrashHandler, token, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["2024/07/missing.jpg"]}`)
+	// a.jpg is not in Trash.
+	postTrashRequest(t, RestoreHandler, token, `{"UserData":{"User":"alice@example.com","DeviceId":"phone"},"Files":["Trash/2024/07/a.jpg"]}`)
+	if changes, err := store.ListChanges(userId, "", 0, 10); err != nil || len(changes) != 0 {
+		t.Fatalf("changes = %+v, %v; want none", changes, err)
+	}
+	for _, action := range []string{AuditTrash, AuditRestore} {
+		entries, err := store.QueryAudit(store.AuditFilter{UserId: userId, Action: action, Limit: 10})
+		if err != nil || len(entries) != 1 || len(entries[0].Paths) != 0 {
+			t.Fatalf("%s audit = %+v, %v; want no paths", action, entries, err)
+		}
+	}
+}
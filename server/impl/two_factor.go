@@ -0,0 +1,388 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/totp"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// loginChallengeTimeout is how long the second login step may take after the password was verified.
+const loginChallengeTimeout = 5 * time.Minute
+
+// loginChallengeAttempts is the number of wrong codes after which a login challenge is dropped.
+const loginChallengeAttempts = 5
+
+// recoveryCodeCount is the number of recovery codes issued on enrolment.
+const recoveryCodeCount = 10
+
+type loginChallenge struct {
+	userId   string
+	enroll   bool
+	expires  time.Time
+	attempts int
+}
+
+var (
+	challengesMu    sync.Mutex
+	loginChallenges = make(map[string]*loginChallenge)
+)
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired  bool   `json:"TwoFactorRequired"`
+	EnrollmentRequired bool   `json:"EnrollmentRequired"`
+	Challenge          string `json:"Challenge"`
+}
+
+type twoFactorRequest struct {
+	Challenge string `json:"Challenge"`
+	Code      string `json:"Code"`
+}
+
+type enrollResponse struct {
+	Secret          string `json:"Secret"`
+	ProvisioningURI string `json:"ProvisioningURI"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"RecoveryCodes"`
+}
+
+type twoFactorLoginResponse struct {
+	Token         string   `json:"Token"`
+	UserId        string   `json:"UserId"`
+	RecoveryCodes []string `json:"RecoveryCodes,omitempty"`
+}
+
+// require2FA returns true if two-factor authentication is required for every user.
+func require2FA() bool {
+	if v, ok := store.GetSetting(settingRequire2FA); ok {
+		return v == "true"
+	}
+	return config.Require2FA
+}
+
+// renderTwoFactorChallenge starts the second login step for userId. If enroll is true the user
+// has no TOTP yet and must enrol with the challenge before the login can complete.
+func renderTwoFactorChallenge(w http.ResponseWriter, userId string, enroll bool) {
+	challenge, err := utils.RandomHex(32)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	challengesMu.Lock()
+	for c, lc := range loginChallenges {
+		if now.After(lc.expires) {
+			delete(loginChallenges, c)
+		}
+	}
+	loginChallenges[challenge] = &loginChallenge{userId: userId, enroll: enroll, expires: now.Add(loginChallengeTimeout)}
+	challengesMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(twoFactorChallengeResponse{TwoFactorRequired: true, EnrollmentRequired: enroll, Challenge: challenge})
+}
+
+// getLoginChallenge returns the pending challenge, or nil if it is unknown or expired.
+func getLoginChallenge(challenge string) *loginChallenge {
+	if challenge == "" {
+		return nil
+	}
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	lc := loginChallenges[challenge]
+	if lc == nil || time.Now().After(lc.expires) {
+		delete(loginChallenges, challenge)
+		return nil
+	}
+	return lc
+}
+
+// failLoginChallenge counts a wrong code and drops the challenge after too many attempts.
+func failLoginChallenge(challenge string) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	if lc := loginChallenges[challenge]; lc != nil {
+		lc.attempts++
+		if lc.attempts >= loginChallengeAttempts {
+			delete(loginChallenges, challenge)
+		}
+	}
+}
+
+// LoginTwoFactorHandler completes a login with a TOTP or recovery code and issues the session token.
+// If the challenge requires enrolment, the code confirms the secret from /auth/2fa/enroll and
+// the response also contains the new recovery codes.
+// POST body: { "Challenge": "", "Code": "" } -> { "Token": "", "UserId": "" } or 401.
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req twoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	lc := getLoginChallenge(req.Challenge)
+	if lc == nil || req.Code == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var recoveryCodes []string
+	if lc.enroll {
+		codes, ok, err := confirmTOTP(lc.userId, req.Code)
+		if err != nil {
+			utils.RenderError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			failLoginChallenge(req.Challenge)
+			audit(r, lc.userId, "", AuditLoginTwoFactor, nil, auditDenied, "enrolment")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		recoveryCodes = codes
+	} else if !verifySecondFactor(lc.userId, req.Code) {
+		failLoginChallenge(req.Challenge)
+		audit(r, lc.userId, "", AuditLoginTwoFactor, nil, auditDenied, "")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	challengesMu.Lock()
+	delete(loginChallenges, req.Challenge)
+	challengesMu.Unlock()
+	token, err := store.CreateToken(lc.userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, lc.userId, "", AuditLoginTwoFactor, nil, auditOK, "")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(twoFactorLoginResponse{Token: token, UserId: lc.userId, RecoveryCodes: recoveryCodes})
+}
+
+// EnrollTwoFactorHandler creates a new (pending) TOTP secret for the caller and returns it with
+// the otpauth:// provisioning URI for the QR code. The caller is identified by the session token,
+// or by a login challenge when enrolment is required before the first token is issued.
+// POST body: { "Challenge": "" } (optional) -> { "Secret": "", "ProvisioningURI": "" }
+func EnrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req twoFactorRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	userId := SessionUserId(r)
+	if userId == "" {
+		if lc := getLoginChallenge(req.Challenge); lc != nil && lc.enroll {
+			userId = lc.userId
+		}
+	}
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if store.HasTOTPEnabled(userId) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := store.SetPendingTOTP(userId, secret); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	account := store.GetUsernameByUserId(userId)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(enrollResponse{Secret: secret, ProvisioningURI: totp.ProvisioningURI(config.TOTPIssuer, account, secret)})
+}
+
+// ConfirmTwoFactorHandler enables the pending TOTP secret of the caller after checking a code
+// from the authenticator app, and returns the recovery codes (shown only once).
+// POST body: { "Code": "" } -> { "RecoveryCodes": [...] }
+func ConfirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req twoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	codes, ok, err := confirmTOTP(userId, req.Code)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactorHandler removes TOTP from the caller's account after checking a TOTP or recovery code.
+// Not allowed while two-factor authentication is required for everyone.
+// POST body: { "Code": "" }
+func DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req twoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	if require2FA() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !verifySecondFactor(userId, req.Code) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := store.DisableTOTP(userId); err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RecoveryCodesHandler replaces the caller's recovery codes after checking a TOTP code.
+// POST body: { "Code": "" } -> { "RecoveryCodes": [...] }
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	userId := SessionUserId(r)
+	if userId == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req twoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	st := store.GetTOTP(userId)
+	if st == nil || !st.Enabled || !verifyTOTP(userId, st, req.Code) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	codes, err := issueRecoveryCodes(userId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// confirmTOTP checks code against the pending secret of the user, enables it and issues recovery codes.
+func confirmTOTP(userId, code string) ([]string, bool, error) {
+	st := store.GetTOTP(userId)
+	if st == nil || st.Enabled || !verifyTOTP(userId, st, code) {
+		return nil, false, nil
+	}
+	if err := store.EnableTOTP(userId); err != nil {
+		return nil, false, err
+	}
+	codes, err := issueRecoveryCodes(userId)
+	if err != nil {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// verifySecondFactor accepts a current TOTP code of the enabled secret or an unused recovery code.
+func verifySecondFactor(userId, code string) bool {
+	st := store.GetTOTP(userId)
+	if st == nil || !st.Enabled {
+		return false
+	}
+	if verifyTOTP(userId, st, code) {
+		return true
+	}
+	return store.UseRecoveryCode(userId, code)
+}
+
+// verifyTOTP validates code and records its time step so the same code cannot be used twice.
+func verifyTOTP(userId string, st *store.TOTPState, code string) bool {
+	counter, ok := totp.Validate(st.Secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	return store.UseTOTPCounter(userId, counter)
+}
+
+// issueRecoveryCodes generates and stores a new set of recovery codes like "k3m9x-7qpwz".
+func issueRecoveryCodes(userId string) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, recoveryCodeCount)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range codes {
+		var sb strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			// rand.Int is uniform; a byte modulo 31 would favor the first letters.
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteByte(alphabet[n.Int64()])
+		}
+		codes[i] = sb.String()
+	}
+	if err := store.ReplaceRecoveryCodes(userId, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
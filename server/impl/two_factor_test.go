@@ -0,0 +1,72 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoginTwoFactorHandler_methodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/login/2fa", nil)
+	rr := httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestLoginTwoFactorHandler_unknownChallenge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login/2fa", bytes.NewBufferString(`{"Challenge":"nope","Code":"123456"}`))
+	rr := httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginTwoFactorHandler_challengeDroppedAfterAttempts(t *testing.T) {
+	rr := httptest.NewRecorder()
+	renderTwoFactorChallenge(rr, "user-without-totp", false)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	var challenge string
+	challengesMu.Lock()
+	for c, lc := range loginChallenges {
+		if lc.userId == "user-without-totp" {
+			challenge = c
+		}
+	}
+	challengesMu.Unlock()
+	for i := 0; i < loginChallengeAttempts; i++ {
+		failLoginChallenge(challenge)
+	}
+	if getLoginChallenge(challenge) != nil {
+		t.Errorf("challenge still valid after %d wrong codes", loginChallengeAttempts)
+	}
+}
+
+func TestAdminSettingsHandler_requiresToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/admin/settings", bytes.NewBufferString(`{"Require2FA":true}`))
+	rr := httptest.NewRecorder()
+	AdminSettingsHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
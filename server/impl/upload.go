@@ -33,6 +33,7 @@ import (
 	"github.com/takecontrolsoft/go_multi_log/logger"
 	"github.com/takecontrolsoft/sync_server/server/config"
 	"github.com/takecontrolsoft/sync_server/server/mediatypes"
+	"github.com/takecontrolsoft/sync_server/server/store"
 	"github.com/takecontrolsoft/sync_server/server/utils"
 )
 
@@ -138,41 +139,70 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Use forward slashes so ThumbnailBasePath/MetadataPath recognize Trash paths on all OSes.
 	relPath = filepath.ToSlash(relPath)
+	indexMediaFile(filepath.Join(config.UploadDirectory, userId, deviceId), relPath)
+	recordFileChange(filepath.Join(config.UploadDirectory, userId, deviceId), store.ChangeAdd, relPath, "")
 
-	go func() {
-		userDir := filepath.Join(config.UploadDirectory, userId, deviceId)
-		// 1. Wait for metadata creation to complete.
-		_, metaErr := ExtractMetadata(userId, deviceId, relPath)
-		if metaErr != nil {
-			logger.ErrorF("Creating metadata failed for file %s, %v", relPath, metaErr)
-		}
-		// 2. Wait for thumbnail creation to complete.
-		var thumbErr error
-		switch mediatype {
-		case mediatypes.Video:
-			_, thumbErr = BuildVideoThumbnail(userId, deviceId, relPath)
-		case mediatypes.Image:
-			_, thumbErr = BuildImageThumbnail(userId, deviceId, relPath)
-		case mediatypes.Audio:
-			_, thumbErr = BuildAudioThumbnail(userId, deviceId, relPath)
-		default:
-			logger.Info("Unknown media type for thumbnail")
-		}
-		if thumbErr != nil {
-			logger.ErrorF("Creating thumbnail failed for file %s, %v", relPath, thumbErr)
+	queueProcessing(processJob{UserId: userId, DeviceId: deviceId, Path: relPath, MediaType: mediatype, Source: "upload"})
+}
+
+// processJob is a stored file that needs its metadata, thumbnail and document detection.
+type processJob struct {
+	// UserId is the storage folder of the user.
+	UserId   string
+	DeviceId string
+	// Path is relative to the device folder, with forward slashes.
+	Path      string
+	MediaType mediatypes.MediaType
+	// Source is the audit detail of a document detection: "upload" or "rescan".
+	Source string
+}
+
+// processFile creates the metadata and the thumbnail of a stored file, updates the media catalog
+// and moves detected documents to Trash.
+func processFile(job processJob) {
+	userId, deviceId, relPath := job.UserId, job.DeviceId, job.Path
+	userDir := filepath.Join(config.UploadDirectory, userId, deviceId)
+	// 1. Wait for metadata creation to complete.
+	_, metaErr := ExtractMetadata(userId, deviceId, relPath)
+	if metaErr != nil {
+		logger.ErrorF("Creating metadata failed for file %s, %v", relPath, metaErr)
+	}
+	// 2. Wait for thumbnail creation to complete.
+	var thumbErr error
+	switch job.MediaType {
+	case mediatypes.Video:
+		_, thumbErr = BuildVideoThumbnail(userId, deviceId, relPath)
+	case mediatypes.Image:
+		_, thumbErr = BuildImageThumbnail(userId, deviceId, relPath)
+	case mediatypes.Audio:
+		_, thumbErr = BuildAudioThumbnail(userId, deviceId, relPath)
+	default:
+		logger.Info("Unknown media type for thumbnail")
+	}
+	if thumbErr != nil {
+		logger.ErrorF("Creating thumbnail failed for file %s, %v", relPath, thumbErr)
+	}
+	if metaErr == nil || thumbErr == nil {
+		indexMediaFile(userDir, relPath)
+		recordFileChange(userDir, store.ChangeMetadata, relPath, "")
+	}
+	// 3. Run document-to-trash detection only after both metadata and thumbnail have completed,
+	// so the file, metadata, and thumbnail are all moved to Trash/ together.
+	inTrash := strings.HasPrefix(relPath, trashPrefix)
+	if metaErr == nil && thumbErr == nil && job.MediaType == mediatypes.Image && config.DocumentToTrashEnabled && !inTrash {
+		fullPath := filepath.Join(userDir, relPath)
+		moved := false
+		if config.DocumentClassifierPath != "" {
+			moved = RunDocumentClassifierSyncReturnsMoved(fullPath, userDir, relPath)
+		} else if LooksLikeDocument(fullPath) {
+			MoveRelativePathToTrash(userDir, relPath)
+			moved = true
 		}
-		// 3. Run document-to-trash detection only after both metadata and thumbnail have completed,
-		// so the file, metadata, and thumbnail are all moved to Trash/ together.
-		if metaErr == nil && thumbErr == nil && mediatype == mediatypes.Image && config.DocumentToTrashEnabled && !saveToTrash {
-			fullPath := filepath.Join(userDir, relPath)
-			if config.DocumentClassifierPath != "" {
-				RunDocumentClassifierSync(fullPath, userDir, relPath)
-			} else if LooksLikeDocument(fullPath) {
-				MoveRelativePathToTrash(userDir, relPath)
-			}
+		if moved {
+			recordAudit(store.AuditEntry{Actor: auditSystemActor, UserId: userId, Device: deviceId,
+				Action: AuditDocumentDetection, Paths: []string{relPath}, Result: auditOK, Detail: job.Source})
 		}
-	}()
-
+	}
 }
 
 func createNewFile(mp *multipart.Part, w http.ResponseWriter,
@@ -0,0 +1,268 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+// UsageCount is a number of files and their size in bytes.
+type UsageCount struct {
+	Count int   `json:"Count"`
+	Size  int64 `json:"Size"`
+}
+
+func (c *UsageCount) add(o UsageCount) {
+	c.Count += o.Count
+	c.Size += o.Size
+}
+
+// UsageTotals splits the files of a storage folder into originals, their thumbnails and metadata,
+// and everything in Trash (originals, thumbnails and metadata).
+type UsageTotals struct {
+	Originals  UsageCount `json:"Originals"`
+	Thumbnails UsageCount `json:"Thumbnails"`
+	Metadata   UsageCount `json:"Metadata"`
+	Trash      UsageCount `json:"Trash"`
+	Total      UsageCount `json:"Total"`
+}
+
+func (t *UsageTotals) add(o UsageTotals) {
+	t.Originals.add(o.Originals)
+	t.Thumbnails.add(o.Thumbnails)
+	t.Metadata.add(o.Metadata)
+	t.Trash.add(o.Trash)
+	t.Total.add(o.Total)
+}
+
+// UsageMonth is the originals stored in a YYYY/MM folder. Year and Month are 0 for originals
+// stored elsewhere.
+type UsageMonth struct {
+	Year  int `json:"Year"`
+	Month int `json:"Month"`
+	UsageCount
+}
+
+// DeviceUsage is the storage used by a device, in all the user folders holding it. Months and
+// Types count originals only.
+type DeviceUsage struct {
+	DeviceId string `json:"DeviceId"`
+	UsageTotals
+	// Months are newest first, followed by the originals outside YYYY/MM folders.
+	Months []UsageMonth `json:"Months"`
+	// Types are keyed by "image", "video", "audio" and "other".
+	Types map[string]UsageCount `json:"Types"`
+}
+
+// UserUsage is the storage used by a user, in the id folder and the legacy email folder together;
+// devices are ordered by total size, largest first.
+type UserUsage struct {
+	// UserId is the id of the folder's user, or the folder name if the user is unknown.
+	UserId   string `json:"UserId"`
+	Username string `json:"Username"`
+	UsageTotals
+	Devices []DeviceUsage `json:"Devices"`
+}
+
+// VolumeUsage is the size and free space of the volume backing the storage folder.
+// Both are 0 if the operating system did not report them.
+type VolumeUsage struct {
+	Path  string `json:"Path"`
+	Total uint64 `json:"Total"`
+	Free  uint64 `json:"Free"`
+}
+
+// UsageReport is the storage used by all users (ordered by total size, largest first).
+type UsageReport struct {
+	Volume VolumeUsage `json:"Volume"`
+	UsageTotals
+	Users []UserUsage `json:"Users"`
+}
+
+// usageMonth returns the year and month of the YYYY/MM folder the original rel is stored in, else 0, 0.
+func usageMonth(rel string) (int, int) {
+	parts := strings.SplitN(rel, "/", 3)
+	if len(parts) < 3 || len(parts[0]) != 4 || len(parts[1]) != 2 {
+		return 0, 0
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year <= 0 {
+		return 0, 0
+	}
+	month, err := strconv.Atoi(parts[1])
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0
+	}
+	return year, month
+}
+
+// deviceUsage walks the folders deviceDirs of one device and sums up the size of their files.
+func deviceUsage(deviceDirs ...string) DeviceUsage {
+	usage := DeviceUsage{DeviceId: filepath.Base(deviceDirs[0]), Months: make([]UsageMonth, 0),
+		Types: make(map[string]UsageCount)}
+	months := make(map[[2]int]UsageCount)
+	for _, deviceDir := range deviceDirs {
+		deviceFilesUsage(deviceDir, &usage, months)
+	}
+	for key, c := range months {
+		usage.Months = append(usage.Months, UsageMonth{Year: key[0], Month: key[1], UsageCount: c})
+	}
+	sort.Slice(usage.Months, func(i, j int) bool {
+		a, b := usage.Months[i], usage.Months[j]
+		if (a.Year == 0) != (b.Year == 0) {
+			return b.Year == 0
+		}
+		if a.Year != b.Year {
+			return a.Year > b.Year
+		}
+		return a.Month > b.Month
+	})
+	return usage
+}
+
+// deviceFilesUsage adds the files of the device folder deviceDir to usage and to months, the
+// originals per YYYY/MM folder.
+func deviceFilesUsage(deviceDir string, usage *DeviceUsage, months map[[2]int]UsageCount) {
+	_ = filepath.WalkDir(deviceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(deviceDir, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		file := UsageCount{Count: 1, Size: info.Size()}
+		usage.Total.add(file)
+		switch top, _, _ := strings.Cut(rel, "/"); top {
+		case TrashFolder:
+			usage.Trash.add(file)
+		case "Thumbnails":
+			usage.Thumbnails.add(file)
+		case "Metadata":
+			usage.Metadata.add(file)
+		default:
+			usage.Originals.add(file)
+			mediaType := mediaTypeName(mime.TypeByExtension(strings.ToLower(path.Ext(rel))))
+			if mediaType == "" {
+				mediaType = "other"
+			}
+			c := usage.Types[mediaType]
+			c.add(file)
+			usage.Types[mediaType] = c
+			year, month := usageMonth(rel)
+			c = months[[2]int{year, month}]
+			c.add(file)
+			months[[2]int{year, month}] = c
+		}
+		return nil
+	})
+}
+
+// StorageUsage sums up the files of each user and device in the storage folder, and the free
+// space of its volume. The legacy email folder of a user is counted with the id folder. If user is
+// set, only the folders of that user are counted. Waits for a running storage migration.
+func StorageUsage(user string) UsageReport {
+	report := UsageReport{Volume: VolumeUsage{Path: config.UploadDirectory}, Users: make([]UserUsage, 0)}
+	if total, free, err := diskSpace(config.UploadDirectory); err == nil {
+		report.Volume.Total, report.Volume.Free = total, free
+	} else {
+		logger.ErrorF("Free space of %s: %v", config.UploadDirectory, err)
+	}
+	storageMigrationMu.Lock()
+	defer storageMigrationMu.Unlock()
+	folders, err := os.ReadDir(config.UploadDirectory)
+	if err != nil {
+		logger.ErrorF("Storage usage: %v", err)
+		return report
+	}
+	devices, err := listDeviceFolders()
+	if err != nil {
+		logger.ErrorF("Storage usage: %v", err)
+		return report
+	}
+	if user != "" {
+		user = canonicalUserId(user)
+	}
+	index := make(map[string]int)
+	for _, u := range folders {
+		userId := canonicalUserId(u.Name())
+		if _, found := index[userId]; found || !u.IsDir() || (user != "" && userId != user) {
+			continue
+		}
+		index[userId] = len(report.Users)
+		report.Users = append(report.Users, UserUsage{UserId: userId, Username: store.GetUsernameByUserId(userId),
+			Devices: make([]DeviceUsage, 0)})
+	}
+	for _, d := range devices {
+		i, found := index[d.userId]
+		if !found {
+			continue
+		}
+		device := deviceUsage(d.dirs()...)
+		report.Users[i].UsageTotals.add(device.UsageTotals)
+		report.Users[i].Devices = append(report.Users[i].Devices, device)
+	}
+	for _, usage := range report.Users {
+		sort.SliceStable(usage.Devices, func(i, j int) bool {
+			return usage.Devices[i].Total.Size > usage.Devices[j].Total.Size
+		})
+		report.UsageTotals.add(usage.UsageTotals)
+	}
+	sort.SliceStable(report.Users, func(i, j int) bool {
+		return report.Users[i].Total.Size > report.Users[j].Total.Size
+	})
+	return report
+}
+
+// UsageHandler reports the storage used per user, device, month and media type (admin only).
+// Query parameter (optional): user (user id or username) to report a single user.
+// GET -> { "Volume": { "Path": "", "Total": 0, "Free": 0 }, "Originals": { "Count": 0, "Size": 0 },
+// "Thumbnails": {}, "Metadata": {}, "Trash": {}, "Total": {}, "Users": [ { "UserId": "", "Username": "",
+// ...totals..., "Devices": [ { "DeviceId": "", ...totals..., "Months": [ { "Year": 2024, "Month": 7,
+// "Count": 0, "Size": 0 } ], "Types": { "image": { "Count": 0, "Size": 0 } } } ] } ] }
+func UsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAuthDB(w) {
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(StorageUsage(strings.TrimSpace(r.URL.Query().Get("user"))))
+}
@@ -0,0 +1,104 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+)
+
+func TestDeviceUsage(t *testing.T) {
+	deviceDir := filepath.Join(t.TempDir(), "phone")
+	for rel, size := range map[string]int{
+		"2024/07/a.jpg":                1000,
+		"2024/07/b.png":                5000,
+		"2023/12/c.jpg":                2000,
+		"Camera/d.pdf":                 300,
+		"Thumbnails/2024/07/a.jpg":     40,
+		"Metadata/2024/07/a.jpg.json":  7,
+		"Trash/2024/07/e.jpg":          900,
+		"Trash/Thumbnails/2024/07/e.j": 30,
+	} {
+		p := filepath.Join(deviceDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage := deviceUsage(deviceDir)
+	if usage.DeviceId != "phone" || usage.Originals != (UsageCount{4, 8300}) || usage.Thumbnails != (UsageCount{1, 40}) ||
+		usage.Metadata != (UsageCount{1, 7}) || usage.Trash != (UsageCount{2, 930}) || usage.Total != (UsageCount{8, 9277}) {
+		t.Fatalf("totals = %+v", usage.UsageTotals)
+	}
+	want := []UsageMonth{{2024, 7, UsageCount{2, 6000}}, {2023, 12, UsageCount{1, 2000}}, {0, 0, UsageCount{1, 300}}}
+	if len(usage.Months) != len(want) {
+		t.Fatalf("months = %+v", usage.Months)
+	}
+	for i := range want {
+		if usage.Months[i] != want[i] {
+			t.Fatalf("months = %+v", usage.Months)
+		}
+	}
+	if usage.Types["image"] != (UsageCount{3, 8000}) || usage.Types["other"] != (UsageCount{1, 300}) || len(usage.Types) != 2 {
+		t.Fatalf("types = %+v", usage.Types)
+	}
+}
+
+func TestStorageUsageUsers(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restore := config.UploadDirectory
+	config.UploadDirectory = tmp
+	defer func() { config.UploadDirectory = restore }()
+	aliceId, _ := createTestUser(t, "alice@example.com")
+	bobId, _ := createTestUser(t, "bob@example.com")
+	writeTestFile(t, filepath.Join(tmp, aliceId, "phone", "2024", "07", "a.jpg"))
+	// Bob's folder was migrated only in part.
+	writeTestFile(t, filepath.Join(tmp, "bob@example.com", "phone", "2024", "07", "b.jpg"))
+	writeTestFile(t, filepath.Join(tmp, bobId, "phone", "2024", "07", "b2.jpg"))
+	writeTestFile(t, filepath.Join(tmp, bobId, "tablet", "2024", "07", "b3.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "unknown", "phone", "2024", "07", "c.jpg"))
+
+	report := StorageUsage("")
+	users := make(map[string]string)
+	for _, u := range report.Users {
+		users[u.UserId] = u.Username
+	}
+	if len(report.Users) != 3 || users[aliceId] != "alice@example.com" || users[bobId] != "bob@example.com" || users["unknown"] != "" {
+		t.Fatalf("users = %+v", report.Users)
+	}
+	if report.Total.Count != 5 {
+		t.Fatalf("total = %+v", report.Total)
+	}
+	for _, name := range []string{"bob@example.com", bobId} {
+		report := StorageUsage(name)
+		if len(report.Users) != 1 || report.Users[0].UserId != bobId || report.Users[0].Total.Count != 3 {
+			t.Fatalf("usage of %s = %+v", name, report.Users)
+		}
+		devices := make(map[string]int)
+		for _, d := range report.Users[0].Devices {
+			devices[d.DeviceId] = d.Originals.Count
+		}
+		if len(report.Users[0].Devices) != 2 || devices["phone"] != 2 || devices["tablet"] != 1 {
+			t.Fatalf("devices of %s = %+v", name, report.Users[0].Devices)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+	"github.com/takecontrolsoft/sync_server/server/utils"
+)
+
+// userUsage is the storage used by one user under UploadDirectory.
+type userUsage struct {
+	Devices    int   `json:"Devices"`
+	Files      int   `json:"Files"`
+	TrashFiles int   `json:"TrashFiles"`
+	Bytes      int64 `json:"Bytes"`
+}
+
+type adminUser struct {
+	store.User
+	Folder string    `json:"Folder"`
+	Usage  userUsage `json:"Usage"`
+}
+
+type disableUserRequest struct {
+	UserId   string `json:"UserId"`
+	Disabled bool   `json:"Disabled"`
+}
+
+type renameUserRequest struct {
+	UserId   string `json:"UserId"`
+	Username string `json:"Username"`
+}
+
+type deleteUserRequest struct {
+	UserId       string `json:"UserId"`
+	PurgeStorage bool   `json:"PurgeStorage"`
+}
+
+// ListUsersHandler returns all users with their storage usage. Requires an admin token.
+// GET -> [ { "Id": "", "Username": "", "Disabled": false, "Folder": "", "Usage": { "Files": 0, "Bytes": 0, ... } } ]
+func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	users, err := store.ListUsers()
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	result := make([]adminUser, 0, len(users))
+	for _, u := range users {
+		result = append(result, adminUser{User: u, Folder: ResolveToUserId(u.Id), Usage: folderUsage(userFolderDirs(u.Id, u.Username)...)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// DisableUserHandler disables (or re-enables) an account without deleting its data.
+// A disabled user cannot log in and all its sessions end. Requires an admin token.
+// POST body: { "UserId": "", "Disabled": true }
+func DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adminId, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req disableUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.UserId == "" || (req.Disabled && req.UserId == adminId) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	found, err := store.SetUserDisabled(req.UserId, req.Disabled)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	action := "enable"
+	if req.Disabled {
+		action = "disable"
+	}
+	audit(r, req.UserId, "", AuditAdminUser, nil, auditOK, action)
+	w.WriteHeader(http.StatusOK)
+}
+
+// RenameUserHandler changes the login email of a user. Files are stored under the user id, so
+// they stay in place; a legacy email-named folder is migrated first. Requires an admin token.
+// POST body: { "UserId": "", "Username": "new@example.com" } -> 200, 404 or 409 if the name is taken.
+func RenameUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	var req renameUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	username := strings.TrimSpace(req.Username)
+	if req.UserId == "" || username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !store.UserIdExists(req.UserId) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// The legacy folder is found by the current name; after the rename it would be orphaned.
+	if err := migrateUserFolderNow(req.UserId, store.GetUsernameByUserId(req.UserId)); err != nil {
+		utils.RenderError(w, err, http.StatusConflict)
+		return
+	}
+	oldUsername := store.GetUsernameByUserId(req.UserId)
+	err := store.RenameUser(req.UserId, username)
+	if err == store.ErrUserExists {
+		utils.RenderError(w, err, http.StatusConflict)
+		return
+	}
+	if err == store.ErrUserNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	audit(r, req.UserId, "", AuditAdminUser, nil, auditOK, "rename "+oldUsername+" to "+username)
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteUserHandler deletes a user and, if PurgeStorage is set, all of its files under
+// UploadDirectory. The files are purged first: if that fails, the user is kept and the request can
+// be sent again. Requires an admin token; admins cannot delete their own account.
+// POST body: { "UserId": "", "PurgeStorage": false }
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adminId, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req deleteUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RenderError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.UserId == "" || req.UserId == adminId {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	username := store.GetUsernameByUserId(req.UserId)
+	if username == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// Purge while the user still exists, so a failed purge can be retried.
+	if req.PurgeStorage {
+		for _, dir := range userFolderDirs(req.UserId, username) {
+			if err := os.RemoveAll(dir); err != nil {
+				logger.ErrorF("Delete user %s: purging %s failed: %v", req.UserId, dir, err)
+				utils.RenderError(w, err, http.StatusInternalServerError)
+				return
+			}
+			logger.InfoF("Delete user %s: purged %s", req.UserId, dir)
+		}
+	}
+	found, err := store.DeleteUser(req.UserId)
+	if err != nil {
+		utils.RenderError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	detail := "delete " + strings.ToLower(username)
+	if req.PurgeStorage {
+		detail += ", purge storage"
+	}
+	audit(r, req.UserId, "", AuditAdminUser, nil, auditOK, detail)
+	w.WriteHeader(http.StatusOK)
+}
+
+// userFolderDirs returns the existing storage folders of a user: the id folder and the legacy
+// lowercased-email folder while the storage migration has not moved all of its files.
+func userFolderDirs(userId, username string) []string {
+	var dirs []string
+	for _, folder := range []string{userId, strings.ToLower(username)} {
+		dir := filepath.Join(config.UploadDirectory, folder)
+		if isSafeFolderName(folder) && dirExists(dir) && (len(dirs) == 0 || dirs[0] != dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isSafeFolderName returns true if name can be used as a single top-level folder under UploadDirectory.
+func isSafeFolderName(name string) bool {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "..") {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`)
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// folderUsage counts the devices, media files (excluding thumbnails and metadata) and total bytes
+// under the folders userDirs of one user. A device in several folders is counted once.
+func folderUsage(userDirs ...string) userUsage {
+	var u userUsage
+	devices := make(map[string]bool)
+	for _, userDir := range userDirs {
+		entries, _ := os.ReadDir(userDir)
+		for _, e := range entries {
+			if e.IsDir() {
+				devices[e.Name()] = true
+				u.add(folderFilesUsage(filepath.Join(userDir, e.Name())))
+			}
+		}
+	}
+	u.Devices = len(devices)
+	return u
+}
+
+func (u *userUsage) add(o userUsage) {
+	u.Files += o.Files
+	u.TrashFiles += o.TrashFiles
+	u.Bytes += o.Bytes
+}
+
+// folderFilesUsage counts the media files (excluding thumbnails and metadata) and bytes under deviceDir.
+func folderFilesUsage(deviceDir string) userUsage {
+	var u userUsage
+	_ = filepath.WalkDir(deviceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			u.Bytes += info.Size()
+		}
+		rel, _ := filepath.Rel(deviceDir, path)
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, "Thumbnails/") || strings.HasPrefix(rel, "Metadata/") ||
+			strings.HasPrefix(rel, TrashFolder+"/Thumbnails/") || strings.HasPrefix(rel, TrashFolder+"/Metadata/") {
+			return nil
+		}
+		if strings.HasPrefix(rel, trashPrefix) {
+			u.TrashFiles++
+		} else {
+			u.Files++
+		}
+		return nil
+	})
+	return u
+}
@@ -0,0 +1,78 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/store"
+)
+
+func TestUsersAdminSplitFolders(t *testing.T) {
+	openTestAuthDB(t)
+	tmp := t.TempDir()
+	restoreDir, restoreAdmin := config.UploadDirectory, config.AdminUser
+	config.UploadDirectory, config.AdminUser = tmp, "admin@example.com"
+	defer func() { config.UploadDirectory, config.AdminUser = restoreDir, restoreAdmin }()
+	_, adminToken := createTestUser(t, "admin@example.com")
+	userId, _ := createTestUser(t, "alice@example.com")
+	// A migration that left a conflict behind in the legacy folder.
+	writeTestFile(t, filepath.Join(tmp, userId, "phone", "2024", "07", "a.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "alice@example.com", "phone", "2024", "07", "a.jpg"))
+	writeTestFile(t, filepath.Join(tmp, "alice@example.com", "tablet", "2024", "07", "b.jpg"))
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	rr := httptest.NewRecorder()
+	ListUsersHandler(rr, r)
+	var users []adminUser
+	if err := json.NewDecoder(rr.Body).Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	var usage userUsage
+	for _, u := range users {
+		if u.Id == userId {
+			usage = u.Usage
+		}
+	}
+	if usage.Devices != 2 || usage.Files != 3 {
+		t.Fatalf("usage = %+v; want 2 devices, 3 files", usage)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/admin/users/delete",
+		strings.NewReader(`{"UserId":"`+userId+`","PurgeStorage":true}`))
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	rr = httptest.NewRecorder()
+	DeleteUserHandler(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body)
+	}
+	for _, folder := range []string{userId, "alice@example.com"} {
+		if _, err := os.Stat(filepath.Join(tmp, folder)); !os.IsNotExist(err) {
+			t.Fatalf("%s not purged: %v", folder, err)
+		}
+	}
+	if store.UserIdExists(userId) {
+		t.Fatal("user not deleted")
+	}
+}
@@ -0,0 +1,428 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// against a single identity provider: discovery, token exchange and ID token validation.
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the tolerance applied to exp/iat checks of ID tokens.
+const clockSkew = 2 * time.Minute
+
+// keysRefreshInterval is the least time between two loads of the JWKS for tokens with an unknown kid.
+const keysRefreshInterval = time.Minute
+
+// Provider is an OpenID Connect identity provider configured for one client.
+type Provider struct {
+	Issuer           string
+	AuthEndpoint     string
+	TokenEndpoint    string
+	UserInfoEndpoint string
+	JWKSURI          string
+
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client *http.Client
+
+	keysMu sync.Mutex
+	keys   map[string]crypto.PublicKey
+	// keysLoaded is when keys were last loaded.
+	keysLoaded time.Time
+}
+
+// Claims are the identity claims of an ID token or the userinfo endpoint.
+type Claims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"-"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+}
+
+// TokenResponse is the response of the token endpoint.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover loads the provider configuration from <issuer>/.well-known/openid-configuration.
+func Discover(issuer, clientID, clientSecret, redirectURL string, scopes []string) (*Provider, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	issuer = strings.TrimRight(issuer, "/")
+	resp, err := client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrProviderResponse("discovery", resp.StatusCode)
+	}
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(doc.Issuer, "/") != issuer {
+		return nil, ErrInvalidClaim("issuer")
+	}
+	return &Provider{
+		Issuer:           doc.Issuer,
+		AuthEndpoint:     doc.AuthorizationEndpoint,
+		TokenEndpoint:    doc.TokenEndpoint,
+		UserInfoEndpoint: doc.UserInfoEndpoint,
+		JWKSURI:          doc.JWKSURI,
+		ClientID:         clientID,
+		ClientSecret:     clientSecret,
+		RedirectURL:      redirectURL,
+		Scopes:           scopes,
+		client:           client,
+	}, nil
+}
+
+// RandomString returns a URL-safe random string with n bytes of entropy (for state and nonce).
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewPKCE returns a new code verifier and its S256 code challenge (RFC 7636).
+func NewPKCE() (verifier, challenge string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(b)
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// PKCEChallenge returns the S256 code challenge of verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the authorization endpoint URL the user agent is redirected to.
+func (p *Provider) AuthCodeURL(state, nonce, challenge string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.ClientID)
+	v.Set("redirect_uri", p.RedirectURL)
+	v.Set("scope", strings.Join(p.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", challenge)
+	v.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(p.AuthEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthEndpoint + sep + v.Encode()
+}
+
+// Exchange redeems an authorization code together with its PKCE verifier at the token endpoint.
+func (p *Provider) Exchange(code, verifier string) (*TokenResponse, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.RedirectURL)
+	v.Set("client_id", p.ClientID)
+	v.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrProviderResponse("token endpoint", resp.StatusCode)
+	}
+	var tr TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, err
+	}
+	if tr.IDToken == "" {
+		return nil, ErrMalformedToken
+	}
+	return &tr, nil
+}
+
+// UserInfo fetches the claims of the user from the userinfo endpoint.
+func (p *Provider) UserInfo(accessToken string) (*Claims, error) {
+	if p.UserInfoEndpoint == "" {
+		return &Claims{}, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, p.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrProviderResponse("userinfo endpoint", resp.StatusCode)
+	}
+	var raw map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return claimsFromMap(raw), nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+// and returns its claims.
+func (p *Provider) VerifyIDToken(rawToken, nonce string) (*Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err := p.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payloadJSON, &raw); err != nil {
+		return nil, ErrMalformedToken
+	}
+	claims := claimsFromMap(raw)
+	if strings.TrimRight(claims.Issuer, "/") != strings.TrimRight(p.Issuer, "/") {
+		return nil, ErrInvalidClaim("iss")
+	}
+	if !audienceContains(raw["aud"], p.ClientID) {
+		return nil, ErrInvalidClaim("aud")
+	}
+	now := time.Now()
+	exp, ok := raw["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, ErrInvalidClaim("exp")
+	}
+	if iat, ok := raw["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, ErrInvalidClaim("iat")
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrInvalidClaim("nonce")
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidClaim("sub")
+	}
+	return claims, nil
+}
+
+// verifySignature verifies the JWS signature with the key kid, refreshing the
+// provider keys once if the key is unknown (key rotation), at most every keysRefreshInterval.
+func (p *Provider) verifySignature(alg, kid, signed string, signature []byte) error {
+	key, err := p.key(kid, false)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		if key, err = p.key(kid, true); err != nil {
+			return err
+		}
+	}
+	if key == nil {
+		return ErrInvalidSignature
+	}
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// key returns the public key with the given kid, loading the JWKS when not cached or when refresh is
+// set and the keys were loaded more than keysRefreshInterval ago.
+// If kid is empty and the set holds a single key, that key is returned.
+func (p *Provider) key(kid string, refresh bool) (crypto.PublicKey, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+	if p.keys == nil || (refresh && time.Since(p.keysLoaded) >= keysRefreshInterval) {
+		keys, err := p.fetchKeys()
+		if err != nil {
+			return nil, err
+		}
+		p.keys, p.keysLoaded = keys, time.Now()
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, nil
+		}
+	}
+	return p.keys[kid], nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *Provider) fetchKeys() (map[string]crypto.PublicKey, error) {
+	resp, err := p.client.Get(p.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrProviderResponse("jwks_uri", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+func claimsFromMap(raw map[string]interface{}) *Claims {
+	c := &Claims{}
+	c.Issuer, _ = raw["iss"].(string)
+	c.Subject, _ = raw["sub"].(string)
+	c.Email, _ = raw["email"].(string)
+	c.Name, _ = raw["name"].(string)
+	c.Nonce, _ = raw["nonce"].(string)
+	// Some providers send email_verified as the string "true".
+	switch v := raw["email_verified"].(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified = strings.EqualFold(v, "true")
+	}
+	return c
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import "github.com/go-errors/errors"
+
+// An error for an ID token that is not a well-formed JWS in compact serialization.
+var ErrMalformedToken = errors.Errorf("Malformed ID token.").Err
+
+// An error for an ID token signed with an algorithm other than RS256 or ES256.
+var ErrUnsupportedAlgorithm = errors.Errorf("Unsupported ID token signing algorithm.").Err
+
+// An error for an ID token whose signature does not match any key of the provider.
+var ErrInvalidSignature = errors.Errorf("Invalid ID token signature.").Err
+
+// An error for an ID token issued by another issuer or for another client.
+func ErrInvalidClaim(claim string) error {
+	return errors.Errorf("Invalid ID token claim '%s'.", claim).Err
+}
+
+// An error for an unexpected response from the identity provider.
+func ErrProviderResponse(endpoint string, status int) error {
+	return errors.Errorf("Identity provider %s returned status %d.", endpoint, status).Err
+}
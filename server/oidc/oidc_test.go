@@ -0,0 +1,227 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockIdP is a minimal local identity provider: discovery, JWKS and a token endpoint
+// that issues one ID token for the code "good-code" when the PKCE verifier matches.
+type mockIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+	// kid is the key id in the header of signed tokens ("" = "k1", the key in the JWKS).
+	kid string
+	// jwksLoads counts the requests of the JWKS.
+	jwksLoads atomic.Int32
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIdP{key: key, clientID: "sync-server"}
+	mux := http.NewServeMux()
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.jwksLoads.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("code") != "good-code" || PKCEChallenge(r.Form.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, m.claims),
+		})
+	})
+	return m
+}
+
+func (m *mockIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	kid := m.kid
+	if kid == "" {
+		kid = "k1"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (m *mockIdP) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            m.server.URL,
+		"sub":            "subject-1",
+		"aud":            m.clientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          m.nonce,
+		"email":          "mum@example.com",
+		"email_verified": true,
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	p, err := Discover(idp.server.URL, idp.clientID, "secret", "http://localhost/auth/oidc/callback", []string{"openid", "email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.challenge = challenge
+	idp.nonce = "n-1"
+	idp.claims = idp.validClaims()
+
+	u, err := url.Parse(p.AuthCodeURL("state-1", idp.nonce, challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method: got %q, want S256", got)
+	}
+	if got := u.Query().Get("state"); got != "state-1" {
+		t.Errorf("state: got %q, want state-1", got)
+	}
+
+	tokens, err := p.Exchange("good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(tokens.IDToken, idp.nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "mum@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := p.Exchange("good-code", "wrong-verifier"); err == nil {
+		t.Error("Exchange with wrong PKCE verifier: want error")
+	}
+}
+
+func TestVerifyIDToken_rejectsInvalidClaims(t *testing.T) {
+	idp := newMockIdP(t)
+	p, err := Discover(idp.server.URL, idp.clientID, "", "http://localhost/cb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.nonce = "n-2"
+	tests := []struct {
+		name   string
+		mutate func(c map[string]interface{})
+	}{
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "other-client" }},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"wrong nonce", func(c map[string]interface{}) { c["nonce"] = "replayed" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := idp.validClaims()
+			tt.mutate(c)
+			if _, err := p.VerifyIDToken(idp.sign(t, c), idp.nonce); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+
+	token := idp.sign(t, idp.validClaims())
+	tampered := token[:len(token)-4] + "AAAA"
+	if _, err := p.VerifyIDToken(tampered, idp.nonce); err == nil {
+		t.Error("tampered signature: want error")
+	}
+}
+
+func TestVerifyIDToken_limitsKeyRefresh(t *testing.T) {
+	idp := newMockIdP(t)
+	p, err := Discover(idp.server.URL, idp.clientID, "", "http://localhost/cb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.nonce = "n-3"
+	if _, err := p.VerifyIDToken(idp.sign(t, idp.validClaims()), idp.nonce); err != nil {
+		t.Fatal(err)
+	}
+	if n := idp.jwksLoads.Load(); n != 1 {
+		t.Fatalf("JWKS loads after first token = %d, want 1", n)
+	}
+	// Tokens with an unknown kid do not make the server load the JWKS every time.
+	idp.kid = "unknown"
+	for i := 0; i < 3; i++ {
+		if _, err := p.VerifyIDToken(idp.sign(t, idp.validClaims()), idp.nonce); err == nil {
+			t.Fatal("unknown kid: want error")
+		}
+	}
+	if n := idp.jwksLoads.Load(); n != 1 {
+		t.Fatalf("JWKS loads within the refresh interval = %d, want 1", n)
+	}
+	p.keysLoaded = time.Now().Add(-keysRefreshInterval)
+	if _, err := p.VerifyIDToken(idp.sign(t, idp.validClaims()), idp.nonce); err == nil {
+		t.Fatal("unknown kid: want error")
+	}
+	if n := idp.jwksLoads.Load(); n != 2 {
+		t.Fatalf("JWKS loads after the refresh interval = %d, want 2", n)
+	}
+}
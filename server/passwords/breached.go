@@ -0,0 +1,129 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passwords
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// breachedScanWindow is the size of the part of the list that is read line by line once the
+// binary search has narrowed it down.
+const breachedScanWindow = 4096
+
+// breachedList is a breached password list searched in place.
+type breachedList struct {
+	f    *os.File
+	size int64
+}
+
+// openBreachedList opens a list of breached password hashes. The file has one entry per line:
+// the uppercase hex SHA-1 of a password, optionally followed by ":<count>", with the lines sorted
+// by hash, as in the "ordered by hash" download of Have I Been Pwned (pwned-passwords-sha1-ordered-by-hash).
+// Lines may end in "\n" or "\r\n". Only the first line is checked here; an unsorted file makes
+// lookups miss entries.
+func openBreachedList(path string) (*breachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &breachedList{f: f, size: info.Size()}
+	first, err := l.entryAt(0)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if l.size > 0 && !isSHA1Hex(first) {
+		f.Close()
+		return nil, ErrBreachedListFormat(path)
+	}
+	return l, nil
+}
+
+// Close closes the file of the list.
+func (l *breachedList) Close() error {
+	return l.f.Close()
+}
+
+// entryAt returns the hash of the line starting at offset off.
+func (l *breachedList) entryAt(off int64) (string, error) {
+	line, err := bufio.NewReaderSize(io.NewSectionReader(l.f, off, l.size-off), 128).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	entry, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ":")
+	return strings.ToUpper(entry), nil
+}
+
+// nextLineStart returns the offset of the first line that starts after off, or -1 if there is none.
+func (l *breachedList) nextLineStart(off int64) (int64, error) {
+	r := bufio.NewReaderSize(io.NewSectionReader(l.f, off, l.size-off), 128)
+	skipped, err := r.ReadString('\n')
+	if err == io.EOF || off+int64(len(skipped)) >= l.size {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return off + int64(len(skipped)), nil
+}
+
+// Contains returns true if the uppercase hex SHA-1 hash is on the list. The lines are searched
+// by bisecting the file by offset until a window of breachedScanWindow bytes is left, which is
+// then scanned line by line.
+func (l *breachedList) Contains(hash string) (bool, error) {
+	// The line of hash, if any, starts in [lo, hi]; lo is always the start of a line.
+	lo, hi := int64(0), l.size
+	for hi-lo > breachedScanWindow {
+		mid := lo + (hi-lo)/2
+		next, err := l.nextLineStart(mid)
+		if err != nil {
+			return false, err
+		}
+		if next < 0 || next >= hi {
+			break
+		}
+		entry, err := l.entryAt(next)
+		if err != nil {
+			return false, err
+		}
+		if entry < hash {
+			lo = next
+		} else {
+			hi = next
+		}
+	}
+	scanner := bufio.NewScanner(io.NewSectionReader(l.f, lo, l.size-lo))
+	for off := lo; off <= hi && scanner.Scan(); {
+		line := scanner.Text()
+		off += int64(len(line)) + 1
+		entry, _, _ := strings.Cut(strings.TrimRight(line, "\r"), ":")
+		switch entry = strings.ToUpper(entry); {
+		case entry == hash:
+			return true, nil
+		case entry > hash:
+			return false, nil
+		}
+	}
+	return false, scanner.Err()
+}
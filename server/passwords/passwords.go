@@ -0,0 +1,257 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package passwords hashes and verifies user passwords. Hashes are stored in a
+// self-describing encoding, so the algorithm and its parameters can change while
+// existing hashes keep working and are upgraded on the next successful login.
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxLength is the longest password accepted, to bound the hashing work per request.
+const MaxLength = 1024
+
+// Hasher is a password hashing algorithm.
+type Hasher interface {
+	// Hash returns the encoded hash of password with a new random salt.
+	Hash(password string) (string, error)
+	// Handles reports whether encoded was produced by this algorithm.
+	Handles(encoded string) bool
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with other parameters than the current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2id hashes with argon2id and encodes in the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<time>,p=<threads>$<salt>$<hash> (unpadded base64).
+type Argon2id struct {
+	Time      uint32
+	MemoryKiB uint32
+	Threads   uint8
+	KeyLen    uint32
+	SaltLen   uint32
+}
+
+// DefaultArgon2id are the OWASP recommended parameters (64 MiB, 3 passes).
+var DefaultArgon2id = Argon2id{Time: 3, MemoryKiB: 64 * 1024, Threads: 2, KeyLen: 32, SaltLen: 16}
+
+var b64 = base64.RawStdEncoding
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.MemoryKiB, a.Threads, a.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.MemoryKiB, a.Time, a.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a Argon2id) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.MemoryKiB, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Time != a.Time || params.MemoryKiB != a.MemoryKiB || params.Threads != a.Threads ||
+		uint32(len(key)) != a.KeyLen || uint32(len(salt)) != a.SaltLen
+}
+
+func decodeArgon2id(encoded string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+	return params, salt, key, nil
+}
+
+// Bcrypt hashes with bcrypt ($2a$, $2b$, $2y$). It is kept to verify hashes created by older
+// releases; bcrypt ignores everything after the first 72 bytes of a password.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b Bcrypt) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b Bcrypt) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
+
+var (
+	mu sync.RWMutex
+	// current hashes new passwords; known also verifies older hashes.
+	current Hasher = DefaultArgon2id
+	known          = []Hasher{Bcrypt{Cost: bcrypt.DefaultCost}}
+)
+
+// SetDefault sets the hasher used for new passwords. Hashes of other algorithms
+// are still verified and reported as needing a rehash.
+func SetDefault(h Hasher) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = h
+}
+
+// Hash returns the encoded hash of password with the default hasher.
+func Hash(password string) (string, error) {
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	return h.Hash(password)
+}
+
+// Verify reports whether password matches encoded, and whether encoded should be replaced
+// by a new Hash(password) because it uses another algorithm or outdated parameters.
+// Empty hashes (accounts without a local password) never match.
+func Verify(encoded, password string) (ok bool, rehash bool) {
+	if encoded == "" || password == "" || len(password) > MaxLength {
+		return false, false
+	}
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	if h.Handles(encoded) {
+		ok, err := h.Verify(encoded, password)
+		return ok && err == nil, ok && err == nil && h.NeedsRehash(encoded)
+	}
+	for _, k := range append([]Hasher{DefaultArgon2id}, known...) {
+		if k.Handles(encoded) {
+			ok, err := k.Verify(encoded, password)
+			return ok && err == nil, ok && err == nil
+		}
+	}
+	return false, false
+}
+
+var (
+	policyMu  sync.RWMutex
+	minLength = 8
+	breached  *breachedList
+)
+
+// SetPolicy sets the minimum password length (in characters) for new passwords and opens the
+// breached password list breachedFile (ignored if empty); see openBreachedList for its format.
+// The file stays open and is searched on every check, so it is never loaded into memory.
+func SetPolicy(minLen int, breachedFile string) error {
+	var list *breachedList
+	if breachedFile != "" {
+		var err error
+		if list, err = openBreachedList(breachedFile); err != nil {
+			return err
+		}
+	}
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if breached != nil {
+		breached.Close()
+	}
+	minLength = minLen
+	breached = list
+	return nil
+}
+
+// CheckPolicy returns an error if password is too short, too long or on the breached list.
+func CheckPolicy(password string) error {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	if n := utf8.RuneCountInString(password); n < minLength {
+		return ErrTooShort(minLength)
+	}
+	if len(password) > MaxLength {
+		return ErrTooLong
+	}
+	if breached != nil {
+		found, err := breached.Contains(sha1Hex(password))
+		if err != nil {
+			return err
+		}
+		if found {
+			return ErrBreached
+		}
+	}
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,37 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passwords
+
+import "github.com/go-errors/errors"
+
+// An error for a stored hash that cannot be decoded.
+var ErrMalformedHash = errors.Errorf("Malformed password hash.").Err
+
+// An error for a password shorter than the configured minimum.
+func ErrTooShort(minLength int) error {
+	return errors.Errorf("Password must be at least %d characters long.", minLength).Err
+}
+
+// An error for a password longer than MaxLength bytes.
+var ErrTooLong = errors.Errorf("Password is too long.").Err
+
+// An error for a password found in the breached password list.
+var ErrBreached = errors.Errorf("This password appeared in a data breach. Choose another one.").Err
+
+// An error for a breached password list that does not start with a SHA-1 line.
+func ErrBreachedListFormat(path string) error {
+	return errors.Errorf("%s is not a list of SHA-1 hashes sorted by hash.", path).Err
+}
@@ -0,0 +1,152 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passwords
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fast keeps the tests quick; production uses DefaultArgon2id.
+var fast = Argon2id{Time: 1, MemoryKiB: 64, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	SetDefault(fast)
+	defer SetDefault(DefaultArgon2id)
+
+	long := strings.Repeat("x", 100)
+	hash, err := Hash(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("hash = %q", hash)
+	}
+	if ok, rehash := Verify(hash, long); !ok || rehash {
+		t.Fatalf("Verify(correct) = %v, %v", ok, rehash)
+	}
+	// Unlike bcrypt, bytes after the 72nd matter.
+	if ok, _ := Verify(hash, long[:99]+"y"); ok {
+		t.Fatal("Verify(wrong) = true")
+	}
+
+	SetDefault(Argon2id{Time: 2, MemoryKiB: 64, Threads: 1, KeyLen: 32, SaltLen: 16})
+	if ok, rehash := Verify(hash, long); !ok || !rehash {
+		t.Fatalf("Verify with changed parameters = %v, %v; want true, true", ok, rehash)
+	}
+}
+
+func TestBcryptHashesNeedRehash(t *testing.T) {
+	SetDefault(fast)
+	defer SetDefault(DefaultArgon2id)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := Verify(string(hash), "secret"); !ok || !rehash {
+		t.Fatalf("Verify(bcrypt) = %v, %v; want true, true", ok, rehash)
+	}
+	if ok, rehash := Verify(string(hash), "other"); ok || rehash {
+		t.Fatalf("Verify(bcrypt, wrong) = %v, %v", ok, rehash)
+	}
+}
+
+func TestVerifyRejectsEmptyAndMalformed(t *testing.T) {
+	for _, hash := range []string{"", "$argon2id$v=19$m=64,t=1,p=1$bad", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "plain"} {
+		if ok, _ := Verify(hash, "secret"); ok {
+			t.Errorf("Verify(%q) = true", hash)
+		}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	lines := []string{sha1Hex("password123") + ":7\r\n", sha1Hex("letmein!") + ":42\r\n"}
+	sort.Strings(lines)
+	if err := os.WriteFile(list, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPolicy(8, list); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPolicy(8, "")
+
+	tests := []struct {
+		password string
+		wantErr  bool
+	}{
+		{"short", true},
+		{"password123", true},
+		{"letmein!", true},
+		{"correct horse", false},
+		{"ääääääää", false}, // length counts characters, not bytes
+		{strings.Repeat("x", MaxLength+1), true},
+	}
+	for _, tt := range tests {
+		if err := CheckPolicy(tt.password); (err != nil) != tt.wantErr {
+			t.Errorf("CheckPolicy(%.20q) = %v, wantErr %v", tt.password, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBreachedListSearch(t *testing.T) {
+	// Enough lines for several bisection steps before the final scan.
+	var lines []string
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d\n", sha1Hex(fmt.Sprintf("breached-%d", i)), i))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := openBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 5000; i++ {
+		if found, err := l.Contains(sha1Hex(fmt.Sprintf("breached-%d", i))); !found || err != nil {
+			t.Fatalf("Contains(breached-%d) = %v, %v", i, found, err)
+		}
+		if found, err := l.Contains(sha1Hex(fmt.Sprintf("other-%d", i))); found || err != nil {
+			t.Fatalf("Contains(other-%d) = %v, %v", i, found, err)
+		}
+	}
+	for _, hash := range []string{strings.Repeat("0", 40), strings.Repeat("F", 40)} {
+		if found, err := l.Contains(hash); found || err != nil {
+			t.Errorf("Contains(%s) = %v, %v", hash, found, err)
+		}
+	}
+}
+
+func TestBreachedListRejectsPlainPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("password123\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPolicy(8, path); err == nil {
+		SetPolicy(8, "")
+		t.Fatal("SetPolicy with a plain password list: want error")
+	}
+}
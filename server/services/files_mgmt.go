@@ -17,12 +17,14 @@ limitations under the License.
 package services
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/takecontrolsoft/go_multi_log/logger"
 	host "github.com/takecontrolsoft/sync_server/server/host"
 	"github.com/takecontrolsoft/sync_server/server/impl"
 	"github.com/takecontrolsoft/sync_server/server/config"
+	"github.com/takecontrolsoft/sync_server/server/passwords"
 	"github.com/takecontrolsoft/sync_server/server/store"
 )
 
@@ -33,22 +35,81 @@ type FilesManagementService struct {
 func (s FilesManagementService) Host() bool {
 	logger.Info("FilesManagementService hosted")
 	if config.AuthDBPath != "" {
-		if err := store.Open(config.AuthDBPath); err != nil {
+		initPasswords()
+		var tooNew *store.SchemaTooNewError
+		if err := store.Open(config.AuthDBPath); errors.As(err, &tooNew) {
+			logger.Fatal(err)
+		} else if err != nil {
 			logger.Error(err)
 		} else if err := store.BootstrapFromEnv(config.AdminUser, config.AdminPassword); err != nil {
 			logger.Error(err)
+		} else {
+			impl.StartStorageMigration()
+			impl.StartAuditRetention()
+			impl.StartChangesRetention()
 		}
 	}
+	impl.StartRescan()
 	http.HandleFunc("/upload", impl.UploadHandler)
 	http.HandleFunc("/auth/login", impl.LoginHandler)
 	http.HandleFunc("/auth/register", impl.RegisterHandler)
+	http.HandleFunc("/auth/oidc/login", impl.OIDCLoginHandler)
+	http.HandleFunc("/auth/oidc/callback", impl.OIDCCallbackHandler)
+	http.HandleFunc("/auth/login/2fa", impl.LoginTwoFactorHandler)
+	http.HandleFunc("/auth/2fa/enroll", impl.EnrollTwoFactorHandler)
+	http.HandleFunc("/auth/2fa/confirm", impl.ConfirmTwoFactorHandler)
+	http.HandleFunc("/auth/2fa/disable", impl.DisableTwoFactorHandler)
+	http.HandleFunc("/auth/2fa/recovery-codes", impl.RecoveryCodesHandler)
+	http.HandleFunc("/admin/settings", impl.AdminSettingsHandler)
+	http.HandleFunc("/admin/invites", impl.ListInvitesHandler)
+	http.HandleFunc("/admin/invites/create", impl.CreateInviteHandler)
+	http.HandleFunc("/admin/invites/revoke", impl.RevokeInviteHandler)
+	http.HandleFunc("/admin/users", impl.ListUsersHandler)
+	http.HandleFunc("/admin/users/disable", impl.DisableUserHandler)
+	http.HandleFunc("/admin/users/rename", impl.RenameUserHandler)
+	http.HandleFunc("/admin/users/delete", impl.DeleteUserHandler)
+	http.HandleFunc("/admin/storage/migrate", impl.MigrateStorageHandler)
+	http.HandleFunc("/admin/audit", impl.AuditLogHandler)
+	http.HandleFunc("/admin/catalog/reconcile", impl.ReconcileCatalogHandler)
+	http.HandleFunc("/admin/rescan", impl.RescanHandler)
+	http.HandleFunc("/admin/usage", impl.UsageHandler)
+	http.HandleFunc("/shares/create", impl.CreateShareHandler)
+	http.HandleFunc("/shares/list", impl.ListSharesHandler)
+	http.HandleFunc("/shares/revoke", impl.RevokeShareHandler)
+	http.HandleFunc("/libraries", impl.ListLibrariesHandler)
+	http.HandleFunc("/libraries/create", impl.CreateLibraryHandler)
+	http.HandleFunc("/libraries/delete", impl.DeleteLibraryHandler)
+	http.HandleFunc("/libraries/members", impl.LibraryMembersHandler)
+	http.HandleFunc("/libraries/add", impl.AddLibraryFilesHandler)
+	http.HandleFunc("/libraries/remove", impl.RemoveLibraryFilesHandler)
+	http.HandleFunc("/albums", impl.ListAlbumsHandler)
+	http.HandleFunc("/albums/create", impl.CreateAlbumHandler)
+	http.HandleFunc("/albums/update", impl.UpdateAlbumHandler)
+	http.HandleFunc("/albums/delete", impl.DeleteAlbumHandler)
+	http.HandleFunc("/albums/add", impl.AddAlbumFilesHandler)
+	http.HandleFunc("/albums/remove", impl.RemoveAlbumFilesHandler)
+	http.HandleFunc("/albums/reorder", impl.ReorderAlbumHandler)
+	http.HandleFunc("/albums/files", impl.GetAlbumFilesHandler)
+	http.HandleFunc("/annotations", impl.GetAnnotationsHandler)
+	http.HandleFunc("/annotations/set", impl.SetAnnotationsHandler)
+	http.HandleFunc("/annotations/clear", impl.ClearAnnotationsHandler)
+	http.HandleFunc("/tags", impl.ListTagsHandler)
 
 	//fs := http.FileServer(http.Dir(config.UploadDirectory))
 	//http.Handle("/", http.StripPrefix("/", fs))
 
 	http.HandleFunc("/folders", impl.GetFoldersHandler)
+	http.HandleFunc("/folders/summary", impl.FolderSummaryHandler)
 
 	http.HandleFunc("/files", impl.GetFilesHandler)
+	http.HandleFunc("/files/list", impl.ListFilesHandler)
+	http.HandleFunc("/timeline", impl.TimelineHandler)
+	http.HandleFunc("/memories", impl.MemoriesHandler)
+	http.HandleFunc("/changes", impl.GetChangesHandler)
+	http.HandleFunc("/search", impl.SearchHandler)
+	http.HandleFunc("/geo", impl.GeoHandler)
+	http.HandleFunc("/duplicates", impl.DuplicatesHandler)
+	http.HandleFunc("/duplicates/resolve", impl.ResolveDuplicatesHandler)
 
 	http.HandleFunc("/move-to-trash", impl.MoveToTrashHandler)
 	http.HandleFunc("/restore", impl.RestoreHandler)
@@ -63,3 +124,17 @@ func (s FilesManagementService) Host() bool {
 func init() {
 	host.RegisterWebService(FilesManagementService{})
 }
+
+// initPasswords applies the password hashing parameters and policy from config.
+func initPasswords() {
+	if config.Argon2Time > 0 && config.Argon2MemoryKiB > 0 && config.Argon2Threads > 0 {
+		params := passwords.DefaultArgon2id
+		params.Time = uint32(config.Argon2Time)
+		params.MemoryKiB = uint32(config.Argon2MemoryKiB)
+		params.Threads = uint8(min(config.Argon2Threads, 255))
+		passwords.SetDefault(params)
+	}
+	if err := passwords.SetPolicy(config.PasswordMinLength, config.BreachedPasswordsFile); err != nil {
+		logger.Error(err)
+	}
+}
@@ -29,6 +29,10 @@ func (s ImagesService) Host() bool {
 	fmt.Println("ImagesService::Host()")
 	http.HandleFunc("/img", impl.GetImageHandler)
 	http.HandleFunc("/stream", impl.GetStreamHandler)
+	http.HandleFunc("/sign-url", impl.SignURLHandler)
+	http.HandleFunc("/share", impl.PublicShareHandler)
+	http.HandleFunc("/share/img", impl.PublicShareImageHandler)
+	http.HandleFunc("/share/stream", impl.PublicShareStreamHandler)
 	return true
 }
 
@@ -0,0 +1,265 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Album is a named, ordered collection of files of one user across devices and months.
+type Album struct {
+	Id     string `json:"Id"`
+	UserId string `json:"UserId"`
+	Name   string `json:"Name"`
+	// Cover is the "deviceId/path" of the cover image; empty uses the first item.
+	Cover     string `json:"Cover"`
+	Count     int    `json:"Count"`
+	CreatedAt int64  `json:"CreatedAt"`
+	UpdatedAt int64  `json:"UpdatedAt"`
+}
+
+// FileRef references a file of a user by device and relative path (forward slashes).
+type FileRef struct {
+	DeviceId string `json:"DeviceId"`
+	Path     string `json:"Path"`
+}
+
+// CreateAlbum stores a. CreatedAt and UpdatedAt default to now.
+func CreateAlbum(a Album) error {
+	if db == nil {
+		return nil
+	}
+	if a.CreatedAt == 0 {
+		a.CreatedAt = time.Now().Unix()
+	}
+	if a.UpdatedAt == 0 {
+		a.UpdatedAt = a.CreatedAt
+	}
+	_, err := db.Exec(`INSERT INTO albums (id, user_id, name, cover, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		a.Id, a.UserId, a.Name, a.Cover, a.CreatedAt, a.UpdatedAt)
+	return err
+}
+
+const albumColumns = `a.id, a.user_id, a.name, a.cover, a.created_at, a.updated_at,
+	(SELECT COUNT(*) FROM album_items i WHERE i.album_id = a.id)`
+
+func scanAlbum(row rowScanner) (*Album, error) {
+	var a Album
+	if err := row.Scan(&a.Id, &a.UserId, &a.Name, &a.Cover, &a.CreatedAt, &a.UpdatedAt, &a.Count); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAlbum returns the album with id, or nil if there is none.
+func GetAlbum(id string) (*Album, error) {
+	if db == nil || id == "" {
+		return nil, nil
+	}
+	a, err := scanAlbum(db.QueryRow(`SELECT `+albumColumns+` FROM albums a WHERE a.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListAlbums returns the albums of userId ordered by name.
+func ListAlbums(userId string) ([]Album, error) {
+	albums := make([]Album, 0)
+	if db == nil {
+		return albums, nil
+	}
+	rows, err := db.Query(`SELECT `+albumColumns+` FROM albums a WHERE a.user_id = ? ORDER BY lower(a.name)`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAlbum(rows)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, *a)
+	}
+	return albums, rows.Err()
+}
+
+// UpdateAlbum sets the name and cover of the album.
+func UpdateAlbum(id, name, cover string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`UPDATE albums SET name = ?, cover = ?, updated_at = ? WHERE id = ?`, name, cover, time.Now().Unix(), id)
+	return err
+}
+
+// DeleteAlbum removes the album and its items. The files are not touched.
+func DeleteAlbum(id string) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM album_items WHERE album_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM albums WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListAlbumItems returns the items of the album in order.
+func ListAlbumItems(id string) ([]FileRef, error) {
+	items := make([]FileRef, 0)
+	if db == nil {
+		return items, nil
+	}
+	rows, err := db.Query(`SELECT device_id, path FROM album_items WHERE album_id = ? ORDER BY position, added_at`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it FileRef
+		if err := rows.Scan(&it.DeviceId, &it.Path); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// AddAlbumItems appends items to the end of the album. Items already in it keep their position.
+// Returns the number of items added.
+func AddAlbumItems(id string, items []FileRef) (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var next int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(position), -1) + 1 FROM album_items WHERE album_id = ?`, id).Scan(&next); err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	added := 0
+	for _, it := range items {
+		res, err := tx.Exec(`INSERT OR IGNORE INTO album_items (album_id, device_id, path, position, added_at) VALUES (?, ?, ?, ?, ?)`,
+			id, it.DeviceId, it.Path, next, now)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			next++
+			added++
+		}
+	}
+	if _, err := tx.Exec(`UPDATE albums SET updated_at = ? WHERE id = ?`, now, id); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+// RemoveAlbumItems removes items from the album. Returns the number of items removed.
+func RemoveAlbumItems(id string, items []FileRef) (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	removed := 0
+	for _, it := range items {
+		res, err := tx.Exec(`DELETE FROM album_items WHERE album_id = ? AND device_id = ? AND path = ?`, id, it.DeviceId, it.Path)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+	if _, err := tx.Exec(`UPDATE albums SET updated_at = ? WHERE id = ?`, time.Now().Unix(), id); err != nil {
+		return 0, err
+	}
+	return removed, tx.Commit()
+}
+
+// ReorderAlbumItems puts items first, in the given order; the other items follow in their current order.
+func ReorderAlbumItems(id string, items []FileRef) error {
+	if db == nil {
+		return nil
+	}
+	current, err := ListAlbumItems(id)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	position := 0
+	seen := make(map[FileRef]bool)
+	for _, it := range append(append([]FileRef{}, items...), current...) {
+		if seen[it] {
+			continue
+		}
+		seen[it] = true
+		res, err := tx.Exec(`UPDATE album_items SET position = ? WHERE album_id = ? AND device_id = ? AND path = ?`,
+			position, id, it.DeviceId, it.Path)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			position++
+		}
+	}
+	if _, err := tx.Exec(`UPDATE albums SET updated_at = ? WHERE id = ?`, time.Now().Unix(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MoveAlbumItems updates the album items and covers of userId that reference the file from on
+// deviceId after it was moved to to (e.g. into or out of Trash).
+func MoveAlbumItems(userId, deviceId, from, to string) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE OR IGNORE album_items SET path = ? WHERE device_id = ? AND path = ?
+		AND album_id IN (SELECT id FROM albums WHERE user_id = ?)`, to, deviceId, from, userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE albums SET cover = ? WHERE user_id = ? AND cover = ?`,
+		deviceId+"/"+to, userId, deviceId+"/"+from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
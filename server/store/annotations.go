@@ -0,0 +1,225 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Annotation is the favorite flag, star rating and tags a user set on a file.
+type Annotation struct {
+	DeviceId  string   `json:"DeviceId"`
+	Path      string   `json:"Path"`
+	Favorite  bool     `json:"Favorite"`
+	Rating    int      `json:"Rating"` // 0 (unrated) to 5
+	Tags      []string `json:"Tags"`
+	UpdatedAt int64    `json:"UpdatedAt"`
+}
+
+// AnnotationChange describes a bulk update; nil fields are left unchanged.
+type AnnotationChange struct {
+	Favorite   *bool
+	Rating     *int
+	AddTags    []string
+	RemoveTags []string
+}
+
+// TagCount is a tag with the number of files of a user carrying it.
+type TagCount struct {
+	Tag   string `json:"Tag"`
+	Count int    `json:"Count"`
+}
+
+// SetAnnotations applies change to the annotations of userId on files. Annotations left without
+// favorite, rating and tags are deleted.
+func SetAnnotations(userId string, files []FileRef, change AnnotationChange) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().Unix()
+	for _, f := range files {
+		key := []any{userId, f.DeviceId, f.Path}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO annotations (user_id, device_id, path, updated_at) VALUES (?, ?, ?, ?)`,
+			append(key, now)...); err != nil {
+			return err
+		}
+		if change.Favorite != nil {
+			if _, err := tx.Exec(`UPDATE annotations SET favorite = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+				append([]any{boolInt(*change.Favorite)}, key...)...); err != nil {
+				return err
+			}
+		}
+		if change.Rating != nil {
+			if _, err := tx.Exec(`UPDATE annotations SET rating = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+				append([]any{*change.Rating}, key...)...); err != nil {
+				return err
+			}
+		}
+		for _, tag := range change.RemoveTags {
+			if _, err := tx.Exec(`DELETE FROM annotation_tags WHERE user_id = ? AND device_id = ? AND path = ? AND tag = ?`,
+				append(key, tag)...); err != nil {
+				return err
+			}
+		}
+		for _, tag := range change.AddTags {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO annotation_tags (user_id, device_id, path, tag) VALUES (?, ?, ?, ?)`,
+				append(key, tag)...); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE annotations SET updated_at = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+			append([]any{now}, key...)...); err != nil {
+			return err
+		}
+		if err := deleteEmptyAnnotation(tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func deleteEmptyAnnotation(tx *sql.Tx, key []any) error {
+	_, err := tx.Exec(`DELETE FROM annotations WHERE user_id = ? AND device_id = ? AND path = ?
+		AND favorite = 0 AND rating = 0 AND NOT EXISTS (SELECT 1 FROM annotation_tags t
+		WHERE t.user_id = annotations.user_id AND t.device_id = annotations.device_id AND t.path = annotations.path)`, key...)
+	return err
+}
+
+// ClearAnnotations removes favorite, rating and tags of userId from files. Returns the files that
+// had annotations.
+func ClearAnnotations(userId string, files []FileRef) ([]FileRef, error) {
+	cleared := make([]FileRef, 0)
+	if db == nil {
+		return cleared, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, f := range files {
+		res, err := tx.Exec(`DELETE FROM annotations WHERE user_id = ? AND device_id = ? AND path = ?`, userId, f.DeviceId, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM annotation_tags WHERE user_id = ? AND device_id = ? AND path = ?`,
+			userId, f.DeviceId, f.Path); err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			cleared = append(cleared, f)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return cleared, nil
+}
+
+// ListAnnotations returns the annotations of userId on deviceId ("" = all devices), keyed by file.
+func ListAnnotations(userId, deviceId string) (map[FileRef]*Annotation, error) {
+	annotations := make(map[FileRef]*Annotation)
+	if db == nil {
+		return annotations, nil
+	}
+	rows, err := db.Query(`SELECT device_id, path, favorite, rating, updated_at FROM annotations
+		WHERE user_id = ? AND (? = '' OR device_id = ?)`, userId, deviceId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a := Annotation{Tags: []string{}}
+		var favorite int
+		if err := rows.Scan(&a.DeviceId, &a.Path, &favorite, &a.Rating, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		a.Favorite = favorite == 1
+		annotations[FileRef{DeviceId: a.DeviceId, Path: a.Path}] = &a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	tagRows, err := db.Query(`SELECT device_id, path, tag FROM annotation_tags
+		WHERE user_id = ? AND (? = '' OR device_id = ?) ORDER BY tag`, userId, deviceId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var ref FileRef
+		var tag string
+		if err := tagRows.Scan(&ref.DeviceId, &ref.Path, &tag); err != nil {
+			return nil, err
+		}
+		if a := annotations[ref]; a != nil {
+			a.Tags = append(a.Tags, tag)
+		}
+	}
+	return annotations, tagRows.Err()
+}
+
+// ListTags returns the tags of userId with the number of files carrying each, ordered by tag.
+func ListTags(userId string) ([]TagCount, error) {
+	tags := make([]TagCount, 0)
+	if db == nil {
+		return tags, nil
+	}
+	rows, err := db.Query(`SELECT tag, COUNT(*) FROM annotation_tags WHERE user_id = ? GROUP BY tag ORDER BY tag`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// MoveAnnotations makes the annotations of userId on the file from on deviceId follow it to to
+// (e.g. into or out of Trash).
+func MoveAnnotations(userId, deviceId, from, to string) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"annotations", "annotation_tags"} {
+		// Annotations left at the destination belong to a file that is no longer there.
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = ? AND device_id = ? AND path = ?`,
+			userId, deviceId, to); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE `+table+` SET path = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+			to, userId, deviceId, from); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
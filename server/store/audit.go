@@ -0,0 +1,137 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AuditEntry is one row of the append-only audit log.
+type AuditEntry struct {
+	Id int64 `json:"Id"`
+	At int64 `json:"At"`
+	// Actor is the user id of the session that made the request, the user named in the request
+	// when no session token was sent, or "system" for actions of the server itself.
+	Actor string `json:"Actor"`
+	// UserId is the user whose account or files are affected.
+	UserId string   `json:"UserId"`
+	Device string   `json:"Device"`
+	IP     string   `json:"IP"`
+	Action string   `json:"Action"`
+	Paths  []string `json:"Paths"`
+	Result string   `json:"Result"`
+	Detail string   `json:"Detail"`
+}
+
+// AuditFilter selects audit entries. Zero values do not filter.
+type AuditFilter struct {
+	// UserId matches entries where the user is the actor or the affected user.
+	UserId string
+	Action string
+	From   int64 // unix seconds, inclusive
+	To     int64 // unix seconds, exclusive
+	// BeforeId returns entries older than this id, for paging.
+	BeforeId int64
+	Limit    int
+}
+
+// AppendAudit stores e. At defaults to now.
+func AppendAudit(e AuditEntry) error {
+	if db == nil {
+		return nil
+	}
+	if e.At == 0 {
+		e.At = time.Now().Unix()
+	}
+	if e.Paths == nil {
+		e.Paths = []string{}
+	}
+	paths, err := json.Marshal(e.Paths)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO audit_log (at, actor, user_id, device, ip, action, paths, result, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.At, e.Actor, e.UserId, e.Device, e.IP, e.Action, string(paths), e.Result, e.Detail)
+	return err
+}
+
+// QueryAudit returns the entries matching f, newest first.
+func QueryAudit(f AuditFilter) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+	if db == nil {
+		return entries, nil
+	}
+	var where []string
+	var args []any
+	if f.UserId != "" {
+		where = append(where, `(actor = ? OR user_id = ?)`)
+		args = append(args, f.UserId, f.UserId)
+	}
+	if f.Action != "" {
+		where = append(where, `action = ?`)
+		args = append(args, f.Action)
+	}
+	if f.From > 0 {
+		where = append(where, `at >= ?`)
+		args = append(args, f.From)
+	}
+	if f.To > 0 {
+		where = append(where, `at < ?`)
+		args = append(args, f.To)
+	}
+	if f.BeforeId > 0 {
+		where = append(where, `id < ?`)
+		args = append(args, f.BeforeId)
+	}
+	query := `SELECT id, at, actor, user_id, device, ip, action, paths, result, detail FROM audit_log`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, f.Limit)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e AuditEntry
+		var paths string
+		if err := rows.Scan(&e.Id, &e.At, &e.Actor, &e.UserId, &e.Device, &e.IP, &e.Action, &paths, &e.Result, &e.Detail); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(paths), &e.Paths); err != nil {
+			e.Paths = []string{}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// PruneAudit deletes entries older than before and returns how many were deleted.
+func PruneAudit(before time.Time) (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+	res, err := db.Exec(`DELETE FROM audit_log WHERE at < ?`, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
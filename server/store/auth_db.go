@@ -27,7 +27,7 @@ import (
 	"time"
 
 	"github.com/takecontrolsoft/go_multi_log/logger"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/takecontrolsoft/sync_server/server/passwords"
 
 	_ "modernc.org/sqlite"
 )
@@ -39,7 +39,9 @@ var (
 	once sync.Once
 )
 
-// Open opens the auth SQLite database at path (creates file and dirs if needed). Safe to call once.
+// Open opens the auth SQLite database at path (creates file and dirs if needed) and applies
+// pending schema migrations. Returns a *SchemaTooNewError if the DB was migrated by a newer
+// binary. Safe to call once.
 func Open(path string) error {
 	var err error
 	once.Do(func() {
@@ -54,71 +56,114 @@ func Open(path string) error {
 		if err != nil {
 			return
 		}
-		err = initSchema()
+		if err = migrate(db); err != nil {
+			db.Close()
+			db = nil
+		}
 	})
 	return err
 }
 
-func initSchema() error {
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at INTEGER NOT NULL
-		);
-	`); err != nil {
-		return err
+// Close closes the auth DB. Open can be called again afterwards.
+func Close() error {
+	once = sync.Once{}
+	if db == nil {
+		return nil
 	}
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS sessions (
-			token TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			expires_at INTEGER NOT NULL
-		);
-	`)
+	err := db.Close()
+	db = nil
 	return err
 }
 
-// CreateUser adds a user with the given password (hashed with bcrypt). Returns userId (UUID). If user already exists, returns their id and nil.
+// CreateUser adds a user with the given password (hashed with passwords.Hash). Returns userId (UUID).
+// Returns ErrUserExists if the username is already taken (compared case-insensitively,
+// since the lowercased username names the storage folder).
 func CreateUser(username, password string) (userId string, err error) {
+	return RegisterUser(username, password, "")
+}
+
+// RegisterUser creates a user like CreateUser. If inviteCode is not empty, the invite must be
+// unused and not expired; it is consumed in the same transaction as the user is created.
+func RegisterUser(username, password, inviteCode string) (userId string, err error) {
 	if db == nil || username == "" || password == "" {
 		return "", nil
 	}
-	if id := GetUserIdByUsername(username); id != "" {
-		return id, nil
-	}
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 	userId = hex.EncodeToString(b)
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := passwords.Hash(password)
 	if err != nil {
 		return "", err
 	}
-	_, err = db.Exec(
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE lower(username) = lower(?)`, username).Scan(&n); err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return "", ErrUserExists
+	}
+	if err := useInvite(tx, inviteCode, userId); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(
 		`INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, ?, strftime('%s','now'))`,
-		userId, username, string(hash),
+		userId, username, hash,
 	)
 	if err != nil {
 		return "", err
 	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
 	return userId, nil
 }
 
-// VerifyUser returns true if the username exists and the password matches.
+// useInvite marks the invite code as used by userId. Does nothing if code is empty.
+// Returns ErrInvalidInvite if the code is unknown, used, revoked or expired.
+func useInvite(tx *sql.Tx, code, userId string) error {
+	if code == "" {
+		return nil
+	}
+	res, err := tx.Exec(
+		`UPDATE invites SET used_by = ?, used_at = strftime('%s','now')
+		 WHERE code = ? AND used_by IS NULL AND revoked = 0 AND expires_at > strftime('%s','now')`,
+		userId, code)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return ErrInvalidInvite
+	}
+	return nil
+}
+
+// VerifyUser returns true if the username exists, is not disabled and the password matches.
 func VerifyUser(username, password string) bool {
 	if db == nil || username == "" || password == "" {
 		return false
 	}
-	var hash string
-	err := db.QueryRow(`SELECT password_hash FROM users WHERE username = ?`, username).Scan(&hash)
+	var id, hash string
+	err := db.QueryRow(`SELECT id, password_hash FROM users WHERE username = ? AND disabled = 0`, username).Scan(&id, &hash)
 	if err == sql.ErrNoRows || err != nil {
 		return false
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	ok, rehash := passwords.Verify(hash, password)
+	if ok && rehash {
+		// Upgrade hashes of older algorithms or parameters while the password is known.
+		if newHash, err := passwords.Hash(password); err != nil {
+			logger.ErrorF("Auth DB: rehash password of %s: %v", id, err)
+		} else if _, err := db.Exec(`UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?`, newHash, id, hash); err != nil {
+			logger.ErrorF("Auth DB: rehash password of %s: %v", id, err)
+		}
+	}
+	return ok
 }
 
 // HasAnyUser returns true if at least one user exists (for bootstrap).
@@ -196,14 +241,16 @@ func CreateToken(userId string) (string, error) {
 	return token, err
 }
 
-// ValidateToken returns the user id if the token is valid and not expired, else empty string.
+// ValidateToken returns the user id if the token is valid, not expired and its user is not disabled, else empty string.
 func ValidateToken(token string) string {
 	if db == nil || token == "" {
 		return ""
 	}
 	var userId string
 	var expiresAt int64
-	err := db.QueryRow(`SELECT user_id, expires_at FROM sessions WHERE token = ?`, token).Scan(&userId, &expiresAt)
+	err := db.QueryRow(`
+		SELECT s.user_id, s.expires_at FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token = ? AND u.disabled = 0`, token).Scan(&userId, &expiresAt)
 	if err == sql.ErrNoRows || err != nil {
 		return ""
 	}
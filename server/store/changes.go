@@ -0,0 +1,137 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of changes in the change log.
+const (
+	ChangeAdd         = "add"         // a file was uploaded
+	ChangeTrash       = "trash"       // Path was moved to NewPath in Trash
+	ChangeRestore     = "restore"     // Path in Trash was restored to NewPath
+	ChangeMetadata    = "metadata"    // metadata or thumbnail of Path were (re)created
+	ChangeAnnotations = "annotations" // favorite, rating or tags of Path changed
+	ChangeDelete      = "delete"      // Path no longer exists
+)
+
+// Change is an entry of the change log of a user. Seq increases with every change.
+type Change struct {
+	Seq      int64  `json:"Seq"`
+	UserId   string `json:"-"`
+	DeviceId string `json:"DeviceId"`
+	Kind     string `json:"Kind"`
+	Path     string `json:"Path"`
+	// NewPath is the path after a trash or restore move, else empty.
+	NewPath string `json:"NewPath"`
+	At      int64  `json:"At"`
+}
+
+// RecordChange appends c to the change log. At defaults to now.
+func RecordChange(c Change) error {
+	if db == nil {
+		return nil
+	}
+	if c.At == 0 {
+		c.At = time.Now().Unix()
+	}
+	_, err := db.Exec(`INSERT INTO changes (user_id, device_id, kind, path, new_path, at) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.UserId, c.DeviceId, c.Kind, c.Path, c.NewPath, c.At)
+	return err
+}
+
+// ListChanges returns up to limit changes of userId after seq, oldest first. A non-empty deviceId
+// returns only the changes of that device.
+func ListChanges(userId, deviceId string, after int64, limit int) ([]Change, error) {
+	changes := make([]Change, 0)
+	if db == nil {
+		return changes, nil
+	}
+	rows, err := db.Query(`SELECT seq, user_id, device_id, kind, path, new_path, at FROM changes
+		WHERE user_id = ? AND seq > ? AND (? = '' OR device_id = ?) ORDER BY seq LIMIT ?`,
+		userId, after, deviceId, deviceId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Seq, &c.UserId, &c.DeviceId, &c.Kind, &c.Path, &c.NewPath, &c.At); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// LatestChangeSeq returns the sequence number of the newest change of userId, also if it was
+// deleted by PruneChanges, or 0.
+func LatestChangeSeq(userId string) (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+	var seq int64
+	err := db.QueryRow(`SELECT MAX(
+		COALESCE((SELECT MAX(seq) FROM changes WHERE user_id = ?), 0),
+		COALESCE((SELECT seq FROM changes_pruned WHERE user_id = ?), 0))`, userId, userId).Scan(&seq)
+	return seq, err
+}
+
+// PruneChanges deletes the changes older than before and returns how many were deleted. The Seq
+// of the newest deleted change of each user is kept, see PrunedChangeSeq.
+func PruneChanges(before time.Time) (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var seq sql.NullInt64
+	if err := tx.QueryRow(`SELECT MAX(seq) FROM changes WHERE at < ?`, before.Unix()).Scan(&seq); err != nil || !seq.Valid {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO changes_pruned (user_id, seq)
+		SELECT user_id, MAX(seq) FROM changes WHERE seq <= ? GROUP BY user_id
+		ON CONFLICT (user_id) DO UPDATE SET seq = MAX(seq, excluded.seq)`, seq.Int64); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`DELETE FROM changes WHERE seq <= ?`, seq.Int64)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PrunedChangeSeq returns the Seq of the newest change of userId deleted by PruneChanges, or 0.
+// Clients of the user with an older cursor have missed changes.
+func PrunedChangeSeq(userId string) (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+	var seq int64
+	err := db.QueryRow(`SELECT seq FROM changes_pruned WHERE user_id = ?`, userId).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
@@ -0,0 +1,112 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListChanges(t *testing.T) {
+	if err := Open(filepath.Join(t.TempDir(), "auth.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	for _, c := range []Change{
+		{UserId: "u1", DeviceId: "phone", Kind: ChangeAdd, Path: "a.jpg"},
+		{UserId: "u2", DeviceId: "phone", Kind: ChangeAdd, Path: "other.jpg"},
+		{UserId: "u1", DeviceId: "tablet", Kind: ChangeAdd, Path: "b.jpg"},
+		{UserId: "u1", DeviceId: "phone", Kind: ChangeTrash, Path: "a.jpg", NewPath: "Trash/a.jpg"},
+	} {
+		if err := RecordChange(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := ListChanges("u1", "", 0, 2)
+	if err != nil || len(page) != 2 || page[0].Path != "a.jpg" || page[1].Path != "b.jpg" {
+		t.Fatalf("first page = %+v, %v", page, err)
+	}
+	rest, err := ListChanges("u1", "", page[1].Seq, 2)
+	if err != nil || len(rest) != 1 || rest[0].Kind != ChangeTrash || rest[0].NewPath != "Trash/a.jpg" {
+		t.Fatalf("second page = %+v, %v", rest, err)
+	}
+	if more, _ := ListChanges("u1", "", rest[0].Seq, 2); len(more) != 0 {
+		t.Fatalf("after last = %+v", more)
+	}
+	phone, err := ListChanges("u1", "phone", 0, 10)
+	if err != nil || len(phone) != 2 || phone[0].DeviceId != "phone" || phone[1].DeviceId != "phone" {
+		t.Fatalf("phone = %+v, %v", phone, err)
+	}
+	if latest, err := LatestChangeSeq("u1"); err != nil || latest != rest[0].Seq {
+		t.Fatalf("latest = %d, %v", latest, err)
+	}
+	if latest, err := LatestChangeSeq("nobody"); err != nil || latest != 0 {
+		t.Fatalf("latest of unknown user = %d, %v", latest, err)
+	}
+}
+
+func TestPruneChanges(t *testing.T) {
+	if err := Open(filepath.Join(t.TempDir(), "auth.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	for _, c := range []Change{
+		{UserId: "u1", DeviceId: "phone", Kind: ChangeAdd, Path: "a.jpg", At: old},
+		{UserId: "u1", DeviceId: "phone", Kind: ChangeAdd, Path: "b.jpg", At: old},
+		{UserId: "u2", DeviceId: "phone", Kind: ChangeAdd, Path: "x.jpg", At: old},
+		{UserId: "u1", DeviceId: "phone", Kind: ChangeAdd, Path: "c.jpg"},
+	} {
+		if err := RecordChange(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := ListChanges("u1", "", 0, 10)
+	if seq, err := PrunedChangeSeq("u1"); err != nil || seq != 0 {
+		t.Fatalf("pruned seq before = %d, %v", seq, err)
+	}
+	n, err := PruneChanges(time.Now().Add(-24 * time.Hour))
+	if err != nil || n != 3 {
+		t.Fatalf("pruned = %d, %v", n, err)
+	}
+	left, err := ListChanges("u1", "", 0, 10)
+	if err != nil || len(left) != 1 || left[0].Path != "c.jpg" {
+		t.Fatalf("left = %+v, %v", left, err)
+	}
+	if seq, err := PrunedChangeSeq("u1"); err != nil || seq != before[1].Seq {
+		t.Fatalf("pruned seq of u1 = %d, %v; want %d", seq, err, before[1].Seq)
+	}
+	// u2 has no changes left; its pruned seq is its own, and Latest does not go back.
+	u2Seq := before[1].Seq + 1
+	if seq, err := PrunedChangeSeq("u2"); err != nil || seq != u2Seq {
+		t.Fatalf("pruned seq of u2 = %d, %v; want %d", seq, err, u2Seq)
+	}
+	if latest, err := LatestChangeSeq("u2"); err != nil || latest != u2Seq {
+		t.Fatalf("latest of u2 = %d, %v; want %d", latest, err, u2Seq)
+	}
+	if seq, err := PrunedChangeSeq("u3"); err != nil || seq != 0 {
+		t.Fatalf("pruned seq of a user without changes = %d, %v", seq, err)
+	}
+	// Nothing old enough: the pruned seqs stay.
+	if n, err := PruneChanges(time.Now().Add(-24 * time.Hour)); err != nil || n != 0 {
+		t.Fatalf("pruned again = %d, %v", n, err)
+	}
+	if seq, _ := PrunedChangeSeq("u1"); seq != before[1].Seq {
+		t.Fatalf("pruned seq after no-op = %d", seq)
+	}
+}
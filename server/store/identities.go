@@ -0,0 +1,95 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+)
+
+// GetUserIdByEmail returns the id of the user whose username equals email (case-insensitive),
+// or empty string if not found.
+func GetUserIdByEmail(email string) string {
+	if db == nil || email == "" {
+		return ""
+	}
+	var id string
+	err := db.QueryRow(`SELECT id FROM users WHERE lower(username) = lower(?) ORDER BY created_at LIMIT 1`, email).Scan(&id)
+	if err == sql.ErrNoRows || err != nil {
+		return ""
+	}
+	return id
+}
+
+// CreateExternalUser adds a user that signs in through an external identity provider only.
+// The user has no local password, so VerifyUser always fails for it. If inviteCode is not
+// empty, it is consumed as in RegisterUser.
+func CreateExternalUser(username, inviteCode string) (userId string, err error) {
+	if db == nil || username == "" {
+		return "", nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	userId = hex.EncodeToString(b)
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	if err := useInvite(tx, inviteCode, userId); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, '', strftime('%s','now'))`,
+		userId, username,
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+// GetUserIdByOIDCIdentity returns the id of the user linked to the OIDC subject of issuer,
+// or empty string if the identity is not linked.
+func GetUserIdByOIDCIdentity(issuer, subject string) string {
+	if db == nil || issuer == "" || subject == "" {
+		return ""
+	}
+	var id string
+	err := db.QueryRow(`SELECT user_id FROM oidc_identities WHERE issuer = ? AND subject = ?`, issuer, subject).Scan(&id)
+	if err == sql.ErrNoRows || err != nil {
+		return ""
+	}
+	return id
+}
+
+// LinkOIDCIdentity links the OIDC subject of issuer to the user with userId.
+func LinkOIDCIdentity(issuer, subject, userId string) error {
+	if db == nil || issuer == "" || subject == "" || userId == "" {
+		return nil
+	}
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO oidc_identities (issuer, subject, user_id, created_at) VALUES (?, ?, ?, strftime('%s','now'))`,
+		issuer, subject, userId,
+	)
+	return err
+}
@@ -0,0 +1,83 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Invite is a single-use registration code created by an admin.
+type Invite struct {
+	Code      string `json:"Code"`
+	CreatedBy string `json:"CreatedBy"`
+	CreatedAt int64  `json:"CreatedAt"`
+	ExpiresAt int64  `json:"ExpiresAt"`
+	Revoked   bool   `json:"Revoked"`
+	UsedBy    string `json:"UsedBy,omitempty"`
+	UsedAt    int64  `json:"UsedAt,omitempty"`
+}
+
+// CreateInvite stores a new invite code that expires at expiresAt.
+func CreateInvite(code, createdBy string, expiresAt time.Time) error {
+	if db == nil || code == "" {
+		return nil
+	}
+	_, err := db.Exec(
+		`INSERT INTO invites (code, created_by, created_at, expires_at) VALUES (?, ?, strftime('%s','now'), ?)`,
+		code, createdBy, expiresAt.Unix())
+	return err
+}
+
+// ListInvites returns all invites, newest first.
+func ListInvites() ([]Invite, error) {
+	invites := make([]Invite, 0)
+	if db == nil {
+		return invites, nil
+	}
+	rows, err := db.Query(`SELECT code, created_by, created_at, expires_at, revoked, used_by, used_at FROM invites ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var inv Invite
+		var revoked int
+		var usedBy sql.NullString
+		var usedAt sql.NullInt64
+		if err := rows.Scan(&inv.Code, &inv.CreatedBy, &inv.CreatedAt, &inv.ExpiresAt, &revoked, &usedBy, &usedAt); err != nil {
+			return nil, err
+		}
+		inv.Revoked = revoked == 1
+		inv.UsedBy = usedBy.String
+		inv.UsedAt = usedAt.Int64
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite makes an unused invite unusable. Returns false if no unused invite has this code.
+func RevokeInvite(code string) (bool, error) {
+	if db == nil || code == "" {
+		return false, nil
+	}
+	res, err := db.Exec(`UPDATE invites SET revoked = 1 WHERE code = ? AND used_by IS NULL`, code)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
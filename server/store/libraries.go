@@ -0,0 +1,282 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Library member roles, each including the permissions of the previous one.
+const (
+	LibraryView       = "view"       // browse the library
+	LibraryContribute = "contribute" // add own files, remove own additions
+	LibraryManage     = "manage"     // manage members, remove any item
+)
+
+var libraryRoleRank = map[string]int{LibraryView: 1, LibraryContribute: 2, LibraryManage: 3}
+
+// LibraryRoleAllows returns true if role grants the permissions of need.
+func LibraryRoleAllows(role, need string) bool {
+	return libraryRoleRank[role] > 0 && libraryRoleRank[role] >= libraryRoleRank[need]
+}
+
+// Library is a collection of files contributed by its members from their own storage.
+type Library struct {
+	Id        string `json:"Id"`
+	Name      string `json:"Name"`
+	OwnerId   string `json:"OwnerId"`
+	CreatedAt int64  `json:"CreatedAt"`
+	// Role of the user the library was listed for.
+	Role string `json:"Role,omitempty"`
+}
+
+// LibraryMember is a user with a role in a library.
+type LibraryMember struct {
+	UserId   string `json:"UserId"`
+	Username string `json:"Username"`
+	Role     string `json:"Role"`
+	AddedAt  int64  `json:"AddedAt"`
+}
+
+// LibraryItem references a file in the storage of its owner; files are never copied.
+type LibraryItem struct {
+	LibraryId string `json:"LibraryId"`
+	OwnerId   string `json:"OwnerId"`
+	DeviceId  string `json:"DeviceId"`
+	Path      string `json:"Path"`
+	AddedBy   string `json:"AddedBy"`
+	AddedAt   int64  `json:"AddedAt"`
+}
+
+// CreateLibrary stores lib and adds its owner as a manage member.
+func CreateLibrary(lib Library) error {
+	if db == nil {
+		return nil
+	}
+	if lib.CreatedAt == 0 {
+		lib.CreatedAt = time.Now().Unix()
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO libraries (id, name, owner_id, created_at) VALUES (?, ?, ?, ?)`,
+		lib.Id, lib.Name, lib.OwnerId, lib.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO library_members (library_id, user_id, role, added_at) VALUES (?, ?, ?, ?)`,
+		lib.Id, lib.OwnerId, LibraryManage, lib.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetLibrary returns the library with id, or nil if there is none.
+func GetLibrary(id string) (*Library, error) {
+	if db == nil || id == "" {
+		return nil, nil
+	}
+	var lib Library
+	err := db.QueryRow(`SELECT id, name, owner_id, created_at FROM libraries WHERE id = ?`, id).
+		Scan(&lib.Id, &lib.Name, &lib.OwnerId, &lib.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lib, nil
+}
+
+// ListLibraries returns the libraries userId is a member of, with the user's role, ordered by name.
+func ListLibraries(userId string) ([]Library, error) {
+	libs := make([]Library, 0)
+	if db == nil {
+		return libs, nil
+	}
+	rows, err := db.Query(`SELECT l.id, l.name, l.owner_id, l.created_at, m.role
+		FROM libraries l JOIN library_members m ON m.library_id = l.id
+		WHERE m.user_id = ? ORDER BY lower(l.name)`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lib Library
+		if err := rows.Scan(&lib.Id, &lib.Name, &lib.OwnerId, &lib.CreatedAt, &lib.Role); err != nil {
+			return nil, err
+		}
+		libs = append(libs, lib)
+	}
+	return libs, rows.Err()
+}
+
+// DeleteLibrary removes the library with its members and items. The files are not touched.
+func DeleteLibrary(id string) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`DELETE FROM library_items WHERE library_id = ?`,
+		`DELETE FROM library_members WHERE library_id = ?`,
+		`DELETE FROM libraries WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LibraryRole returns the role of userId in the library, or "" if the user is not a member.
+func LibraryRole(libraryId, userId string) string {
+	if db == nil || libraryId == "" || userId == "" {
+		return ""
+	}
+	var role string
+	if err := db.QueryRow(`SELECT role FROM library_members WHERE library_id = ? AND user_id = ?`,
+		libraryId, userId).Scan(&role); err != nil {
+		return ""
+	}
+	return role
+}
+
+// SetLibraryMember adds userId to the library or changes the user's role.
+// Returns ErrInvalidLibraryRole for an unknown role.
+func SetLibraryMember(libraryId, userId, role string) error {
+	if libraryRoleRank[role] == 0 {
+		return ErrInvalidLibraryRole
+	}
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`INSERT INTO library_members (library_id, user_id, role, added_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (library_id, user_id) DO UPDATE SET role = excluded.role`,
+		libraryId, userId, role, time.Now().Unix())
+	return err
+}
+
+// RemoveLibraryMember removes userId from the library. Items the user added stay.
+// Returns false if the user was not a member.
+func RemoveLibraryMember(libraryId, userId string) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+	res, err := db.Exec(`DELETE FROM library_members WHERE library_id = ? AND user_id = ?`, libraryId, userId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListLibraryMembers returns the members of the library ordered by username.
+func ListLibraryMembers(libraryId string) ([]LibraryMember, error) {
+	members := make([]LibraryMember, 0)
+	if db == nil {
+		return members, nil
+	}
+	rows, err := db.Query(`SELECT m.user_id, COALESCE(u.username, ''), m.role, m.added_at
+		FROM library_members m LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.library_id = ? ORDER BY lower(u.username)`, libraryId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m LibraryMember
+		if err := rows.Scan(&m.UserId, &m.Username, &m.Role, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddLibraryItem adds a file to the library. Adding a file twice is a no-op.
+func AddLibraryItem(item LibraryItem) error {
+	if db == nil {
+		return nil
+	}
+	if item.AddedAt == 0 {
+		item.AddedAt = time.Now().Unix()
+	}
+	_, err := db.Exec(`INSERT OR IGNORE INTO library_items (library_id, owner_id, device_id, path, added_by, added_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, item.LibraryId, item.OwnerId, item.DeviceId, item.Path, item.AddedBy, item.AddedAt)
+	return err
+}
+
+// GetLibraryItem returns the item for the file in the library, or nil if the file is not in it.
+func GetLibraryItem(libraryId, ownerId, deviceId, path string) (*LibraryItem, error) {
+	if db == nil {
+		return nil, nil
+	}
+	item := LibraryItem{LibraryId: libraryId, OwnerId: ownerId, DeviceId: deviceId, Path: path}
+	err := db.QueryRow(`SELECT added_by, added_at FROM library_items
+		WHERE library_id = ? AND owner_id = ? AND device_id = ? AND path = ?`,
+		libraryId, ownerId, deviceId, path).Scan(&item.AddedBy, &item.AddedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// RemoveLibraryItem removes the file from the library. Returns false if it was not in it.
+func RemoveLibraryItem(libraryId, ownerId, deviceId, path string) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+	res, err := db.Exec(`DELETE FROM library_items WHERE library_id = ? AND owner_id = ? AND device_id = ? AND path = ?`,
+		libraryId, ownerId, deviceId, path)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListMemberLibraryItems returns the items of all libraries userId is a member of.
+func ListMemberLibraryItems(userId string) ([]LibraryItem, error) {
+	items := make([]LibraryItem, 0)
+	if db == nil {
+		return items, nil
+	}
+	rows, err := db.Query(`SELECT i.library_id, i.owner_id, i.device_id, i.path, i.added_by, i.added_at
+		FROM library_items i JOIN library_members m ON m.library_id = i.library_id
+		WHERE m.user_id = ? ORDER BY i.library_id, i.path`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it LibraryItem
+		if err := rows.Scan(&it.LibraryId, &it.OwnerId, &it.DeviceId, &it.Path, &it.AddedBy, &it.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,318 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// MediaEntry is the catalog entry of a stored file of a user.
+type MediaEntry struct {
+	UserId     string
+	DeviceId   string
+	Path       string
+	Size       int64
+	ModifiedAt int64
+	// Hash is the hex SHA-256 of the content.
+	Hash string
+	// Trashed is true for paths in Trash/; set by UpsertMedia and MoveMedia.
+	Trashed  bool
+	MimeType string
+	// MediaType is "image", "video", "audio" or "".
+	MediaType string
+	Width     int
+	Height    int
+	Duration  float64
+	// CapturedAt is the capture time, else the modification time (unix seconds).
+	CapturedAt  int64
+	Make        string
+	Model       string
+	Lens        string
+	HasLocation bool
+	Latitude    float64
+	Longitude   float64
+	// HasMetadata and HasThumbnail are false for files indexed before they were created.
+	HasMetadata  bool
+	HasThumbnail bool
+	// PHash is the hex perceptual hash of an image, "" if not computed yet, or PHashFailed.
+	PHash string
+}
+
+// PHashFailed marks an image whose perceptual hash could not be computed.
+const PHashFailed = "-"
+
+// MediaStamp is the state a file had when it was indexed.
+type MediaStamp struct {
+	Size         int64
+	ModifiedAt   int64
+	HasMetadata  bool
+	HasThumbnail bool
+}
+
+// GeoBounds is a latitude/longitude box. West > East crosses the antimeridian.
+type GeoBounds struct {
+	North float64 `json:"North"`
+	South float64 `json:"South"`
+	East  float64 `json:"East"`
+	West  float64 `json:"West"`
+}
+
+// trashPrefix starts the paths of files in Trash.
+const trashPrefix = "Trash/"
+
+// MediaQuery selects indexed files of a user outside Trash. Zero fields do not filter; text
+// fields match case-insensitive substrings.
+type MediaQuery struct {
+	DeviceId    string
+	From        int64 // CapturedAt >= From
+	To          int64 // CapturedAt < To
+	MediaType   string
+	Make        string
+	Model       string
+	Lens        string
+	MinWidth    int
+	MinHeight   int
+	MinDuration float64
+	MaxDuration float64
+	Bounds      *GeoBounds
+}
+
+const mediaColumns = `user_id, device_id, path, size, modified_at, hash, trashed, mime_type, media_type, width, height,
+	duration, captured_at, make, model, lens, has_location, latitude, longitude, has_metadata, has_thumbnail, phash`
+
+func scanMediaEntry(row rowScanner) (*MediaEntry, error) {
+	var e MediaEntry
+	var trashed, hasLocation, hasMetadata, hasThumbnail int
+	if err := row.Scan(&e.UserId, &e.DeviceId, &e.Path, &e.Size, &e.ModifiedAt, &e.Hash, &trashed, &e.MimeType,
+		&e.MediaType, &e.Width, &e.Height, &e.Duration, &e.CapturedAt, &e.Make, &e.Model, &e.Lens,
+		&hasLocation, &e.Latitude, &e.Longitude, &hasMetadata, &hasThumbnail, &e.PHash); err != nil {
+		return nil, err
+	}
+	e.Trashed, e.HasLocation = trashed != 0, hasLocation != 0
+	e.HasMetadata, e.HasThumbnail = hasMetadata != 0, hasThumbnail != 0
+	return &e, nil
+}
+
+// UpsertMedia adds e to the catalog or replaces the entry of the same file.
+func UpsertMedia(e MediaEntry) error {
+	if db == nil {
+		return nil
+	}
+	e.Trashed = strings.HasPrefix(e.Path, trashPrefix)
+	_, err := db.Exec(`INSERT OR REPLACE INTO media (`+mediaColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.UserId, e.DeviceId, e.Path, e.Size, e.ModifiedAt, e.Hash, boolInt(e.Trashed), e.MimeType, e.MediaType,
+		e.Width, e.Height, e.Duration, e.CapturedAt, e.Make, e.Model, e.Lens, boolInt(e.HasLocation), e.Latitude,
+		e.Longitude, boolInt(e.HasMetadata), boolInt(e.HasThumbnail), e.PHash)
+	return err
+}
+
+// GetMedia returns the catalog entry of the file path on deviceId of userId, or nil if there is none.
+func GetMedia(userId, deviceId, path string) (*MediaEntry, error) {
+	if db == nil {
+		return nil, nil
+	}
+	e, err := scanMediaEntry(db.QueryRow(`SELECT `+mediaColumns+` FROM media WHERE user_id = ? AND device_id = ? AND path = ?`,
+		userId, deviceId, path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListMediaFolder returns the catalog entries of the files directly in folder ("2024/07") on deviceId
+// ("" = all devices) of userId, ordered by device and path. The folder "Trash" returns all trashed files.
+func ListMediaFolder(userId, deviceId, folder string) ([]MediaEntry, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE user_id = ? AND (? = '' OR device_id = ?)`
+	args := []any{userId, deviceId, deviceId}
+	switch {
+	case folder+"/" == trashPrefix:
+		query += ` AND trashed = 1`
+	case folder == "":
+		query += ` AND instr(path, '/') = 0`
+	default:
+		// Paths in folder sort between "folder/" and "folder0" ('0' follows '/').
+		query += ` AND path >= ? AND path < ? AND instr(substr(path, ?), '/') = 0`
+		args = append(args, folder+"/", folder+"0", len(folder)+2)
+	}
+	return queryMedia(query+` ORDER BY device_id, path`, args...)
+}
+
+// ListMediaFolders returns the folders ("2024/07") that hold files outside Trash on deviceId
+// ("" = all devices) of userId.
+func ListMediaFolders(userId, deviceId string) ([]string, error) {
+	folders := make([]string, 0)
+	if db == nil {
+		return folders, nil
+	}
+	// rtrim with all characters but "/" strips the file name.
+	rows, err := db.Query(`SELECT DISTINCT rtrim(path, replace(path, '/', '')) FROM media
+		WHERE user_id = ? AND (? = '' OR device_id = ?) AND trashed = 0`, userId, deviceId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var folder string
+		if err := rows.Scan(&folder); err != nil {
+			return nil, err
+		}
+		if folder = strings.TrimSuffix(folder, "/"); folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+	return folders, rows.Err()
+}
+
+func queryMedia(query string, args ...any) ([]MediaEntry, error) {
+	entries := make([]MediaEntry, 0)
+	if db == nil {
+		return entries, nil
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanMediaEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+// MoveMedia updates the catalog entry of the file from on deviceId of userId after it was moved to to.
+func MoveMedia(userId, deviceId, from, to string) error {
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM media WHERE user_id = ? AND device_id = ? AND path = ?`, userId, deviceId, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE media SET path = ?, trashed = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+		to, boolInt(strings.HasPrefix(to, trashPrefix)), userId, deviceId, from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteMedia removes the catalog entry of the file path on deviceId of userId.
+func DeleteMedia(userId, deviceId, path string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`DELETE FROM media WHERE user_id = ? AND device_id = ? AND path = ?`, userId, deviceId, path)
+	return err
+}
+
+// SetMediaPHash sets the perceptual hash of the file path on deviceId of userId.
+func SetMediaPHash(userId, deviceId, path, phash string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`UPDATE media SET phash = ? WHERE user_id = ? AND device_id = ? AND path = ?`,
+		phash, userId, deviceId, path)
+	return err
+}
+
+// MediaWithoutPHash returns at most limit images with a thumbnail whose perceptual hash was not
+// computed yet, of all users.
+func MediaWithoutPHash(limit int) ([]MediaEntry, error) {
+	return queryMedia(`SELECT `+mediaColumns+` FROM media
+		WHERE phash = '' AND media_type = 'image' AND has_thumbnail = 1 LIMIT ?`, limit)
+}
+
+// MediaStamps returns the cataloged files of deviceId of userId with the state they were indexed in.
+func MediaStamps(userId, deviceId string) (map[string]MediaStamp, error) {
+	stamps := make(map[string]MediaStamp)
+	if db == nil {
+		return stamps, nil
+	}
+	rows, err := db.Query(`SELECT path, size, modified_at, has_metadata, has_thumbnail FROM media
+		WHERE user_id = ? AND device_id = ?`, userId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var s MediaStamp
+		var hasMetadata, hasThumbnail int
+		if err := rows.Scan(&path, &s.Size, &s.ModifiedAt, &hasMetadata, &hasThumbnail); err != nil {
+			return nil, err
+		}
+		s.HasMetadata, s.HasThumbnail = hasMetadata != 0, hasThumbnail != 0
+		stamps[path] = s
+	}
+	return stamps, rows.Err()
+}
+
+// SearchMedia returns the cataloged files of userId outside Trash that match q.
+func SearchMedia(userId string, q MediaQuery) ([]MediaEntry, error) {
+	where := []string{`user_id = ?`, `trashed = 0`}
+	args := []any{userId}
+	add := func(cond string, arg ...any) {
+		where = append(where, cond)
+		args = append(args, arg...)
+	}
+	if q.DeviceId != "" {
+		add(`device_id = ?`, q.DeviceId)
+	}
+	if q.From != 0 {
+		add(`captured_at >= ?`, q.From)
+	}
+	if q.To != 0 {
+		add(`captured_at < ?`, q.To)
+	}
+	if q.MediaType != "" {
+		add(`media_type = ?`, q.MediaType)
+	}
+	for column, value := range map[string]string{"make": q.Make, "model": q.Model, "lens": q.Lens} {
+		if value != "" {
+			add(`instr(lower(`+column+`), ?) > 0`, strings.ToLower(value))
+		}
+	}
+	if q.MinWidth > 0 {
+		add(`width >= ?`, q.MinWidth)
+	}
+	if q.MinHeight > 0 {
+		add(`height >= ?`, q.MinHeight)
+	}
+	if q.MinDuration > 0 {
+		add(`duration >= ?`, q.MinDuration)
+	}
+	if q.MaxDuration > 0 {
+		add(`duration <= ?`, q.MaxDuration)
+	}
+	if b := q.Bounds; b != nil {
+		add(`has_location = 1 AND latitude BETWEEN ? AND ?`, b.South, b.North)
+		if b.West <= b.East {
+			add(`longitude BETWEEN ? AND ?`, b.West, b.East)
+		} else {
+			add(`(longitude >= ? OR longitude <= ?)`, b.West, b.East)
+		}
+	}
+	return queryMedia(`SELECT `+mediaColumns+` FROM media WHERE `+strings.Join(where, " AND "), args...)
+}
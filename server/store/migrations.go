@@ -0,0 +1,369 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/takecontrolsoft/go_multi_log/logger"
+)
+
+// migration is one step of the auth DB schema. Migrations are applied in order, each in its
+// own transaction together with its schema_version row. Never edit or reorder a released
+// migration; append a new one instead. Steps must also succeed on databases created before
+// versioning was introduced, hence IF NOT EXISTS and addColumnIfMissing.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "users and sessions", execAll(
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			expires_at INTEGER NOT NULL
+		);`,
+	)},
+	{2, "oidc identities", execAll(
+		`CREATE TABLE IF NOT EXISTS oidc_identities (
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (issuer, subject)
+		);`,
+	)},
+	{3, "two-factor authentication", execAll(
+		`CREATE TABLE IF NOT EXISTS totp_secrets (
+			user_id TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			last_counter INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			user_id TEXT NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at INTEGER,
+			PRIMARY KEY (user_id, code_hash)
+		);`,
+	)},
+	{4, "settings and invites", execAll(
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS invites (
+			code TEXT PRIMARY KEY,
+			created_by TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			revoked INTEGER NOT NULL DEFAULT 0,
+			used_by TEXT,
+			used_at INTEGER
+		);`,
+	)},
+	{5, "disabled users", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "users", "disabled", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{6, "audit log", execAll(
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			at INTEGER NOT NULL,
+			actor TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			device TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			action TEXT NOT NULL,
+			paths TEXT NOT NULL,
+			result TEXT NOT NULL,
+			detail TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS audit_log_at ON audit_log (at);`,
+		`CREATE INDEX IF NOT EXISTS audit_log_user_at ON audit_log (user_id, at);`,
+	)},
+	{7, "share links", execAll(
+		`CREATE TABLE IF NOT EXISTS shares (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			folder TEXT NOT NULL,
+			files TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			allow_download INTEGER NOT NULL DEFAULT 0,
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			created_by TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			revoked INTEGER NOT NULL DEFAULT 0,
+			views INTEGER NOT NULL DEFAULT 0,
+			last_viewed_at INTEGER NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS shares_created_by ON shares (created_by);`,
+	)},
+	{8, "shared libraries", execAll(
+		`CREATE TABLE IF NOT EXISTS libraries (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			owner_id TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS library_members (
+			library_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			added_at INTEGER NOT NULL,
+			PRIMARY KEY (library_id, user_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS library_members_user ON library_members (user_id);`,
+		`CREATE TABLE IF NOT EXISTS library_items (
+			library_id TEXT NOT NULL,
+			owner_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			added_by TEXT NOT NULL,
+			added_at INTEGER NOT NULL,
+			PRIMARY KEY (library_id, owner_id, device_id, path)
+		);`,
+		`CREATE INDEX IF NOT EXISTS library_items_owner ON library_items (owner_id, device_id, path);`,
+	)},
+	{9, "albums", execAll(
+		`CREATE TABLE IF NOT EXISTS albums (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			cover TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS albums_user ON albums (user_id);`,
+		`CREATE TABLE IF NOT EXISTS album_items (
+			album_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			added_at INTEGER NOT NULL,
+			PRIMARY KEY (album_id, device_id, path)
+		);`,
+	)},
+	{10, "annotations", execAll(
+		`CREATE TABLE IF NOT EXISTS annotations (
+			user_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			favorite INTEGER NOT NULL DEFAULT 0,
+			rating INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (user_id, device_id, path)
+		);`,
+		`CREATE TABLE IF NOT EXISTS annotation_tags (
+			user_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (user_id, device_id, path, tag)
+		);`,
+		`CREATE INDEX IF NOT EXISTS annotation_tags_tag ON annotation_tags (user_id, tag);`,
+	)},
+	{11, "change log", execAll(
+		`CREATE TABLE IF NOT EXISTS changes (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			path TEXT NOT NULL,
+			new_path TEXT NOT NULL DEFAULT '',
+			at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS changes_user_seq ON changes (user_id, seq);`,
+	)},
+	{12, "media index", execAll(
+		`CREATE TABLE IF NOT EXISTS media (
+			user_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			modified_at INTEGER NOT NULL,
+			mime_type TEXT NOT NULL,
+			media_type TEXT NOT NULL,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			duration REAL NOT NULL DEFAULT 0,
+			captured_at INTEGER NOT NULL,
+			make TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			lens TEXT NOT NULL DEFAULT '',
+			has_location INTEGER NOT NULL DEFAULT 0,
+			latitude REAL NOT NULL DEFAULT 0,
+			longitude REAL NOT NULL DEFAULT 0,
+			has_metadata INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, device_id, path)
+		);`,
+		`CREATE INDEX IF NOT EXISTS media_user_captured ON media (user_id, captured_at);`,
+	)},
+	{13, "media catalog", execAll(
+		`ALTER TABLE media ADD COLUMN hash TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE media ADD COLUMN trashed INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE media ADD COLUMN has_thumbnail INTEGER NOT NULL DEFAULT 0;`,
+		`UPDATE media SET trashed = 1 WHERE path LIKE 'Trash/%';`,
+	)},
+	{14, "perceptual hashes", execAll(
+		`ALTER TABLE media ADD COLUMN phash TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS media_user_hash ON media(user_id, hash);`,
+	)},
+	{15, "pruned changes", execAll(
+		`CREATE TABLE IF NOT EXISTS changes_pruned (
+			user_id TEXT PRIMARY KEY,
+			seq INTEGER NOT NULL
+		);`,
+	)},
+	// Capture times with a UTC offset were stored converted to UTC; re-index them as local times.
+	{16, "local capture times", execAll(
+		`UPDATE media SET modified_at = -1 WHERE has_metadata = 1;`,
+	)},
+}
+
+// MigrationStatus describes the schema version of an auth DB compared to this binary.
+type MigrationStatus struct {
+	Current int
+	Latest  int
+	// Pending lists the migrations that Open would apply, as "<version> <name>".
+	Pending []string
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// GetMigrationStatus reports the schema version of the DB at path without changing it.
+// It uses its own connection, so it can run while the server is stopped or before Open.
+// A missing file is reported as version 0 with all migrations pending.
+func GetMigrationStatus(path string) (MigrationStatus, error) {
+	status := MigrationStatus{Latest: LatestSchemaVersion(), Pending: make([]string, 0)}
+	if _, err := os.Stat(path); err == nil {
+		conn, err := sql.Open("sqlite", path)
+		if err != nil {
+			return status, err
+		}
+		defer conn.Close()
+		if status.Current, err = schemaVersion(conn); err != nil {
+			return status, err
+		}
+	} else if !os.IsNotExist(err) {
+		return status, err
+	}
+	for _, m := range migrations {
+		if m.Version > status.Current {
+			status.Pending = append(status.Pending, migrationLabel(m))
+		}
+	}
+	return status, nil
+}
+
+// migrate brings the schema of conn to LatestSchemaVersion.
+func migrate(conn *sql.DB) error {
+	if _, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	);`); err != nil {
+		return err
+	}
+	current, err := schemaVersion(conn)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return &SchemaTooNewError{Version: current, Supported: latest}
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(conn, m); err != nil {
+			return MigrationFailed(migrationLabel(m), err)
+		}
+		logger.InfoF("Auth DB: applied migration %s", migrationLabel(m))
+	}
+	return nil
+}
+
+func applyMigration(conn *sql.DB, m migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the highest applied migration, or 0 for an unversioned DB.
+func schemaVersion(conn *sql.DB) (int, error) {
+	var n int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&n); err != nil || n == 0 {
+		return 0, err
+	}
+	var version int
+	err := conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+func migrationLabel(m migration) string {
+	return strconv.Itoa(m.Version) + " " + m.Name
+}
+
+// execAll returns a migration step that runs the statements in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumnIfMissing adds the column to table unless it already exists.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
@@ -0,0 +1,94 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateUpgradesUnversionedDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.db")
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Schema as created by releases before versioned migrations.
+	for _, stmt := range []string{
+		`CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT UNIQUE NOT NULL, password_hash TEXT NOT NULL, created_at INTEGER NOT NULL);`,
+		`CREATE TABLE sessions (token TEXT PRIMARY KEY, user_id TEXT NOT NULL, expires_at INTEGER NOT NULL);`,
+		`INSERT INTO users VALUES ('u1', 'a@example.com', 'x', 1);`,
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, err := GetMigrationStatus(path)
+	if err != nil || status.Current != 0 || len(status.Pending) != len(migrations) {
+		t.Fatalf("status before = %+v, %v", status, err)
+	}
+	if err := migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	var disabled int
+	if err := conn.QueryRow(`SELECT disabled FROM users WHERE id = 'u1'`).Scan(&disabled); err != nil || disabled != 0 {
+		t.Fatalf("disabled = %d, %v", disabled, err)
+	}
+	status, err = GetMigrationStatus(path)
+	if err != nil || status.Current != LatestSchemaVersion() || len(status.Pending) != 0 {
+		t.Fatalf("status after = %+v, %v", status, err)
+	}
+	// Running again is a no-op.
+	if err := migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateRefusesNewerDB(t *testing.T) {
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`INSERT INTO schema_version VALUES (?, 'future', 0)`, LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	var tooNew *SchemaTooNewError
+	if err := migrate(conn); !errors.As(err, &tooNew) || tooNew.Version != LatestSchemaVersion()+1 {
+		t.Fatalf("migrate() = %v, want SchemaTooNewError", err)
+	}
+}
+
+func TestMigrationFailedWrapsCause(t *testing.T) {
+	err := MigrationFailed("3 two-factor authentication", sql.ErrConnDone)
+	if !errors.Is(err, sql.ErrConnDone) {
+		t.Fatalf("MigrationFailed() = %v, want it to wrap sql.ErrConnDone", err)
+	}
+}
+
+func TestGetMigrationStatusMissingFile(t *testing.T) {
+	status, err := GetMigrationStatus(filepath.Join(t.TempDir(), "missing.db"))
+	if err != nil || status.Current != 0 || len(status.Pending) != len(migrations) {
+		t.Fatalf("status = %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,160 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Share is a public link to a set of files or a folder of one user's device.
+type Share struct {
+	Id       string `json:"Id"`
+	UserId   string `json:"UserId"`
+	DeviceId string `json:"DeviceId"`
+	// Folder (e.g. "2024/07") shares all files directly in it; otherwise Files lists the shared paths.
+	Folder        string   `json:"Folder"`
+	Files         []string `json:"Files"`
+	HasPassword   bool     `json:"HasPassword"`
+	PasswordHash  string   `json:"-"`
+	AllowDownload bool     `json:"AllowDownload"`
+	ExpiresAt     int64    `json:"ExpiresAt"` // unix seconds, 0 = never
+	CreatedBy     string   `json:"CreatedBy"`
+	CreatedAt     int64    `json:"CreatedAt"`
+	Revoked       bool     `json:"Revoked"`
+	Views         int64    `json:"Views"`
+	LastViewedAt  int64    `json:"LastViewedAt"`
+}
+
+// Active returns true if the share is not revoked and not expired.
+func (s *Share) Active() bool {
+	return !s.Revoked && (s.ExpiresAt == 0 || time.Now().Unix() < s.ExpiresAt)
+}
+
+const shareColumns = `id, user_id, device_id, folder, files, password_hash, allow_download, expires_at,
+	created_by, created_at, revoked, views, last_viewed_at`
+
+// CreateShare stores s. CreatedAt defaults to now.
+func CreateShare(s Share) error {
+	if db == nil {
+		return nil
+	}
+	if s.CreatedAt == 0 {
+		s.CreatedAt = time.Now().Unix()
+	}
+	if s.Files == nil {
+		s.Files = []string{}
+	}
+	files, err := json.Marshal(s.Files)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO shares (`+shareColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0)`,
+		s.Id, s.UserId, s.DeviceId, s.Folder, string(files), s.PasswordHash, boolInt(s.AllowDownload), s.ExpiresAt,
+		s.CreatedBy, s.CreatedAt)
+	return err
+}
+
+// GetShare returns the share with id, or nil if there is none.
+func GetShare(id string) (*Share, error) {
+	if db == nil || id == "" {
+		return nil, nil
+	}
+	s, err := scanShare(db.QueryRow(`SELECT `+shareColumns+` FROM shares WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListShares returns the shares created by userId, newest first.
+func ListShares(userId string) ([]Share, error) {
+	shares := make([]Share, 0)
+	if db == nil {
+		return shares, nil
+	}
+	rows, err := db.Query(`SELECT `+shareColumns+` FROM shares WHERE created_by = ? ORDER BY created_at DESC`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *s)
+	}
+	return shares, rows.Err()
+}
+
+// RevokeShare revokes the share id. If createdBy is not empty, only a share created by that
+// user is revoked. Returns false if no share matched.
+func RevokeShare(id, createdBy string) (bool, error) {
+	if db == nil || id == "" {
+		return false, nil
+	}
+	query := `UPDATE shares SET revoked = 1 WHERE id = ?`
+	args := []any{id}
+	if createdBy != "" {
+		query += ` AND created_by = ?`
+		args = append(args, createdBy)
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RecordShareView increments the view count of the share.
+func RecordShareView(id string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`UPDATE shares SET views = views + 1, last_viewed_at = ? WHERE id = ?`, time.Now().Unix(), id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanShare(row rowScanner) (*Share, error) {
+	var s Share
+	var files string
+	var allowDownload, revoked int
+	if err := row.Scan(&s.Id, &s.UserId, &s.DeviceId, &s.Folder, &files, &s.PasswordHash, &allowDownload,
+		&s.ExpiresAt, &s.CreatedBy, &s.CreatedAt, &revoked, &s.Views, &s.LastViewedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(files), &s.Files); err != nil || s.Files == nil {
+		s.Files = []string{}
+	}
+	s.HasPassword = s.PasswordHash != ""
+	s.AllowDownload = allowDownload == 1
+	s.Revoked = revoked == 1
+	return &s, nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
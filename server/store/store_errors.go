@@ -0,0 +1,50 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+
+	"github.com/go-errors/errors"
+)
+
+// An error for registering a username that is already taken.
+var ErrUserExists = errors.Errorf("User already exists.").Err
+
+// An error for an invite code that is unknown, already used, revoked or expired.
+var ErrInvalidInvite = errors.Errorf("Invalid or expired invite code.").Err
+
+// An error for operations on a user id that does not exist.
+var ErrUserNotFound = errors.Errorf("User not found.").Err
+
+// An error for a library member with a role other than view, contribute or manage.
+var ErrInvalidLibraryRole = errors.Errorf("Invalid library role; use view, contribute or manage.").Err
+
+// An error for a migration step that could not be applied.
+func MigrationFailed(migration string, err error) error {
+	return errors.Errorf("Auth DB migration %s failed: %w", migration, err).Err
+}
+
+// SchemaTooNewError is returned by Open when the DB was migrated by a newer version of the
+// server. Running an older binary against it could lose or corrupt data, so it must not start.
+type SchemaTooNewError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("Auth DB schema version %d is newer than the version %d supported by this server. Upgrade the server.", e.Version, e.Supported)
+}
@@ -0,0 +1,167 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+)
+
+// TOTPState is the two-factor enrolment of a user.
+type TOTPState struct {
+	Secret      string
+	Enabled     bool
+	LastCounter int64
+}
+
+// GetTOTP returns the TOTP enrolment of the user, or nil if the user has none (not even pending).
+func GetTOTP(userId string) *TOTPState {
+	if db == nil || userId == "" {
+		return nil
+	}
+	var st TOTPState
+	var enabled int
+	err := db.QueryRow(`SELECT secret, enabled, last_counter FROM totp_secrets WHERE user_id = ?`, userId).
+		Scan(&st.Secret, &enabled, &st.LastCounter)
+	if err == sql.ErrNoRows || err != nil {
+		return nil
+	}
+	st.Enabled = enabled == 1
+	return &st
+}
+
+// HasTOTPEnabled returns true if the user completed TOTP enrolment.
+func HasTOTPEnabled(userId string) bool {
+	st := GetTOTP(userId)
+	return st != nil && st.Enabled
+}
+
+// SetPendingTOTP stores a new, not yet confirmed TOTP secret for the user.
+// It replaces an earlier pending secret but never an enabled one.
+func SetPendingTOTP(userId, secret string) error {
+	if db == nil || userId == "" {
+		return nil
+	}
+	_, err := db.Exec(`
+		INSERT INTO totp_secrets (user_id, secret, enabled, last_counter, created_at)
+		VALUES (?, ?, 0, 0, strftime('%s','now'))
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at
+		WHERE totp_secrets.enabled = 0`,
+		userId, secret)
+	return err
+}
+
+// EnableTOTP marks the pending TOTP secret of the user as confirmed.
+func EnableTOTP(userId string) error {
+	if db == nil || userId == "" {
+		return nil
+	}
+	_, err := db.Exec(`UPDATE totp_secrets SET enabled = 1 WHERE user_id = ?`, userId)
+	return err
+}
+
+// DisableTOTP removes the TOTP secret and the recovery codes of the user.
+func DisableTOTP(userId string) error {
+	if db == nil || userId == "" {
+		return nil
+	}
+	if _, err := db.Exec(`DELETE FROM totp_secrets WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userId)
+	return err
+}
+
+// UseTOTPCounter records counter as the last used time step of the user.
+// Returns false if a code of this or a later time step was already used (replay).
+func UseTOTPCounter(userId string, counter int64) bool {
+	if db == nil || userId == "" {
+		return false
+	}
+	res, err := db.Exec(`UPDATE totp_secrets SET last_counter = ? WHERE user_id = ? AND last_counter < ?`, counter, userId, counter)
+	if err != nil {
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n == 1
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of the user. Only hashes are stored.
+func ReplaceRecoveryCodes(userId string, codes []string) error {
+	if db == nil || userId == "" {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userId, hashRecoveryCode(code)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes an unused recovery code of the user. Returns true if the code was valid.
+func UseRecoveryCode(userId, code string) bool {
+	if db == nil || userId == "" || code == "" {
+		return false
+	}
+	res, err := db.Exec(`UPDATE recovery_codes SET used_at = strftime('%s','now') WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		userId, hashRecoveryCode(code))
+	if err != nil {
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n == 1
+}
+
+// hashRecoveryCode normalizes a recovery code (case, dashes, spaces) and hashes it.
+// Recovery codes are random, so a fast hash is sufficient.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetSetting returns the value of a server setting stored in the DB and whether it is set.
+func GetSetting(key string) (string, bool) {
+	if db == nil || key == "" {
+		return "", false
+	}
+	var value string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// SetSetting stores a server setting in the DB.
+func SetSetting(key, value string) error {
+	if db == nil || key == "" {
+		return nil
+	}
+	_, err := db.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
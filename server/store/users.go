@@ -0,0 +1,165 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+// User is an account as listed for administration.
+type User struct {
+	Id             string `json:"Id"`
+	Username       string `json:"Username"`
+	CreatedAt      int64  `json:"CreatedAt"`
+	Disabled       bool   `json:"Disabled"`
+	TwoFactor      bool   `json:"TwoFactor"`
+	ActiveSessions int    `json:"ActiveSessions"`
+}
+
+// ListUsers returns all users ordered by username.
+func ListUsers() ([]User, error) {
+	users := make([]User, 0)
+	if db == nil {
+		return users, nil
+	}
+	rows, err := db.Query(`
+		SELECT u.id, u.username, u.created_at, u.disabled,
+			COALESCE((SELECT enabled FROM totp_secrets t WHERE t.user_id = u.id), 0),
+			(SELECT COUNT(*) FROM sessions s WHERE s.user_id = u.id AND s.expires_at > strftime('%s','now'))
+		FROM users u ORDER BY lower(u.username)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u User
+		var disabled, twoFactor int
+		if err := rows.Scan(&u.Id, &u.Username, &u.CreatedAt, &disabled, &twoFactor, &u.ActiveSessions); err != nil {
+			return nil, err
+		}
+		u.Disabled = disabled == 1
+		u.TwoFactor = twoFactor == 1
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// IsUserDisabled returns true if the user exists and is disabled.
+func IsUserDisabled(userId string) bool {
+	if db == nil || userId == "" {
+		return false
+	}
+	var disabled int
+	err := db.QueryRow(`SELECT disabled FROM users WHERE id = ?`, userId).Scan(&disabled)
+	return err == nil && disabled == 1
+}
+
+// SetUserDisabled disables or re-enables a user. Disabling also ends all sessions of the user.
+// Returns false if the user does not exist.
+func SetUserDisabled(userId string, disabled bool) (bool, error) {
+	if db == nil || userId == "" {
+		return false, nil
+	}
+	v := 0
+	if disabled {
+		v = 1
+	}
+	res, err := db.Exec(`UPDATE users SET disabled = ? WHERE id = ?`, v, userId)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if disabled {
+		if _, err := db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userId); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// RenameUser changes the login username (email) of a user.
+// Returns ErrUserExists if another user already has the name (case-insensitive)
+// and ErrUserNotFound if there is no user with userId.
+func RenameUser(userId, username string) error {
+	if db == nil || userId == "" || username == "" {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE lower(username) = lower(?) AND id <> ?`, username, userId).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrUserExists
+	}
+	res, err := tx.Exec(`UPDATE users SET username = ? WHERE id = ?`, username, userId)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrUserNotFound
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes the user with its sessions, linked identities, two-factor data, share links,
+// the libraries the user owns, the user's library memberships and items, albums, annotations,
+// change log and media index. Stored files are not touched. Returns false if the user does not exist.
+func DeleteUser(userId string) (bool, error) {
+	if db == nil || userId == "" {
+		return false, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM users WHERE id = ?`, userId)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	for _, stmt := range []string{
+		`DELETE FROM sessions WHERE user_id = ?`,
+		`DELETE FROM oidc_identities WHERE user_id = ?`,
+		`DELETE FROM totp_secrets WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM shares WHERE user_id = ?`,
+		`DELETE FROM library_items WHERE library_id IN (SELECT id FROM libraries WHERE owner_id = ?)`,
+		`DELETE FROM library_members WHERE library_id IN (SELECT id FROM libraries WHERE owner_id = ?)`,
+		`DELETE FROM libraries WHERE owner_id = ?`,
+		`DELETE FROM library_items WHERE owner_id = ?`,
+		`DELETE FROM library_members WHERE user_id = ?`,
+		`DELETE FROM album_items WHERE album_id IN (SELECT id FROM albums WHERE user_id = ?)`,
+		`DELETE FROM albums WHERE user_id = ?`,
+		`DELETE FROM annotations WHERE user_id = ?`,
+		`DELETE FROM annotation_tags WHERE user_id = ?`,
+		`DELETE FROM changes WHERE user_id = ?`,
+		`DELETE FROM changes_pruned WHERE user_id = ?`,
+		`DELETE FROM media WHERE user_id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, userId); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,80 @@
+/* Copyright 2026 Take Control - Software & Infrastructure
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B test vectors for SHA1 (secret "12345678901234567890"), truncated to 6 digits.
+func TestCodeAt_rfc6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := CodeAt(secret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CodeAt(%d): got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, _ := CodeAt(secret, Counter(now))
+	if c, ok := Validate(secret, code, now); !ok || c != Counter(now) {
+		t.Errorf("current code not accepted")
+	}
+	prev, _ := CodeAt(secret, Counter(now)-1)
+	if _, ok := Validate(secret, prev, now); !ok {
+		t.Errorf("previous period code not accepted")
+	}
+	old, _ := CodeAt(secret, Counter(now)-3)
+	if _, ok := Validate(secret, old, now); ok {
+		t.Errorf("code three periods old accepted")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Errorf("short code accepted")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Sync Server", "mum@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Sync%20Server:mum@example.com?") {
+		t.Errorf("unexpected label: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Sync+Server") {
+		t.Errorf("missing parameters: %s", uri)
+	}
+}
@@ -18,6 +18,8 @@ package utils
 import (
 	"bufio"
 	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"image"
 	"image/color"
@@ -94,6 +96,15 @@ func GenerateRandomString(length int) string {
 	return string(result)
 }
 
+// RandomHex returns n cryptographically random bytes, hex encoded (for tokens and challenges).
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func JsonReaderFactory(in interface{}) (io.Reader, error) {
 	buf := bytes.NewBuffer(nil)
 	enc := json.NewEncoder(buf)